@@ -0,0 +1,197 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package cache keeps generated reports, so that repeated requests for the same report are not rendered again
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// Source produces a report, see report.Report
+type Source interface {
+	Generate() (io.ReadCloser, error)
+	Title() string
+	Clean()
+}
+
+// Partial is implemented by sources whose report can be incomplete, e.g. because some panels failed to render.
+// Incomplete reports are returned but not cached.
+type Partial interface {
+	Complete() bool
+}
+
+// Entry describes a cached report
+type Entry struct {
+	Key     string
+	Title   string
+	Size    int64
+	Created time.Time
+}
+
+// Cache holds reports in a Store up to a total size, evicting the least recently used ones first.
+// Concurrent requests for a report that is not cached yet share a single render.
+type Cache struct {
+	store Store
+	limit int64
+
+	mu      sync.Mutex
+	size    int64
+	lru     *list.List //of *Entry, most recently used first
+	entries map[string]*list.Element
+	pending map[string]*render
+}
+
+// render is a report being generated, which other requests for the same key wait for
+type render struct {
+	done chan struct{}
+	err  error
+}
+
+// New returns a Cache that keeps reports in store, up to limit bytes in total. There is no limit if it is 0.
+// The most recently generated report is kept even if it is larger than the limit on its own.
+func New(store Store, limit int64) *Cache {
+	return &Cache{
+		store:   store,
+		limit:   limit,
+		lru:     list.New(),
+		entries: map[string]*list.Element{},
+		pending: map[string]*render{},
+	}
+}
+
+// Get returns the cached report with the given key, if there is one. Close the file after reading it.
+func (c *Cache) Get(key string) (Entry, File, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return Entry{}, nil, false
+	}
+	f, err := c.store.Open(key)
+	if err != nil {
+		log.Println("Error opening cached report:", err)
+		c.remove(el)
+		return Entry{}, nil, false
+	}
+	c.lru.MoveToFront(el)
+	return *el.Value.(*Entry), f, true
+}
+
+// Do returns the cached report with the given key, or generates it from src and caches it.
+// If the report is already being generated for another caller, Do waits for that render instead.
+// Close the file after reading it. src is cleaned up by Do.
+func (c *Cache) Do(key string, src Source) (Entry, File, error) {
+	for {
+		if e, f, ok := c.Get(key); ok {
+			log.Println("Serving cached report:", key)
+			src.Clean()
+			return e, f, nil
+		}
+
+		c.mu.Lock()
+		if r, ok := c.pending[key]; ok {
+			c.mu.Unlock()
+			log.Println("Waiting for report being generated:", key)
+			<-r.done
+			if r.err != nil {
+				src.Clean()
+				return Entry{}, nil, r.err
+			}
+			//the report is cached now, unless it was evicted again in the meantime
+			continue
+		}
+		r := &render{done: make(chan struct{})}
+		c.pending[key] = r
+		c.mu.Unlock()
+
+		var e Entry
+		var f File
+		e, f, r.err = c.generate(key, src)
+		c.mu.Lock()
+		delete(c.pending, key)
+		c.mu.Unlock()
+		close(r.done)
+		return e, f, r.err
+	}
+}
+
+func (c *Cache) generate(key string, src Source) (Entry, File, error) {
+	defer src.Clean()
+	content, err := src.Generate()
+	if err != nil {
+		return Entry{}, nil, err
+	}
+	size, err := c.store.Put(key, content)
+	content.Close()
+	if err != nil {
+		return Entry{}, nil, fmt.Errorf("error caching report: %v", err)
+	}
+
+	e := &Entry{Key: key, Title: src.Title(), Size: size, Created: time.Now()}
+	if p, ok := src.(Partial); ok && !p.Complete() {
+		log.Println("Not caching incomplete report:", key)
+		//open files and readers outlive the deleted content
+		f, err := c.store.Open(key)
+		c.store.Delete(key)
+		if err != nil {
+			return Entry{}, nil, err
+		}
+		return *e, f, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	c.entries[key] = c.lru.PushFront(e)
+	c.size += size
+	c.evict()
+
+	f, err := c.store.Open(key)
+	if err != nil {
+		return Entry{}, nil, err
+	}
+	return *e, f, nil
+}
+
+// evict removes the least recently used reports until the cache is within its limit
+func (c *Cache) evict() {
+	for c.limit > 0 && c.size > c.limit && c.lru.Len() > 1 {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *Cache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*Entry)
+	delete(c.entries, e.Key)
+	c.size -= e.Size
+	if err := c.store.Delete(e.Key); err != nil {
+		log.Println("Error evicting cached report:", err)
+	}
+}
+
+// Size returns the total size of the cached reports
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
@@ -0,0 +1,153 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cache
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type mockSource struct {
+	content   string
+	err       error
+	delay     time.Duration
+	generated *int32
+	cleaned   *int32
+}
+
+func newMockSource(content string) mockSource {
+	return mockSource{content: content, generated: new(int32), cleaned: new(int32)}
+}
+
+func (m mockSource) Generate() (io.ReadCloser, error) {
+	atomic.AddInt32(m.generated, 1)
+	time.Sleep(m.delay)
+	if m.err != nil {
+		return nil, m.err
+	}
+	return ioutil.NopCloser(strings.NewReader(m.content)), nil
+}
+
+func (m mockSource) Title() string { return "title" }
+
+func (m mockSource) Clean() { atomic.AddInt32(m.cleaned, 1) }
+
+type partialSource struct {
+	mockSource
+}
+
+func (partialSource) Complete() bool { return false }
+
+func read(f File) string {
+	defer f.Close()
+	b, _ := ioutil.ReadAll(f)
+	return string(b)
+}
+
+func TestCache(t *testing.T) {
+	Convey("When caching reports", t, func() {
+		c := New(NewMemoryStore(), 10)
+		src := newMockSource("12345")
+
+		Convey("The first request should generate the report", func() {
+			e, f, err := c.Do("a", src)
+			So(err, ShouldBeNil)
+			So(read(f), ShouldEqual, "12345")
+			So(e.Title, ShouldEqual, "title")
+			So(e.Size, ShouldEqual, 5)
+			So(*src.generated, ShouldEqual, 1)
+			So(*src.cleaned, ShouldEqual, 1)
+
+			Convey("Later requests should be served from the cache", func() {
+				again := newMockSource("other")
+				e2, f, err := c.Do("a", again)
+				So(err, ShouldBeNil)
+				So(read(f), ShouldEqual, "12345")
+				So(e2.Created, ShouldEqual, e.Created)
+				So(*again.generated, ShouldEqual, 0)
+				So(*again.cleaned, ShouldEqual, 1)
+			})
+		})
+
+		Convey("Concurrent requests should share a single render", func() {
+			src.delay = 50 * time.Millisecond
+			var wg sync.WaitGroup
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, f, err := c.Do("a", src)
+					if err == nil {
+						read(f)
+					}
+				}()
+			}
+			wg.Wait()
+			So(*src.generated, ShouldEqual, 1)
+			So(*src.cleaned, ShouldEqual, 5)
+		})
+
+		Convey("Errors should be returned and not cached", func() {
+			src.err = errors.New("render failed")
+			_, _, err := c.Do("a", src)
+			So(err, ShouldEqual, src.err)
+			_, _, ok := c.Get("a")
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("Incomplete reports should be returned but not cached", func() {
+			_, f, err := c.Do("a", partialSource{src})
+			So(err, ShouldBeNil)
+			So(read(f), ShouldEqual, "12345")
+			_, _, ok := c.Get("a")
+			So(ok, ShouldBeFalse)
+			So(c.Size(), ShouldEqual, 0)
+		})
+
+		Convey("The least recently used reports should be evicted beyond the size limit", func() {
+			c.Do("a", newMockSource("1234"))
+			c.Do("b", newMockSource("1234"))
+			_, f, _ := c.Get("a")
+			f.Close()
+			c.Do("c", newMockSource("1234"))
+			_, _, ok := c.Get("b")
+			So(ok, ShouldBeFalse)
+			_, _, ok = c.Get("a")
+			So(ok, ShouldBeTrue)
+			So(c.Size(), ShouldEqual, 8)
+		})
+
+		Convey("A report larger than the limit should be kept until the next one", func() {
+			_, f, err := c.Do("big", newMockSource("0123456789ab"))
+			So(err, ShouldBeNil)
+			So(read(f), ShouldEqual, "0123456789ab")
+			_, _, ok := c.Get("big")
+			So(ok, ShouldBeTrue)
+			c.Do("small", newMockSource("1"))
+			_, _, ok = c.Get("big")
+			So(ok, ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,163 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cache
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// File is the content of a cached report
+type File interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+// Store holds the content of cached reports by key
+type Store interface {
+	// Put stores the content read from r under key and returns its size
+	Put(key string, r io.Reader) (int64, error)
+	// Open returns the content stored under key
+	Open(key string) (File, error)
+	// Delete removes the content stored under key
+	Delete(key string) error
+}
+
+type memoryStore struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+// NewMemoryStore returns a Store that keeps reports in memory
+func NewMemoryStore() Store {
+	return &memoryStore{files: map[string][]byte{}}
+}
+
+type memoryFile struct {
+	*bytes.Reader
+}
+
+func (memoryFile) Close() error { return nil }
+
+func (s *memoryStore) Put(key string, r io.Reader) (int64, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return 0, fmt.Errorf("error reading report: %v", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[key] = b
+	return int64(len(b)), nil
+}
+
+func (s *memoryStore) Open(key string) (File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.files[key]
+	if !ok {
+		return nil, fmt.Errorf("report %v is not cached", key)
+	}
+	return memoryFile{bytes.NewReader(b)}, nil
+}
+
+func (s *memoryStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, key)
+	return nil
+}
+
+type diskStore struct {
+	dir string
+}
+
+// diskStoreMarker marks the directories created by NewDiskStore, which it may empty
+const diskStoreMarker = ".grafana-reporter-cache"
+
+// NewDiskStore returns a Store that keeps reports as files in dir.
+// The directory is emptied first, as the cache does not know about the reports of previous runs.
+// To avoid deleting other files, dir must be missing, empty, or a directory created by NewDiskStore.
+func NewDiskStore(dir string) (Store, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error reading cache directory %v: %v", dir, err)
+	}
+	if len(entries) > 0 {
+		if _, err := os.Stat(filepath.Join(dir, diskStoreMarker)); err != nil {
+			return nil, fmt.Errorf("cache directory %v is not empty and was not created by the cache, refusing to empty it", dir)
+		}
+	}
+	for _, e := range entries {
+		if e.Name() == diskStoreMarker {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(dir, e.Name())); err != nil {
+			return nil, fmt.Errorf("error emptying cache directory %v: %v", dir, err)
+		}
+	}
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return nil, fmt.Errorf("error creating cache directory %v: %v", dir, err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, diskStoreMarker), nil, 0666); err != nil {
+		return nil, fmt.Errorf("error marking cache directory %v: %v", dir, err)
+	}
+	return diskStore{dir}, nil
+}
+
+func (s diskStore) path(key string) string {
+	return filepath.Join(s.dir, filepath.Base(key))
+}
+
+// Put writes to a temporary file first, so that a failed write does not leave a partial report behind
+func (s diskStore) Put(key string, r io.Reader) (int64, error) {
+	tmp, err := ioutil.TempFile(s.dir, ".tmp-")
+	if err != nil {
+		return 0, fmt.Errorf("error creating cache file: %v", err)
+	}
+	size, err := io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), s.path(key))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, fmt.Errorf("error writing cache file: %v", err)
+	}
+	return size, nil
+}
+
+func (s diskStore) Open(key string) (File, error) {
+	f, err := os.Open(s.path(key))
+	if err != nil {
+		return nil, fmt.Errorf("error opening cache file: %v", err)
+	}
+	return f, nil
+}
+
+func (s diskStore) Delete(key string) error {
+	if err := os.Remove(s.path(key)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error removing cache file: %v", err)
+	}
+	return nil
+}
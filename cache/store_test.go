@@ -0,0 +1,89 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStores(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, newStore := range map[string]func() (Store, error){
+		"memory": func() (Store, error) { return NewMemoryStore(), nil },
+		"disk":   func() (Store, error) { return NewDiskStore(filepath.Join(dir, "cache")) },
+	} {
+		Convey("When using the "+name+" store", t, func() {
+			s, err := newStore()
+			So(err, ShouldBeNil)
+
+			Convey("It should return what was put", func() {
+				size, err := s.Put("key", strings.NewReader("report"))
+				So(err, ShouldBeNil)
+				So(size, ShouldEqual, 6)
+				f, err := s.Open("key")
+				So(err, ShouldBeNil)
+				defer f.Close()
+				b, _ := ioutil.ReadAll(f)
+				So(string(b), ShouldEqual, "report")
+			})
+
+			Convey("It should return an error for keys that were not put or were deleted", func() {
+				_, err := s.Open("missing")
+				So(err, ShouldNotBeNil)
+				s.Put("key", strings.NewReader("report"))
+				So(s.Delete("key"), ShouldBeNil)
+				_, err = s.Open("key")
+				So(err, ShouldNotBeNil)
+			})
+		})
+	}
+
+	Convey("When creating a disk store", t, func() {
+		cacheDir := filepath.Join(dir, "old")
+		_, err := NewDiskStore(cacheDir)
+		So(err, ShouldBeNil)
+		ioutil.WriteFile(filepath.Join(cacheDir, "stale"), []byte("old report"), 0666)
+		_, err = NewDiskStore(cacheDir)
+		So(err, ShouldBeNil)
+
+		Convey("It should remove the reports of previous runs", func() {
+			_, err := os.Stat(filepath.Join(cacheDir, "stale"))
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+
+		Convey("It should refuse to empty a directory that it did not create", func() {
+			other := filepath.Join(dir, "data")
+			os.MkdirAll(other, 0777)
+			ioutil.WriteFile(filepath.Join(other, "important"), []byte("data"), 0666)
+			_, err := NewDiskStore(other)
+			So(err, ShouldNotBeNil)
+			_, err = os.Stat(filepath.Join(other, "important"))
+			So(err, ShouldBeNil)
+		})
+	})
+}
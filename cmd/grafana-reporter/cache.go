@@ -0,0 +1,95 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/IzakMarais/reporter/cache"
+	"github.com/IzakMarais/reporter/report"
+)
+
+// reportCache holds generated reports. Reports are not cached if it is nil.
+var reportCache *cache.Cache
+
+// newReportCache creates the cache selected by the -cache flag
+func newReportCache() (*cache.Cache, error) {
+	limit := *cacheSize << 20
+	switch *cacheStore {
+	case "":
+		return nil, nil
+	case "memory":
+		log.Printf("Caching up to %d MB of reports in memory", *cacheSize)
+		return cache.New(cache.NewMemoryStore(), limit), nil
+	case "disk":
+		dir := *cacheDir
+		if dir == "" {
+			dir = filepath.Join(*workDir, "cache")
+		}
+		store, err := cache.NewDiskStore(dir)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Caching up to %d MB of reports in '%s'", *cacheSize, dir)
+		return cache.New(store, limit), nil
+	}
+	return nil, fmt.Errorf("unknown cache %q, expected memory or disk", *cacheStore)
+}
+
+// serveCached serves a report from the cache, generating it first if it is not cached.
+// The cache key is the report's ETag, so a client that already has the report gets 304 Not Modified without it being generated.
+func serveCached(w http.ResponseWriter, req *http.Request, rep report.Report, opts report.Options) {
+	key, err := rep.CacheKey()
+	if err != nil {
+		rep.Clean()
+		writeReportError(w, err)
+		return
+	}
+	etag := `"` + key + `"`
+	w.Header().Set("ETag", etag)
+	if etagMatches(req.Header.Get("If-None-Match"), etag) {
+		log.Println("Report not modified:", key)
+		rep.Clean()
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	entry, file, err := reportCache.Do(key, rep)
+	if err != nil {
+		writeReportError(w, err)
+		return
+	}
+	defer file.Close()
+	addFilenameHeader(w, entry.Title, report.FileExtension(opts.Format))
+	w.Header().Set("Content-Type", report.ContentType(opts.Format))
+	http.ServeContent(w, req, "", entry.Created, file)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == etag || t == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/IzakMarais/reporter/cache"
+	"github.com/IzakMarais/reporter/grafana"
+	"github.com/IzakMarais/reporter/report"
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type countingReport struct {
+	mockReport
+	generated *int
+}
+
+func (m countingReport) Generate() (io.ReadCloser, error) {
+	*m.generated++
+	return ioutil.NopCloser(strings.NewReader("report")), nil
+}
+
+func TestReportCache(t *testing.T) {
+	Convey("When the report cache is enabled", t, func() {
+		reportCache = cache.New(cache.NewMemoryStore(), 0)
+		defer func() { reportCache = nil }()
+
+		generated := 0
+		newGrafanaClient := func(url string, apiToken string, variables url.Values, sslCheck bool, gridLayout bool) grafana.Client {
+			return nil
+		}
+		newReport := func(g grafana.Client, dashName string, _ grafana.TimeRange, _ report.Options) report.Report {
+			return countingReport{generated: &generated}
+		}
+		router := mux.NewRouter()
+		RegisterHandlers(router, ServeReportHandler{nil, nil}, ServeReportHandler{newGrafanaClient, newReport})
+		get := func(etag string) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/api/v5/report/testDash?from=1453206447000&to=1453213647000", nil)
+			if etag != "" {
+				req.Header.Set("If-None-Match", etag)
+			}
+			router.ServeHTTP(rec, req)
+			return rec
+		}
+
+		first := get("")
+
+		Convey("It should serve the report with an ETag and Last-Modified", func() {
+			So(first.Code, ShouldEqual, 200)
+			So(first.Body.String(), ShouldEqual, "report")
+			So(first.Header().Get("ETag"), ShouldEqual, `"key"`)
+			So(first.Header().Get("Last-Modified"), ShouldNotBeEmpty)
+			So(first.Header().Get("Content-Type"), ShouldEqual, "application/pdf")
+		})
+
+		Convey("It should serve repeated requests from the cache", func() {
+			rec := get("")
+			So(rec.Code, ShouldEqual, 200)
+			So(rec.Body.String(), ShouldEqual, "report")
+			So(generated, ShouldEqual, 1)
+		})
+
+		Convey("It should answer a matching If-None-Match with 304 Not Modified", func() {
+			rec := get(`"other", "key"`)
+			So(rec.Code, ShouldEqual, http.StatusNotModified)
+			So(rec.Body.Len(), ShouldEqual, 0)
+			So(generated, ShouldEqual, 1)
+		})
+
+		Convey("It should serve the report if If-None-Match does not match", func() {
+			rec := get(`"other"`)
+			So(rec.Code, ShouldEqual, 200)
+			So(rec.Body.String(), ShouldEqual, "report")
+		})
+	})
+}
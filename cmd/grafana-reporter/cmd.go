@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strings"
 )
 
 type responseWriter struct {
@@ -39,6 +40,30 @@ func cmdHandler(router *mux.Router) error {
 		rqStr += "&template=" + *template
 	}
 
+	if format != nil && *format != "" {
+		rqStr += "&format=" + *format
+	}
+
+	if *cmdStrict {
+		rqStr += "&strict=true"
+	}
+
+	if *cmdTOC {
+		rqStr += "&toc=true"
+	}
+
+	if *cmdRowBreak {
+		rqStr += "&rowbreak=true"
+	}
+
+	if *cmdFilter != "" {
+		rqStr += "&" + strings.Replace(*cmdFilter, "%", "%%", -1)
+	}
+
+	if *cmdRepeatBy != "" {
+		rqStr += "&repeatBy=" + *cmdRepeatBy
+	}
+
 	rq, err := http.NewRequest("GET", fmt.Sprintf(rqStr, *dashboard, *apiKey, *timeSpan), nil)
 	if err != nil {
 		return err
@@ -17,6 +17,8 @@
 package main
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -24,6 +26,7 @@ import (
 	"net/http"
 	"net/url"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -32,10 +35,25 @@ import (
 	"github.com/gorilla/mux"
 )
 
+// renderScheduler limits the concurrent panel renders of all reports. The report package's default is used if it is nil.
+var renderScheduler *report.Scheduler
+
+// reportBranding is the branding of all reports, loaded from the -branding file
+var reportBranding report.BrandingConfig
+
+// reportWorkspace is the work directory of all reports
+var reportWorkspace = report.NewWorkspace(report.DefaultWorkDir, 0)
+
+// newCompositeReport creates composite reports, replaced in tests
+var newCompositeReport = report.NewComposite
+
+// newRepeatedReport creates reports repeated by a template variable, replaced in tests
+var newRepeatedReport = report.NewRepeated
+
 // ServeReportHandler interface facilitates testsing the reportServing http handler
 type ServeReportHandler struct {
 	newGrafanaClient func(url string, apiToken string, variables url.Values, sslCheck bool, gridLayout bool) grafana.Client
-	newReport        func(g grafana.Client, dashName string, time grafana.TimeRange, texTemplate string, gridLayout bool) report.Report
+	newReport        func(g grafana.Client, dashName string, time grafana.TimeRange, opts report.Options) report.Report
 }
 
 // RegisterHandlers registers all http.Handler's with their associated routes to the router
@@ -43,26 +61,69 @@ type ServeReportHandler struct {
 func RegisterHandlers(router *mux.Router, reportServerV4, reportServerV5 ServeReportHandler) {
 	router.Handle("/api/report/{dashId}", reportServerV4)
 	router.Handle("/api/v5/report/{dashId}", reportServerV5)
+	router.Handle("/api/v5/report", reportServerV5)
+	router.HandleFunc("/api/diagnostics/{id}/{file}", serveDiagnostics)
+	router.HandleFunc("/api/template/check", serveTemplateCheck)
+	router.HandleFunc("/api/jobs/{id}", serveJob)
+	router.HandleFunc("/api/jobs/{id}/result", serveJobResult)
+	router.HandleFunc("/api/jobs/{id}/events", serveJobEvents)
+	router.HandleFunc("/debug/vars", serveRenderQueue)
 	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, "This is grafana-reporter. \nThe API endpoints are documented here: https://github.com/IzakMarais/reporter#endpoint.")
 	})
 
 }
 
+// serveRenderQueue serves the render queue in the JSON format of expvar. The other expvar variables,
+// such as the command line and memory statistics, are not published on the report port.
+func serveRenderQueue(w http.ResponseWriter, r *http.Request) {
+	stats := map[string]report.RenderStats{}
+	if renderScheduler != nil {
+		stats = renderScheduler.Stats()
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{"renderQueue": stats})
+}
+
 func (h ServeReportHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	log.Print("Reporter called")
-	g := h.newGrafanaClient(*proto+*ip, apiToken(req), dashVariables(req), *sslCheck, *gridLayout)
-	rep := h.newReport(g, dashID(req), time(req), texTemplate(req), *gridLayout)
+	if req.Method == http.MethodPost {
+		h.startJob(w, req)
+		return
+	}
+	if _, err := panelFilter(req); err != nil {
+		writeReportError(w, err)
+		return
+	}
+	if _, _, err := comparison(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	parts, err := h.compositeParts(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts := reportOptions(req)
+	if err := validateOptions(req, parts, opts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rep := h.requestReport(req, parts, opts)
+	if reportCache != nil {
+		serveCached(w, req, rep, opts)
+		return
+	}
 
+	defer rep.Clean()
 	file, err := rep.Generate()
 	if err != nil {
-		log.Println("Error generating report:", err)
-		http.Error(w, err.Error(), 500)
+		writeReportError(w, err)
 		return
 	}
-	defer rep.Clean()
 	defer file.Close()
-	addFilenameHeader(w, rep.Title())
+	addFilenameHeader(w, rep.Title(), report.FileExtension(opts.Format))
+	w.Header().Set("Content-Type", report.ContentType(opts.Format))
 
 	_, err = io.Copy(w, file)
 	if err != nil {
@@ -73,17 +134,153 @@ func (h ServeReportHandler) ServeHTTP(w http.ResponseWriter, req *http.Request)
 	log.Println("Report generated correctly")
 }
 
-func addFilenameHeader(w http.ResponseWriter, title string) {
+func writeReportError(w http.ResponseWriter, err error) {
+	log.Println("Error generating report:", err)
+	if latexErr, ok := err.(*report.LaTeXError); ok {
+		writeLaTeXError(w, latexErr)
+		return
+	}
+	if _, ok := err.(*report.QuotaError); ok {
+		http.Error(w, err.Error(), http.StatusInsufficientStorage)
+		return
+	}
+	switch err.(type) {
+	case *report.FilterError, *report.OptionsError:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, err.Error(), 500)
+}
+
+// latexErrorResponse is the JSON body returned when a report fails to compile
+type latexErrorResponse struct {
+	Error string `json:"error"`
+	*report.LaTeXError
+	TeXURL string `json:"texUrl,omitempty"`
+	LogURL string `json:"logUrl,omitempty"`
+}
+
+func writeLaTeXError(w http.ResponseWriter, latexErr *report.LaTeXError) {
+	resp := latexErrorResponse{Error: latexErr.Error(), LaTeXError: latexErr}
+	if latexErr.ID != "" {
+		resp.TeXURL = "/api/diagnostics/" + latexErr.ID + "/report.tex"
+		resp.LogURL = "/api/diagnostics/" + latexErr.ID + "/report.log"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(500)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Println("Error writing LaTeX error response:", err)
+	}
+}
+
+// serveDiagnostics serves the TeX source or log kept from a failed LaTeX run
+func serveDiagnostics(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	path, err := reportWorkspace.DiagnosticsFile(vars["id"], vars["file"])
+	if err != nil {
+		log.Println("Error serving diagnostics:", err)
+		http.Error(w, err.Error(), 404)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	http.ServeFile(w, r, path)
+}
+
+func addFilenameHeader(w http.ResponseWriter, title string, ext string) {
 	//sanitize title. Http headers should be ASCII
 	filename := strconv.QuoteToASCII(title)
 	filename = strings.TrimLeft(filename, "\"")
 	filename = strings.TrimRight(filename, "\"")
-	filename += ".pdf"
+	filename += ext
 	log.Println("Extracted filename from dashboard title: ", filename)
 	header := fmt.Sprintf("inline; filename=\"%s\"", filename)
 	w.Header().Add("Content-Disposition", header)
 }
 
+// validateOptions checks the options of the requested report before it is created, so that invalid ones are refused
+// with 400 Bad Request rather than failing once the report is generated
+func validateOptions(r *http.Request, parts []report.Part, opts report.Options) error {
+	if len(parts) > 0 {
+		return opts.ValidateComposite()
+	}
+	if r.URL.Query().Get("repeatBy") != "" {
+		return opts.ValidateRepeated()
+	}
+	return opts.Validate()
+}
+
+// requestReport creates the report of the dashboard in the URL, or a composite report of parts if there are any.
+// With a repeatBy parameter, the report of the dashboard has a chapter per value of that variable.
+func (h ServeReportHandler) requestReport(r *http.Request, parts []report.Part, opts report.Options) report.Report {
+	if len(parts) > 0 {
+		return newCompositeReport(compositeTitle(r), timeRange(r), parts, opts)
+	}
+	if repeatBy := r.URL.Query().Get("repeatBy"); repeatBy != "" {
+		newClient := func(variables url.Values) grafana.Client {
+			return h.newGrafanaClient(*proto+*ip, apiToken(r), variables, *sslCheck, *gridLayout)
+		}
+		return newRepeatedReport(newClient, dashID(r), repeatBy, dashVariables(r), timeRange(r), opts)
+	}
+	g := h.newGrafanaClient(*proto+*ip, apiToken(r), dashVariables(r), *sslCheck, *gridLayout)
+	return h.newReport(g, dashID(r), timeRange(r), opts)
+}
+
+// compositeParts returns the dashboards of a composite report, requested without a dashboard in the URL and with
+// repeated dashboard parameters instead. Each is a dashboard UID, optionally followed by ? and the variables and time range
+// of that dashboard, e.g. db?var-host=db1&from=now-7d, which override those of the request.
+// It returns nil if there is a dashboard in the URL.
+func (h ServeReportHandler) compositeParts(r *http.Request) ([]report.Part, error) {
+	if dashID(r) != "" {
+		return nil, nil
+	}
+	if r.URL.Query().Get("repeatBy") != "" {
+		return nil, fmt.Errorf("repeatBy needs a dashboard in the URL, it cannot be used in composite reports")
+	}
+	dashboards := r.URL.Query()["dashboard"]
+	if len(dashboards) == 0 {
+		return nil, fmt.Errorf("a composite report needs at least one dashboard parameter")
+	}
+	var parts []report.Part
+	for _, d := range dashboards {
+		uid, query := d, ""
+		if i := strings.Index(d, "?"); i >= 0 {
+			uid, query = d[:i], d[i+1:]
+		}
+		params, err := url.ParseQuery(query)
+		if err != nil || uid == "" {
+			return nil, fmt.Errorf("invalid dashboard parameter %q, expected a dashboard UID optionally followed by ? and its variables and time range", d)
+		}
+		variables := dashVariables(r)
+		for k, v := range params {
+			if strings.HasPrefix(k, "var-") {
+				variables[k] = v
+			}
+		}
+		t := timeRange(r)
+		if from := params.Get("from"); from != "" {
+			t.From = from
+		}
+		if to := params.Get("to"); to != "" {
+			t.To = to
+		}
+		g := h.newGrafanaClient(*proto+*ip, apiToken(r), variables, *sslCheck, *gridLayout)
+		parts = append(parts, report.Part{Client: g, DashName: uid, Time: t})
+	}
+	return parts, nil
+}
+
+// compositeTitle returns the title of a composite report: the title parameter, or else its dashboard UIDs
+func compositeTitle(r *http.Request) string {
+	if title := r.URL.Query().Get("title"); title != "" {
+		return title
+	}
+	var uids []string
+	for _, d := range r.URL.Query()["dashboard"] {
+		uids = append(uids, strings.SplitN(d, "?", 2)[0])
+	}
+	return strings.Join(uids, ", ")
+}
+
 func dashID(r *http.Request) string {
 	vars := mux.Vars(r)
 	d := vars["dashId"]
@@ -91,7 +288,7 @@ func dashID(r *http.Request) string {
 	return d
 }
 
-func time(r *http.Request) grafana.TimeRange {
+func timeRange(r *http.Request) grafana.TimeRange {
 	params := r.URL.Query()
 	t := grafana.NewTimeRange(params.Get("from"), params.Get("to"))
 	log.Println("Called with time range:", t)
@@ -120,12 +317,201 @@ func dashVariables(r *http.Request) url.Values {
 	return output
 }
 
-func texTemplate(r *http.Request) string {
+func reportOptions(r *http.Request) report.Options {
+	format := outputFormat(r)
+	filter, _ := panelFilter(r) //validated before the report is created
+	compare, shifts, _ := comparison(r)
+	return report.Options{
+		Template:    customTemplate(r, format),
+		TemplateDir: *templateDir,
+		GridLayout:  *gridLayout,
+		Backend:     pdfBackend(r),
+		Format:      format,
+		Slides:      r.URL.Query().Get("slides"),
+		Engine:      engine(r),
+		FontsDir:    *fontsDir,
+		MainFont:    *mainFont,
+		PublicURL:   grafanaPublicURL(),
+		User:        requestUser(r),
+		Meta:        metaParams(r),
+		Version:     version(),
+		TOC:         r.URL.Query().Get("toc") == "true",
+		RowBreak:    r.URL.Query().Get("rowbreak") == "true",
+		Paper:       optionParam(r, "paper", *paper),
+		Orientation: optionParam(r, "orientation", *orientation),
+		Margin:      optionParam(r, "margin", *margin),
+		Locale:      optionParam(r, "locale", *locale),
+		Branding:    reportBranding,
+		Strict:      r.URL.Query().Get("strict") == "true",
+		Filter:      filter,
+
+		Compare:       compare,
+		CompareShift:  shifts,
+		CompareLayout: r.URL.Query().Get("compareLayout"),
+
+		Scheduler:            renderScheduler,
+		Workspace:            reportWorkspace,
+		DiagnosticsRetention: *diagnosticsRetention,
+	}
+}
+
+func grafanaPublicURL() string {
+	if *publicURL != "" {
+		return *publicURL
+	}
+	return *proto + *ip
+}
+
+// requestUser returns the user named in the -user-header header, as set by an authenticating proxy,
+// or otherwise the name of the Grafana API key in the request
+func requestUser(r *http.Request) string {
+	if *userHeader != "" {
+		if user := r.Header.Get(*userHeader); user != "" {
+			return user
+		}
+	}
+	return apiKeyName(r.URL.Query().Get("apitoken"))
+}
+
+// apiKeyName decodes the name of a Grafana API key. Grafana API keys are base64 encoded JSON objects,
+// holding the key name as "n". It returns an empty string if the key cannot be decoded.
+func apiKeyName(apiToken string) string {
+	decoded, err := base64.StdEncoding.DecodeString(apiToken)
+	if err != nil {
+		return ""
+	}
+	var key struct {
+		N string `json:"n"`
+	}
+	if err = json.Unmarshal(decoded, &key); err != nil {
+		return ""
+	}
+	return key.N
+}
+
+// metaParams returns the meta-* query parameters by name without the prefix
+func metaParams(r *http.Request) map[string]string {
+	meta := map[string]string{}
+	for k, v := range r.URL.Query() {
+		if strings.HasPrefix(k, "meta-") && len(v) > 0 {
+			meta[strings.TrimPrefix(k, "meta-")] = v[0]
+		}
+	}
+	return meta
+}
+
+func engine(r *http.Request) string {
+	e := r.URL.Query().Get("engine")
+	if e == "" {
+		e = *texEngine
+	}
+	log.Println("Called with TeX engine:", e)
+	return e
+}
+
+// panelFilter returns the panel filters in the panelId, excludePanelId, panelTitle, panelType and row query parameters.
+// Ids and types can be repeated or separated by commas.
+func panelFilter(r *http.Request) (report.PanelFilter, error) {
+	q := r.URL.Query()
+	f := report.PanelFilter{Title: q.Get("panelTitle"), Types: splitParams(q["panelType"]), Rows: q["row"]}
+	var err error
+	if f.IDs, err = panelIDs(q["panelId"]); err != nil {
+		return f, err
+	}
+	if f.ExcludeIDs, err = panelIDs(q["excludePanelId"]); err != nil {
+		return f, err
+	}
+	if _, err = regexp.Compile(f.Title); err != nil {
+		return f, &report.FilterError{Reason: fmt.Sprintf("error parsing panelTitle %q: %v", f.Title, err)}
+	}
+	return f, nil
+}
+
+func panelIDs(params []string) ([]int, error) {
+	var ids []int
+	for _, s := range splitParams(params) {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, &report.FilterError{Reason: fmt.Sprintf("invalid panel id %q", s)}
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// splitParams splits the comma separated values of repeated query parameters
+func splitParams(params []string) []string {
+	var values []string
+	for _, p := range params {
+		for _, v := range strings.Split(p, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+// comparison returns the periods of a comparison report: the time ranges of the compare parameters, each a from and
+// to time separated by a comma, and the time shifts of the compareShift parameters, which can be repeated or separated by commas
+func comparison(r *http.Request) ([]grafana.TimeRange, []string, error) {
+	q := r.URL.Query()
+	var ranges []grafana.TimeRange
+	for _, c := range q["compare"] {
+		t := strings.Split(c, ",")
+		if len(t) != 2 || strings.TrimSpace(t[0]) == "" || strings.TrimSpace(t[1]) == "" {
+			return nil, nil, fmt.Errorf("invalid compare parameter %q, expected a from and to time separated by a comma", c)
+		}
+		ranges = append(ranges, grafana.NewTimeRange(strings.TrimSpace(t[0]), strings.TrimSpace(t[1])))
+	}
+	shifts := splitParams(q["compareShift"])
+	for _, s := range shifts {
+		if err := grafana.ValidateShift(s); err != nil {
+			return nil, nil, err
+		}
+	}
+	switch l := q.Get("compareLayout"); l {
+	case "", report.CompareSideBySide, report.CompareStacked:
+	default:
+		return nil, nil, fmt.Errorf("unknown compareLayout %q, expected %q or %q", l, report.CompareSideBySide, report.CompareStacked)
+	}
+	return ranges, shifts, nil
+}
+
+// optionParam returns the option in the named query parameter, or def, the default set with a flag, if it is not set
+func optionParam(r *http.Request, name, def string) string {
+	if o := r.URL.Query().Get(name); o != "" {
+		return o
+	}
+	return def
+}
+
+func outputFormat(r *http.Request) string {
+	f := r.URL.Query().Get("format")
+	if f == "" {
+		f = report.FormatPDF
+	}
+	log.Println("Called with format:", f)
+	return f
+}
+
+func pdfBackend(r *http.Request) string {
+	b := r.URL.Query().Get("backend")
+	if b == "" {
+		b = *backend
+	}
+	log.Println("Called with backend:", b)
+	return b
+}
+
+// customTemplate reads the template named in the request from the templates directory.
+// The file extension depends on the output format: .tex, or .html and .pptx for HTML and PPTX reports.
+func customTemplate(r *http.Request, format string) string {
 	fName := r.URL.Query().Get("template")
 	if fName == "" {
 		return ""
 	}
-	file := filepath.Join(*templateDir, fName+".tex")
+	file := filepath.Join(*templateDir, fName+report.TemplateExtension(format))
 	log.Println("Called with template:", file)
 
 	customTemplate, err := ioutil.ReadFile(file)
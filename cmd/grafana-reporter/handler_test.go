@@ -18,6 +18,8 @@ package main
 
 import (
 	"bytes"
+	"encoding/base64"
+	"encoding/json"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -42,6 +44,230 @@ func (m mockReport) Clean() {}
 
 func (m mockReport) Title() string { return "title" }
 
+func (m mockReport) CacheKey() (string, error) { return "key", nil }
+
+type errReport struct {
+	mockReport
+	err error
+}
+
+func (m errReport) Generate() (pdf io.ReadCloser, err error) {
+	return nil, m.err
+}
+
+func TestLaTeXErrorResponse(t *testing.T) {
+	Convey("When a report fails to compile", t, func() {
+		latexErr := &report.LaTeXError{Message: "Undefined control sequence.", TeXLine: 9, TemplateLine: 8, ID: "1234"}
+		newReport := func(g grafana.Client, dashName string, _ grafana.TimeRange, opts report.Options) report.Report {
+			return errReport{err: latexErr}
+		}
+		newGrafanaClient := func(url string, apiToken string, variables url.Values, sslCheck bool, gridLayout bool) grafana.Client {
+			return nil
+		}
+		router := mux.NewRouter()
+		RegisterHandlers(router, ServeReportHandler{nil, nil}, ServeReportHandler{newGrafanaClient, newReport})
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/v5/report/testDash", nil)
+		router.ServeHTTP(rec, req)
+
+		Convey("It should return the error as JSON with links to the kept files", func() {
+			So(rec.Code, ShouldEqual, 500)
+			So(rec.Header().Get("Content-Type"), ShouldEqual, "application/json")
+			var resp map[string]interface{}
+			So(json.Unmarshal(rec.Body.Bytes(), &resp), ShouldBeNil)
+			So(resp["error"], ShouldEqual, latexErr.Error())
+			So(resp["message"], ShouldEqual, "Undefined control sequence.")
+			So(resp["texLine"], ShouldEqual, 9)
+			So(resp["templateLine"], ShouldEqual, 8)
+			So(resp["texUrl"], ShouldEqual, "/api/diagnostics/1234/report.tex")
+			So(resp["logUrl"], ShouldEqual, "/api/diagnostics/1234/report.log")
+		})
+
+		Convey("Unknown diagnostics files should not be found", func() {
+			rec := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/api/diagnostics/1234/report.tex", nil)
+			router.ServeHTTP(rec, req)
+			So(rec.Code, ShouldEqual, 404)
+		})
+	})
+}
+
+func TestQuotaErrorResponse(t *testing.T) {
+	Convey("When the work directory is full", t, func() {
+		newReport := func(g grafana.Client, dashName string, _ grafana.TimeRange, opts report.Options) report.Report {
+			return errReport{err: &report.QuotaError{Root: "tmp", Used: 2 << 20, Quota: 1 << 20}}
+		}
+		newGrafanaClient := func(url string, apiToken string, variables url.Values, sslCheck bool, gridLayout bool) grafana.Client {
+			return nil
+		}
+		router := mux.NewRouter()
+		RegisterHandlers(router, ServeReportHandler{nil, nil}, ServeReportHandler{newGrafanaClient, newReport})
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/v5/report/testDash", nil)
+		router.ServeHTTP(rec, req)
+
+		Convey("It should refuse the report with 507 Insufficient Storage", func() {
+			So(rec.Code, ShouldEqual, http.StatusInsufficientStorage)
+			So(rec.Body.String(), ShouldContainSubstring, "work directory tmp is full: 2.0 MB used of a 1.0 MB quota")
+		})
+	})
+}
+
+func TestOptionsErrorResponse(t *testing.T) {
+	Convey("When the report options are invalid", t, func() {
+		newReport := func(g grafana.Client, dashName string, _ grafana.TimeRange, opts report.Options) report.Report {
+			return errReport{err: &report.OptionsError{Reason: "unknown report backend"}}
+		}
+		newGrafanaClient := func(url string, apiToken string, variables url.Values, sslCheck bool, gridLayout bool) grafana.Client {
+			return nil
+		}
+		router := mux.NewRouter()
+		RegisterHandlers(router, ServeReportHandler{nil, nil}, ServeReportHandler{newGrafanaClient, newReport})
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/v5/report/testDash", nil)
+		router.ServeHTTP(rec, req)
+
+		Convey("It should refuse the report with 400 Bad Request", func() {
+			So(rec.Code, ShouldEqual, http.StatusBadRequest)
+			So(rec.Body.String(), ShouldContainSubstring, "unknown report backend")
+		})
+	})
+}
+
+func TestRenderQueueHandler(t *testing.T) {
+	Convey("When requesting the runtime metrics", t, func() {
+		router := mux.NewRouter()
+		RegisterHandlers(router, ServeReportHandler{nil, nil}, ServeReportHandler{nil, nil})
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/debug/vars", nil)
+		router.ServeHTTP(rec, req)
+
+		Convey("It should serve only the render queue", func() {
+			So(rec.Code, ShouldEqual, http.StatusOK)
+			var vars map[string]json.RawMessage
+			So(json.Unmarshal(rec.Body.Bytes(), &vars), ShouldBeNil)
+			So(vars, ShouldContainKey, "renderQueue")
+			So(vars, ShouldNotContainKey, "cmdline")
+			So(vars, ShouldNotContainKey, "memstats")
+		})
+	})
+}
+
+func TestCompositeReportHandler(t *testing.T) {
+	Convey("When a composite report of several dashboards is requested", t, func() {
+		var clVars []url.Values
+		newGrafanaClient := func(url string, apiToken string, variables url.Values, sslCheck bool, gridLayout bool) grafana.Client {
+			clVars = append(clVars, variables)
+			return grafana.NewV5Client(url, apiToken, variables, true, false)
+		}
+		var title string
+		var parts []report.Part
+		defer func(f func(string, grafana.TimeRange, []report.Part, report.Options) report.Report) {
+			newCompositeReport = f
+		}(newCompositeReport)
+		newCompositeReport = func(t string, _ grafana.TimeRange, p []report.Part, _ report.Options) report.Report {
+			title, parts = t, p
+			return &mockReport{}
+		}
+		router := mux.NewRouter()
+		RegisterHandlers(router, ServeReportHandler{nil, nil}, ServeReportHandler{newGrafanaClient, nil})
+		rec := httptest.NewRecorder()
+		q := url.Values{"dashboard": {"api", "db?var-host=db1&from=now-7d"}, "var-host": {"all"}, "var-env": {"prod"}, "from": {"now-1d"}}
+		req, _ := http.NewRequest("GET", "/api/v5/report?"+q.Encode(), nil)
+		router.ServeHTTP(rec, req)
+
+		Convey("It should create a part per dashboard, with its own variables and time range", func() {
+			So(rec.Code, ShouldEqual, http.StatusOK)
+			So(parts, ShouldHaveLength, 2)
+			So(parts[0].DashName, ShouldEqual, "api")
+			So(parts[0].Time, ShouldResemble, grafana.TimeRange{From: "now-1d", To: "now"})
+			So(parts[1].DashName, ShouldEqual, "db")
+			So(parts[1].Time, ShouldResemble, grafana.TimeRange{From: "now-7d", To: "now"})
+			So(clVars[0], ShouldResemble, url.Values{"var-host": {"all"}, "var-env": {"prod"}})
+			So(clVars[1], ShouldResemble, url.Values{"var-host": {"db1"}, "var-env": {"prod"}})
+		})
+
+		Convey("It should be titled with the title parameter, or else the dashboard UIDs", func() {
+			So(title, ShouldEqual, "api, db")
+			req, _ := http.NewRequest("GET", "/api/v5/report?dashboard=api&title=Service+review", nil)
+			router.ServeHTTP(httptest.NewRecorder(), req)
+			So(title, ShouldEqual, "Service review")
+		})
+
+		Convey("It should refuse a request without dashboards with 400 Bad Request", func() {
+			rec := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/api/v5/report", nil)
+			router.ServeHTTP(rec, req)
+			So(rec.Code, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("It should refuse other formats and backends with 400 Bad Request, before creating the report", func() {
+			for _, q := range []string{"format=html", "backend=native"} {
+				title = ""
+				rec := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", "/api/v5/report?dashboard=api&title=Other&"+q, nil)
+				router.ServeHTTP(rec, req)
+				So(rec.Code, ShouldEqual, http.StatusBadRequest)
+				So(title, ShouldEqual, "")
+			}
+		})
+	})
+}
+
+func TestRepeatedReportHandler(t *testing.T) {
+	Convey("When a report repeated by a template variable is requested", t, func() {
+		var clVars url.Values
+		newGrafanaClient := func(url string, apiToken string, variables url.Values, sslCheck bool, gridLayout bool) grafana.Client {
+			clVars = variables
+			return grafana.NewV5Client(url, apiToken, variables, true, false)
+		}
+		var dashName, repeatBy string
+		var variables url.Values
+		defer func(f func(func(url.Values) grafana.Client, string, string, url.Values, grafana.TimeRange, report.Options) report.Report) {
+			newRepeatedReport = f
+		}(newRepeatedReport)
+		newRepeatedReport = func(newClient func(url.Values) grafana.Client, d, by string, v url.Values, _ grafana.TimeRange, _ report.Options) report.Report {
+			dashName, repeatBy, variables = d, by, v
+			newClient(url.Values{"var-host": {"db1"}})
+			return &mockReport{}
+		}
+		router := mux.NewRouter()
+		RegisterHandlers(router, ServeReportHandler{nil, nil}, ServeReportHandler{newGrafanaClient, nil})
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/v5/report/hosts?repeatBy=var-host&var-host=db1&var-host=db2", nil)
+		router.ServeHTTP(rec, req)
+
+		Convey("It should forward the dashboard, the variable and its selected values", func() {
+			So(rec.Code, ShouldEqual, http.StatusOK)
+			So(dashName, ShouldEqual, "hosts")
+			So(repeatBy, ShouldEqual, "var-host")
+			So(variables, ShouldResemble, url.Values{"var-host": {"db1", "db2"}})
+		})
+
+		Convey("It should create the Grafana client of each value", func() {
+			So(clVars, ShouldResemble, url.Values{"var-host": {"db1"}})
+		})
+
+		Convey("It should refuse other formats and backends with 400 Bad Request, before creating the report", func() {
+			for _, q := range []string{"format=pptx", "backend=native"} {
+				dashName = ""
+				rec := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", "/api/v5/report/other?repeatBy=var-host&"+q, nil)
+				router.ServeHTTP(rec, req)
+				So(rec.Code, ShouldEqual, http.StatusBadRequest)
+				So(dashName, ShouldEqual, "")
+			}
+		})
+
+		Convey("It should refuse repeatBy in a composite report with 400 Bad Request", func() {
+			rec := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/api/v5/report?dashboard=hosts&repeatBy=var-host", nil)
+			router.ServeHTTP(rec, req)
+			So(rec.Code, ShouldEqual, http.StatusBadRequest)
+		})
+	})
+}
+
 func TestV4ServeReportHandler(t *testing.T) {
 	Convey("When the v4 report server handler is called", t, func() {
 		//mock new grafana client function to capture and validate its input parameters
@@ -54,7 +280,7 @@ func TestV4ServeReportHandler(t *testing.T) {
 		}
 		//mock new report function to capture and validate its input parameters
 		var repDashName string
-		newReport := func(g grafana.Client, dashName string, _ grafana.TimeRange, _ string, _ bool) report.Report {
+		newReport := func(g grafana.Client, dashName string, _ grafana.TimeRange, _ report.Options) report.Report {
 			repDashName = dashName
 			return &mockReport{}
 		}
@@ -105,8 +331,10 @@ func TestV5ServeReportHandler(t *testing.T) {
 		}
 		//mock new report function to capture and validate its input parameters
 		var repDashName string
-		newReport := func(g grafana.Client, dashName string, _ grafana.TimeRange, _ string, _ bool) report.Report {
+		var repOpts report.Options
+		newReport := func(g grafana.Client, dashName string, _ grafana.TimeRange, opts report.Options) report.Report {
 			repDashName = dashName
+			repOpts = opts
 			return &mockReport{}
 		}
 
@@ -126,6 +354,147 @@ func TestV5ServeReportHandler(t *testing.T) {
 			So(clAPIToken, ShouldEqual, "1234")
 		})
 
+		Convey("It should name the report file after the dashboard title and the output format", func() {
+			req, _ := http.NewRequest("GET", "/api/v5/report/testDash", nil)
+			router.ServeHTTP(rec, req)
+			So(rec.Header().Get("Content-Disposition"), ShouldEqual, `inline; filename="title.pdf"`)
+			So(rec.Header().Get("Content-Type"), ShouldEqual, "application/pdf")
+
+			Convey("HTML reports should be served as html files", func() {
+				rec := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", "/api/v5/report/testDash?format=html", nil)
+				router.ServeHTTP(rec, req)
+				So(repOpts.Format, ShouldEqual, "html")
+				So(rec.Header().Get("Content-Disposition"), ShouldEqual, `inline; filename="title.html"`)
+				So(rec.Header().Get("Content-Type"), ShouldStartWith, "text/html")
+			})
+		})
+
+		Convey("It should forward the requesting user and meta parameters to the new reporter", func() {
+			//a Grafana API key for a key named "reports"
+			key := base64.StdEncoding.EncodeToString([]byte(`{"k":"abc","n":"reports","id":1}`))
+			req, _ := http.NewRequest("GET", "/api/v5/report/testDash?apitoken="+key+"&meta-customer=ACME&meta-ref=42", nil)
+			router.ServeHTTP(rec, req)
+			So(repOpts.User, ShouldEqual, "reports")
+			So(repOpts.Meta, ShouldResemble, map[string]string{"customer": "ACME", "ref": "42"})
+			So(repOpts.PublicURL, ShouldEqual, "http://localhost:3000")
+
+			Convey("The user header should be ignored unless it is configured", func() {
+				req.Header.Set("X-WEBAUTH-USER", "alice")
+				router.ServeHTTP(rec, req)
+				So(repOpts.User, ShouldEqual, "reports")
+			})
+
+			Convey("The user header should take precedence over the API key once configured", func() {
+				*userHeader = "X-WEBAUTH-USER"
+				defer func() { *userHeader = "" }()
+				req.Header.Set("X-WEBAUTH-USER", "alice")
+				router.ServeHTTP(rec, req)
+				So(repOpts.User, ShouldEqual, "alice")
+			})
+		})
+
+		Convey("It should forward the TeX engine to the new reporter, defaulting to pdflatex", func() {
+			req, _ := http.NewRequest("GET", "/api/v5/report/testDash", nil)
+			router.ServeHTTP(rec, req)
+			So(repOpts.Engine, ShouldEqual, "pdflatex")
+
+			req, _ = http.NewRequest("GET", "/api/v5/report/testDash?engine=xelatex", nil)
+			router.ServeHTTP(rec, req)
+			So(repOpts.Engine, ShouldEqual, "xelatex")
+		})
+
+		Convey("It should add a table of contents if toc=true", func() {
+			req, _ := http.NewRequest("GET", "/api/v5/report/testDash", nil)
+			router.ServeHTTP(rec, req)
+			So(repOpts.TOC, ShouldBeFalse)
+
+			req, _ = http.NewRequest("GET", "/api/v5/report/testDash?toc=true", nil)
+			router.ServeHTTP(rec, req)
+			So(repOpts.TOC, ShouldBeTrue)
+		})
+
+		Convey("It should start rows on a new page if rowbreak=true", func() {
+			req, _ := http.NewRequest("GET", "/api/v5/report/testDash?rowbreak=true", nil)
+			router.ServeHTTP(rec, req)
+			So(repOpts.RowBreak, ShouldBeTrue)
+		})
+
+		Convey("It should forward the panel filters", func() {
+			req, _ := http.NewRequest("GET", "/api/v5/report/testDash?panelId=1,4&panelId=5&excludePanelId=2&panelTitle=^CPU&panelType=graph&row=Data,base", nil)
+			router.ServeHTTP(rec, req)
+			So(repOpts.Filter, ShouldResemble, report.PanelFilter{
+				IDs: []int{1, 4, 5}, ExcludeIDs: []int{2}, Title: "^CPU", Types: []string{"graph"}, Rows: []string{"Data,base"},
+			})
+		})
+
+		Convey("It should refuse invalid panel filters with 400 Bad Request", func() {
+			for _, q := range []string{"panelId=abc", "excludePanelId=1,x", "panelTitle=("} {
+				rec := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", "/api/v5/report/testDash?"+q, nil)
+				router.ServeHTTP(rec, req)
+				So(rec.Code, ShouldEqual, http.StatusBadRequest)
+			}
+		})
+
+		Convey("It should forward the compared periods", func() {
+			req, _ := http.NewRequest("GET", "/api/v5/report/testDash?compare=now-2d,now-1d&compareShift=1w,4w&compareLayout=stacked", nil)
+			router.ServeHTTP(rec, req)
+			So(repOpts.Compare, ShouldResemble, []grafana.TimeRange{{From: "now-2d", To: "now-1d"}})
+			So(repOpts.CompareShift, ShouldResemble, []string{"1w", "4w"})
+			So(repOpts.CompareLayout, ShouldEqual, report.CompareStacked)
+		})
+
+		Convey("It should refuse invalid compared periods with 400 Bad Request", func() {
+			for _, q := range []string{"compare=now-2d", "compareShift=1s", "compareLayout=diagonal", "compareShift=1w&format=html"} {
+				rec := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", "/api/v5/report/testDash?"+q, nil)
+				router.ServeHTTP(rec, req)
+				So(rec.Code, ShouldEqual, http.StatusBadRequest)
+			}
+		})
+
+		Convey("It should forward the paper options, defaulting to the flags", func() {
+			req, _ := http.NewRequest("GET", "/api/v5/report/testDash", nil)
+			router.ServeHTTP(rec, req)
+			So(repOpts.Paper, ShouldEqual, "")
+			So(repOpts.Orientation, ShouldEqual, report.OrientationPortrait)
+
+			req, _ = http.NewRequest("GET", "/api/v5/report/testDash?paper=a3&orientation=auto&margin=15mm", nil)
+			router.ServeHTTP(rec, req)
+			So(repOpts.Paper, ShouldEqual, "a3")
+			So(repOpts.Orientation, ShouldEqual, report.OrientationAuto)
+			So(repOpts.Margin, ShouldEqual, "15mm")
+		})
+
+		Convey("It should forward the locale, defaulting to the flag", func() {
+			req, _ := http.NewRequest("GET", "/api/v5/report/testDash", nil)
+			router.ServeHTTP(rec, req)
+			So(repOpts.Locale, ShouldEqual, report.LocaleEnglish)
+
+			req, _ = http.NewRequest("GET", "/api/v5/report/testDash?locale=de", nil)
+			router.ServeHTTP(rec, req)
+			So(repOpts.Locale, ShouldEqual, report.LocaleGerman)
+		})
+
+		Convey("It should forward the server's branding", func() {
+			reportBranding = report.BrandingConfig{Branding: report.Branding{Company: "ACME"}}
+			defer func() { reportBranding = report.BrandingConfig{} }()
+			req, _ := http.NewRequest("GET", "/api/v5/report/testDash", nil)
+			router.ServeHTTP(rec, req)
+			So(repOpts.Branding.Company, ShouldEqual, "ACME")
+		})
+
+		Convey("It should tolerate failed panels unless strict=true", func() {
+			req, _ := http.NewRequest("GET", "/api/v5/report/testDash", nil)
+			router.ServeHTTP(rec, req)
+			So(repOpts.Strict, ShouldBeFalse)
+
+			req, _ = http.NewRequest("GET", "/api/v5/report/testDash?strict=true", nil)
+			router.ServeHTTP(rec, req)
+			So(repOpts.Strict, ShouldBeTrue)
+		})
+
 		Convey("It should extract the grafana variables and forward them to the new Grafana Client ", func() {
 			req, _ := http.NewRequest("GET", "/api/v5/report/testDash?var-test=testValue", nil)
 			router.ServeHTTP(rec, req)
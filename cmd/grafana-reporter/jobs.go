@@ -0,0 +1,326 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/IzakMarais/reporter/report"
+	"github.com/gorilla/mux"
+	"github.com/pborman/uuid"
+)
+
+const (
+	jobRunning   = "running"
+	jobDone      = "done"
+	jobFailed    = "failed"
+	jobCancelled = "cancelled"
+)
+
+// job is a report generated in the background. The exported fields are its JSON status.
+type job struct {
+	ID        string `json:"id"`
+	Dashboard string `json:"dashboard"`
+	Status    string `json:"status"`
+	report.Progress
+	Error     string             `json:"error,omitempty"`
+	LaTeX     *report.LaTeXError `json:"latex,omitempty"`
+	Created   time.Time          `json:"created"`
+	Finished  *time.Time         `json:"finished,omitempty"`
+	ResultURL string             `json:"resultUrl,omitempty"`
+
+	format  string
+	title   string
+	path    string
+	cancel  context.CancelFunc
+	changed chan struct{} //closed and replaced on every update
+}
+
+// jobStore holds the report jobs and their results
+type jobStore struct {
+	dir  string
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+var reportJobs = &jobStore{dir: filepath.Join(report.DefaultWorkDir, "jobs"), jobs: map[string]*job{}}
+
+// start registers a running job. Its report should be created with the returned context and progress callback.
+func (s *jobStore) start(dashName, format string) (*job, context.Context, func(report.Progress)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	id := uuid.New()
+	j := &job{
+		ID:        id,
+		Dashboard: dashName,
+		Status:    jobRunning,
+		Created:   time.Now(),
+		format:    format,
+		path:      filepath.Join(s.dir, id+report.FileExtension(format)),
+		cancel:    cancel,
+		changed:   make(chan struct{}),
+	}
+	s.mu.Lock()
+	s.jobs[id] = j
+	s.mu.Unlock()
+	progress := func(p report.Progress) {
+		s.update(id, func(j *job) { j.Progress = p })
+	}
+	return j, ctx, progress
+}
+
+// get returns a copy of a job, and a channel that is closed when it changes
+func (s *jobStore) get(id string) (job, <-chan struct{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return job{}, nil, false
+	}
+	return *j, j.changed, true
+}
+
+func (s *jobStore) update(id string, change func(*job)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if j, ok := s.jobs[id]; ok {
+		change(j)
+		close(j.changed)
+		j.changed = make(chan struct{})
+	}
+}
+
+// run generates the report, keeps the result and removes the job once it expires
+func (s *jobStore) run(id string, rep report.Report) {
+	defer rep.Clean()
+	j, _, _ := s.get(id)
+	file, err := rep.Generate()
+	if err == nil {
+		err = s.saveResult(j.path, file)
+		file.Close()
+	}
+	if err != nil {
+		log.Println("Error generating report for job", id, ":", err)
+	}
+	title := rep.Title()
+
+	s.update(id, func(j *job) {
+		now := time.Now()
+		j.Finished = &now
+		j.title = title
+		switch {
+		case j.Status == jobCancelled:
+			os.Remove(j.path)
+		case err != nil:
+			j.Status, j.Error = jobFailed, err.Error()
+			if latexErr, ok := err.(*report.LaTeXError); ok {
+				j.LaTeX = latexErr
+			}
+		default:
+			j.Status, j.ResultURL = jobDone, "/api/jobs/"+id+"/result"
+		}
+	})
+	time.AfterFunc(*jobTTL, func() { s.remove(id) })
+}
+
+func (s *jobStore) saveResult(path string, file io.Reader) error {
+	if err := os.MkdirAll(s.dir, 0777); err != nil {
+		return fmt.Errorf("error creating jobs directory: %v", err)
+	}
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating job result: %v", err)
+	}
+	_, err = io.Copy(out, file)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return fmt.Errorf("error writing job result: %v", err)
+	}
+	return nil
+}
+
+// cancel stops a running job, or removes a finished one and its result. It returns false if there is no such job.
+func (s *jobStore) cancel(id string) bool {
+	j, _, ok := s.get(id)
+	if !ok {
+		return false
+	}
+	if j.Status != jobRunning {
+		s.remove(id)
+		return true
+	}
+	s.update(id, func(j *job) { j.Status = jobCancelled })
+	j.cancel()
+	return true
+}
+
+func (s *jobStore) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if j, ok := s.jobs[id]; ok {
+		os.Remove(j.path)
+		j.cancel()
+		delete(s.jobs, id)
+	}
+}
+
+// startJob generates a report in the background and responds with the job's status
+func (h ServeReportHandler) startJob(w http.ResponseWriter, req *http.Request) {
+	if err := reportWorkspace.CheckQuota(); err != nil {
+		writeReportError(w, err)
+		return
+	}
+	if _, err := panelFilter(req); err != nil {
+		writeReportError(w, err)
+		return
+	}
+	if _, _, err := comparison(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	parts, err := h.compositeParts(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts := reportOptions(req)
+	if err := validateOptions(req, parts, opts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dashboard := dashID(req)
+	if len(parts) > 0 {
+		dashboard = compositeTitle(req)
+	}
+	j, ctx, progress := reportJobs.start(dashboard, opts.Format)
+	opts.Context, opts.Progress = ctx, progress
+	rep := h.requestReport(req, parts, opts)
+	go reportJobs.run(j.ID, rep)
+
+	log.Println("Started report job", j.ID)
+	w.Header().Set("Location", "/api/jobs/"+j.ID)
+	status, _, _ := reportJobs.get(j.ID)
+	writeJSON(w, http.StatusAccepted, status)
+}
+
+// serveJob returns the status of a job on GET, and cancels or removes it on DELETE
+func serveJob(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	switch r.Method {
+	case http.MethodGet:
+		j, _, ok := reportJobs.get(id)
+		if !ok {
+			http.Error(w, "unknown job "+id, http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, j)
+	case http.MethodDelete:
+		if !reportJobs.cancel(id) {
+			http.Error(w, "unknown job "+id, http.StatusNotFound)
+			return
+		}
+		log.Println("Cancelled report job", id)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// serveJobResult serves the report of a finished job
+func serveJobResult(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	j, _, ok := reportJobs.get(id)
+	if !ok {
+		http.Error(w, "unknown job "+id, http.StatusNotFound)
+		return
+	}
+	if j.Status != jobDone {
+		writeJSON(w, http.StatusConflict, j)
+		return
+	}
+	f, err := os.Open(j.path)
+	if err != nil {
+		log.Println("Error opening job result:", err)
+		http.Error(w, "result of job "+id+" is no longer available", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+	addFilenameHeader(w, j.title, report.FileExtension(j.format))
+	w.Header().Set("Content-Type", report.ContentType(j.format))
+	http.ServeContent(w, r, "", *j.Finished, f)
+}
+
+// serveJobEvents streams the status of a job as server-sent events, until the job finishes.
+// The events are named "progress" while the job is running, then after its final status.
+func serveJobEvents(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	j, changed, ok := reportJobs.get(id)
+	if !ok {
+		http.Error(w, "unknown job "+id, http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	for {
+		event := "progress"
+		if j.Status != jobRunning {
+			event = j.Status
+		}
+		data, err := json.Marshal(j)
+		if err != nil {
+			log.Println("Error encoding job status:", err)
+			return
+		}
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+		flusher.Flush()
+		if j.Status != jobRunning {
+			return
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+		if j, changed, ok = reportJobs.get(id); !ok {
+			return
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("Error writing JSON response:", err)
+	}
+}
@@ -0,0 +1,170 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IzakMarais/reporter/grafana"
+	"github.com/IzakMarais/reporter/report"
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// jobReport waits for release or cancellation, reporting progress
+type jobReport struct {
+	mockReport
+	opts    report.Options
+	release chan struct{}
+}
+
+func (m jobReport) Generate() (io.ReadCloser, error) {
+	m.opts.Progress(report.Progress{Phase: report.PhaseRendering, PanelsRendered: 1, PanelsTotal: 2})
+	select {
+	case <-m.release:
+		return ioutil.NopCloser(strings.NewReader("report")), nil
+	case <-m.opts.Context.Done():
+		return nil, m.opts.Context.Err()
+	}
+}
+
+func TestMain(m *testing.M) {
+	//keep job results out of the source tree
+	dir, err := ioutil.TempDir("", "jobs")
+	if err != nil {
+		panic(err)
+	}
+	reportJobs.dir = dir
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestJobs(t *testing.T) {
+	Convey("When generating a report as a job", t, func() {
+		release := make(chan struct{})
+		newGrafanaClient := func(url string, apiToken string, variables url.Values, sslCheck bool, gridLayout bool) grafana.Client {
+			return nil
+		}
+		newReport := func(g grafana.Client, dashName string, _ grafana.TimeRange, opts report.Options) report.Report {
+			return jobReport{opts: opts, release: release}
+		}
+		router := mux.NewRouter()
+		RegisterHandlers(router, ServeReportHandler{nil, nil}, ServeReportHandler{newGrafanaClient, newReport})
+		do := func(method, path string) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			req, _ := http.NewRequest(method, path, nil)
+			router.ServeHTTP(rec, req)
+			return rec
+		}
+		status := func(id string) job {
+			var j job
+			json.NewDecoder(do("GET", "/api/jobs/"+id).Body).Decode(&j)
+			return j
+		}
+		waitFor := func(id, s string) job {
+			j := status(id)
+			for i := 0; i < 1000 && j.Status != s; i++ {
+				time.Sleep(time.Millisecond)
+				j = status(id)
+			}
+			return j
+		}
+
+		rec := do("POST", "/api/v5/report/testDash?format=html")
+		var started job
+		json.NewDecoder(rec.Body).Decode(&started)
+
+		Convey("POST should start a job and return its ID", func() {
+			So(rec.Code, ShouldEqual, http.StatusAccepted)
+			So(started.ID, ShouldNotBeEmpty)
+			So(started.Dashboard, ShouldEqual, "testDash")
+			So(rec.Header().Get("Location"), ShouldEqual, "/api/jobs/"+started.ID)
+			close(release)
+		})
+
+		Convey("GET should return its status and progress while it is running", func() {
+			j := status(started.ID)
+			for i := 0; i < 1000 && j.PanelsRendered == 0; i++ {
+				time.Sleep(time.Millisecond)
+				j = status(started.ID)
+			}
+			So(j.Status, ShouldEqual, jobRunning)
+			So(j.Progress, ShouldResemble, report.Progress{Phase: report.PhaseRendering, PanelsRendered: 1, PanelsTotal: 2})
+			So(do("GET", "/api/jobs/"+started.ID+"/result").Code, ShouldEqual, http.StatusConflict)
+			close(release)
+		})
+
+		Convey("The result should be available once it is done", func() {
+			close(release)
+			j := waitFor(started.ID, jobDone)
+			So(j.ResultURL, ShouldEqual, "/api/jobs/"+started.ID+"/result")
+			result := do("GET", j.ResultURL)
+			So(result.Code, ShouldEqual, http.StatusOK)
+			So(result.Body.String(), ShouldEqual, "report")
+			So(result.Header().Get("Content-Type"), ShouldEqual, "text/html; charset=utf-8")
+
+			Convey("Its events should end with the final status", func() {
+				events := do("GET", "/api/jobs/"+started.ID+"/events")
+				So(events.Header().Get("Content-Type"), ShouldEqual, "text/event-stream")
+				So(events.Body.String(), ShouldStartWith, "event: done\ndata: {")
+			})
+
+			Convey("DELETE should remove the job and its result", func() {
+				kept, _, _ := reportJobs.get(started.ID)
+				So(kept.path, ShouldNotBeEmpty)
+				So(do("DELETE", "/api/jobs/"+started.ID).Code, ShouldEqual, http.StatusNoContent)
+				So(do("GET", "/api/jobs/"+started.ID).Code, ShouldEqual, http.StatusNotFound)
+				_, err := os.Stat(kept.path)
+				So(os.IsNotExist(err), ShouldBeTrue)
+			})
+		})
+
+		Convey("DELETE should cancel a running job", func() {
+			So(do("DELETE", "/api/jobs/"+started.ID).Code, ShouldEqual, http.StatusNoContent)
+			j := waitFor(started.ID, jobCancelled)
+			for i := 0; i < 1000 && j.Finished == nil; i++ {
+				time.Sleep(time.Millisecond)
+				j = status(started.ID)
+			}
+			So(j.Status, ShouldEqual, jobCancelled)
+			So(j.Finished, ShouldNotBeNil)
+			So(do("GET", "/api/jobs/"+started.ID+"/result").Code, ShouldEqual, http.StatusConflict)
+		})
+
+		Convey("Jobs with invalid options should be refused with 400 Bad Request", func() {
+			So(do("POST", "/api/v5/report/testDash?format=html&compareShift=1w").Code, ShouldEqual, http.StatusBadRequest)
+			So(do("POST", "/api/v5/report?dashboard=api&format=html").Code, ShouldEqual, http.StatusBadRequest)
+			close(release)
+		})
+
+		Convey("Unknown jobs should not be found", func() {
+			So(do("GET", "/api/jobs/unknown").Code, ShouldEqual, http.StatusNotFound)
+			So(do("DELETE", "/api/jobs/unknown").Code, ShouldEqual, http.StatusNotFound)
+			close(release)
+		})
+	})
+}
@@ -18,9 +18,11 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/IzakMarais/reporter/grafana"
 	"github.com/IzakMarais/reporter/report"
@@ -32,7 +34,27 @@ var ip = flag.String("ip", "localhost:3000", "Grafana IP and port.")
 var port = flag.String("port", ":8686", "Port to serve on.")
 var templateDir = flag.String("templates", "templates/", "Directory for custom TeX templates.")
 var sslCheck = flag.Bool("ssl-check", true, "Check the SSL issuer and validity. Set this to false if your Grafana serves https using an unverified, self-signed certificate.")
-var gridLayout = flag.Bool("grid-layout", false, "Enable grid layout (-grid-layout=1). Panel width and height will be calculated based off Grafana gridPos width and height.")
+var gridLayout = flag.Bool("grid-layout", false, "Enable grid layout (-grid-layout=1). Panels are placed at their Grafana gridPos position and size, like on the dashboard.")
+var backend = flag.String("backend", report.BackendLaTeX, "PDF backend: [latex, native]. 'latex' typesets TeX templates with the TeX engine, 'native' lays out the report without requiring a TeX installation. Can be overridden per request.")
+var texEngine = flag.String("tex-engine", report.EnginePdfLaTeX, "TeX engine used by the latex backend: [pdflatex, xelatex, lualatex]. Use xelatex or lualatex for dashboards with non-Latin scripts or emoji. Can be overridden per request.")
+var paper = flag.String("paper", "", "Paper size of PDF reports: [a4, letter, a3]. Defaults to letter for the latex backend and a4 for the native backend. Can be overridden per request.")
+var orientation = flag.String("orientation", report.OrientationPortrait, "Page orientation of PDF reports: [portrait, landscape, auto]. 'auto' turns the pages of very wide panels sideways in grid layout. Can be overridden per request.")
+var margin = flag.String("margin", "", "Page margins of PDF reports, a length in mm, cm, in, pt or bp, example: -margin 15mm. Defaults to 1in, or 0.5in in grid layout. Can be overridden per request.")
+var locale = flag.String("locale", report.LocaleEnglish, "Locale of reports, for the text of the default templates and the formatting of dates and numbers: [en, fr, de], or a locale with a message catalogue messages.{locale}.json in the -templates directory. Can be overridden per request.")
+var brandingFile = flag.String("branding", "", "JSON file of the logo, primary colour, company name, cover page and disclaimer of reports, with optional overrides per backend, see the readme. No branding if empty.")
+var fontsDir = flag.String("fonts", "", "Directory of font files for the xelatex and lualatex engines. Optional, fonts installed on the system can be used without it.")
+var mainFont = flag.String("font", "", "Main font for the xelatex and lualatex engines: a system font name, or a font file name in the -fonts directory, example: -font NotoSans-Regular.ttf.")
+var diagnosticsRetention = flag.Duration("diagnostics-retention", report.DefaultDiagnosticsRetention, "How long the TeX source and log of a report that failed to compile are kept for download from the diagnostics endpoint. Set to 0 to not keep them.")
+var publicURL = flag.String("public-url", "", "Grafana URL for links in reports, e.g. https://grafana.example.com if Grafana is behind a proxy. Defaults to the -proto and -ip Grafana URL.")
+var userHeader = flag.String("user-header", "", "Request header holding the name of the user requesting a report, as set by an authenticating proxy, example: -user-header X-WEBAUTH-USER. Only set it if the reporter is only reachable through the proxy, as clients can send any header. Without it, the name of the Grafana API key is used.")
+var cacheStore = flag.String("cache", "", "Cache generated reports: [memory, disk]. Repeated requests for the same dashboard version, absolute time range, variables, template and options are then served from the cache. Reports are not cached if empty.")
+var cacheDir = flag.String("cache-dir", "", "Directory of the disk cache. It is emptied on startup, and must be missing, empty or a previous cache directory. Defaults to cache in the -work-dir.")
+var cacheSize = flag.Int64("cache-size", 256, "Maximum total size of the cached reports, in MB. 0 for no limit.")
+var renderConcurrency = flag.Int("render-concurrency", report.DefaultRenderConcurrency, "Maximum number of panels rendered by Grafana at the same time, shared by all reports. Halved temporarily when Grafana responds with 429 or 503.")
+var jobTTL = flag.Duration("job-ttl", time.Hour, "How long the status and result of a report job are kept after it finishes.")
+var workDir = flag.String("work-dir", report.DefaultWorkDir, "Directory that reports are generated in, e.g. a tmpfs mount. Orphaned report directories in it are removed on startup and periodically.")
+var workDirQuota = flag.Int64("work-dir-quota", 0, "Maximum total size of the reports being generated and the LaTeX diagnostics in the -work-dir, in MB. The disk cache and job results are not counted. New reports are refused with 507 Insufficient Storage once it is used. 0 for no limit.")
+var workDirMaxAge = flag.Duration("work-dir-max-age", time.Hour, "How long a report may use its directory in the -work-dir before it is considered orphaned and removed.")
 
 //cmd line mode params
 var cmdMode = flag.Bool("cmd_enable", false, "Enable command line mode. Generate report from command line without starting webserver (-cmd_enable=1).")
@@ -42,25 +64,53 @@ var apiVersion = flag.String("cmd_apiVersion", "v5", "Api version: [v4, v5]. Req
 var outputFile = flag.String("cmd_o", "out.pdf", "Output file. Required (and only used) in command line mode.")
 var timeSpan = flag.String("cmd_ts", "from=now-3h&to=now", "Time span. Required (and only used) in command line mode.")
 var template = flag.String("cmd_template", "", "Specify a custom TeX template file. Only used in command line mode, but is optional even there.")
+var format = flag.String("cmd_format", "pdf", "Output format: [pdf, html, zip, docx, pptx]. Only used in command line mode, example: -cmd_format html.")
+var cmdStrict = flag.Bool("cmd_strict", false, "Fail the report if any panel fails to render, instead of replacing failed panels by a placeholder and listing them in an appendix. Only used in command line mode.")
+var cmdTOC = flag.Bool("cmd_toc", false, "Add a table of contents to PDF reports. Only used in command line mode.")
+var cmdRowBreak = flag.Bool("cmd_rowbreak", false, "Start every dashboard row that shows its title on a new page. Only used in command line mode.")
+var cmdFilter = flag.String("cmd_filter", "", "Panel filters as query parameters, example: -cmd_filter 'panelType=graph&row=Database'. Only used in command line mode.")
+var cmdRepeatBy = flag.String("cmd_repeatBy", "", "Template variable to repeat the dashboard by, with a chapter per value, example: -cmd_repeatBy var-host. Only used in command line mode.")
+
+func version() string {
+	return fmt.Sprintf("%s.%s-%s", generatedMajor, generatedMinor, generatedRelease)
+}
 
 func main() {
+	if len(os.Args) > 2 && os.Args[1] == "template" && os.Args[2] == "check" {
+		os.Exit(templateCheckCmd(os.Args[3:], os.Stdout))
+	}
 	flag.Parse()
 	log.SetOutput(os.Stdout)
 
 	//'generated*'' variables injected from build.gradle: task 'injectGoVersion()'
-	log.Printf("grafana reporter, version: %s.%s-%s hash: %s", generatedMajor, generatedMinor, generatedRelease, generatedGitHash)
+	log.Printf("grafana reporter, version: %s hash: %s", version(), generatedGitHash)
 	log.Printf("serving at '%s' and using grafana at '%s'", *port, *proto+*ip)
 	if !*sslCheck {
 		log.Printf("SSL check disabled")
 	} else {
 		log.Printf("SSL check enforced")
 	}
+	log.Printf("Using '%s' PDF backend", *backend)
+	log.Printf("Using '%s' TeX engine", *texEngine)
 	if !*gridLayout {
 		log.Printf("Using sequential report layout. Consider enabling 'grid-layout' so that your report more closely follow the dashboard layout.")
 	} else {
 		log.Printf("Using grid layout.")
 	}
 
+	if *brandingFile != "" {
+		var err error
+		if reportBranding, err = report.LoadBranding(*brandingFile); err != nil {
+			log.Fatalln(err)
+		}
+		log.Printf("Using branding from '%s'", *brandingFile)
+	}
+
+	renderScheduler = report.NewScheduler(*renderConcurrency)
+	log.Printf("Rendering up to %d panels at the same time", *renderConcurrency)
+
+	reportWorkspace = newReportWorkspace()
+
 	router := mux.NewRouter()
 	RegisterHandlers(
 		router,
@@ -78,11 +128,22 @@ func main() {
 		if template != nil && *template != "" {
 			log.Printf("Called with command line mode 'template' '%s'", *template)
 		}
+		log.Printf("Called with command line mode 'format' '%s'", *format)
+		log.Printf("Called with command line mode 'strict' '%v'", *cmdStrict)
+		log.Printf("Called with command line mode 'toc' '%v'", *cmdTOC)
+		log.Printf("Called with command line mode 'rowbreak' '%v'", *cmdRowBreak)
+		log.Printf("Called with command line mode 'filter' '%s'", *cmdFilter)
+		log.Printf("Called with command line mode 'repeatBy' '%s'", *cmdRepeatBy)
 
 		if err := cmdHandler(router); err != nil {
 			log.Fatalln(err)
 		}
 	} else {
+		sweepWorkDir(reportWorkspace, *workDirMaxAge)
+		var err error
+		if reportCache, err = newReportCache(); err != nil {
+			log.Fatalln(err)
+		}
 		log.Fatal(http.ListenAndServe(*port, router))
 	}
 }
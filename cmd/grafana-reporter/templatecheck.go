@@ -0,0 +1,231 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/IzakMarais/reporter/grafana"
+	"github.com/IzakMarais/reporter/report"
+)
+
+// templateCheckConfig holds the command line options of 'grafana-reporter template check'
+type templateCheckConfig struct {
+	templateFile  string
+	dashboardFile string
+	templateDir   string
+	output        string
+	opts          report.Options
+	compile       bool
+}
+
+// templateCheckCmd runs 'grafana-reporter template check [flags] template.tex' and returns the exit code
+func templateCheckCmd(args []string, out io.Writer) int {
+	fs := flag.NewFlagSet("template check", flag.ContinueOnError)
+	fs.SetOutput(out)
+	var cfg templateCheckConfig
+	fs.StringVar(&cfg.dashboardFile, "dashboard", "", "Dashboard JSON file, as exported from Grafana. A built-in sample dashboard is used if empty.")
+	fs.StringVar(&cfg.templateDir, "templates", "", "Directory of partials. Defaults to the directory of the template.")
+	fs.StringVar(&cfg.output, "o", "", "Write the compiled preview PDF to this file.")
+	fs.StringVar(&cfg.opts.Engine, "tex-engine", report.EnginePdfLaTeX, "TeX engine: [pdflatex, xelatex, lualatex].")
+	fs.StringVar(&cfg.opts.FontsDir, "fonts", "", "Directory of font files for the xelatex and lualatex engines.")
+	fs.StringVar(&cfg.opts.MainFont, "font", "", "Main font for the xelatex and lualatex engines.")
+	fs.BoolVar(&cfg.opts.GridLayout, "grid-layout", false, "Size placeholder images from the panels' gridPos, as in grid layout.")
+	fs.BoolVar(&cfg.compile, "compile", true, "Compile the template with LaTeX. Set to false to only check the template itself.")
+	watch := fs.Bool("watch", false, "Check the template again, and update the preview, whenever the template, its partials or the dashboard file change.")
+	fs.Usage = func() {
+		fmt.Fprintln(out, "Usage: grafana-reporter template check [flags] template.tex")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	cfg.templateFile = fs.Arg(0)
+	if cfg.templateDir == "" {
+		cfg.templateDir = filepath.Dir(cfg.templateFile)
+	}
+
+	if !*watch {
+		if !checkTemplateFile(cfg, out) {
+			return 1
+		}
+		return 0
+	}
+	var lastChange time.Time
+	for {
+		if change := cfg.lastChange(); !change.Equal(lastChange) {
+			lastChange = change
+			fmt.Fprintf(out, "%s checking %s\n", change.Format("15:04:05"), cfg.templateFile)
+			checkTemplateFile(cfg, out)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
+// lastChange returns the latest modification time of the template, its partials and the dashboard file
+func (cfg templateCheckConfig) lastChange() time.Time {
+	files, _ := filepath.Glob(filepath.Join(cfg.templateDir, "_*.tex"))
+	files = append(files, cfg.templateFile)
+	if cfg.dashboardFile != "" {
+		files = append(files, cfg.dashboardFile)
+	}
+	var latest time.Time
+	for _, f := range files {
+		if info, err := os.Stat(f); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// checkTemplateFile checks the configured template, prints the result and writes the preview. It returns true if the check passed.
+func checkTemplateFile(cfg templateCheckConfig, out io.Writer) bool {
+	templ, err := ioutil.ReadFile(cfg.templateFile)
+	if err != nil {
+		fmt.Fprintln(out, "Error reading template:", err)
+		return false
+	}
+	var dashJSON []byte
+	if cfg.dashboardFile != "" {
+		if dashJSON, err = ioutil.ReadFile(cfg.dashboardFile); err != nil {
+			fmt.Fprintln(out, "Error reading dashboard:", err)
+			return false
+		}
+	}
+	g, err := report.NewSampleClient(dashJSON, url.Values{}, cfg.opts.GridLayout)
+	if err != nil {
+		fmt.Fprintln(out, err)
+		return false
+	}
+	opts := cfg.opts
+	opts.Template = string(templ)
+	opts.TemplateDir = cfg.templateDir
+
+	var preview bytes.Buffer
+	res := report.Check(g, "sample", grafana.NewTimeRange("now-6h", "now"), opts, cfg.compile, &preview)
+	printCheckResult(out, res)
+	if res.OK && cfg.output != "" && preview.Len() > 0 {
+		if err = ioutil.WriteFile(cfg.output, preview.Bytes(), 0666); err != nil {
+			fmt.Fprintln(out, "Error writing preview:", err)
+			return false
+		}
+		fmt.Fprintln(out, "Preview written to", cfg.output)
+	}
+	return res.OK
+}
+
+func printCheckResult(out io.Writer, res report.CheckResult) {
+	if res.OK {
+		fmt.Fprintln(out, "Template OK")
+		return
+	}
+	fmt.Fprintf(out, "Template check failed at the %s stage: %s\n", res.Stage, res.Error)
+	if res.MissingField != "" {
+		fmt.Fprintf(out, "The template data has no field %q\n", res.MissingField)
+	}
+	if res.LaTeX != nil {
+		fmt.Fprintln(out, strings.Join(res.LaTeX.Context, "\n"))
+	}
+}
+
+// serveTemplateCheck checks a template posted as the multipart form field or file 'template', or the template in the
+// templates directory named by the 'template' query parameter, against the posted 'dashboard' JSON or the sample dashboard.
+// It responds with the report.CheckResult as JSON, or the compiled PDF if preview=true and the check passed.
+func serveTemplateCheck(w http.ResponseWriter, r *http.Request) {
+	templ, err := formFile(r, "template")
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	if templ == "" {
+		templ = customTemplate(r, report.FormatPDF)
+	}
+	if templ == "" {
+		http.Error(w, "no template posted or found in the templates directory", 400)
+		return
+	}
+	dashJSON, err := formFile(r, "dashboard")
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	g, err := report.NewSampleClient([]byte(dashJSON), dashVariables(r), *gridLayout)
+	if err != nil {
+		http.Error(w, err.Error(), 400)
+		return
+	}
+	opts := reportOptions(r)
+	opts.Template = templ
+	params := r.URL.Query()
+
+	var preview bytes.Buffer
+	res := report.Check(g, "sample", grafana.NewTimeRange("now-6h", "now"), opts, params.Get("compile") != "false", &preview)
+	if res.OK && params.Get("preview") == "true" && preview.Len() > 0 {
+		w.Header().Set("Content-Type", report.ContentType(report.FormatPDF))
+		w.Write(preview.Bytes())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !res.OK {
+		w.WriteHeader(422)
+	}
+	if err = json.NewEncoder(w).Encode(res); err != nil {
+		log.Println("Error writing template check response:", err)
+	}
+}
+
+// formFile returns the content of a posted form field, or of an uploaded file of that name
+func formFile(r *http.Request, name string) (string, error) {
+	if r.Method != "POST" {
+		return "", nil
+	}
+	if err := r.ParseMultipartForm(32 << 20); err != nil && err != http.ErrNotMultipart {
+		return "", fmt.Errorf("error parsing form: %v", err)
+	}
+	if v := r.PostFormValue(name); v != "" {
+		return v, nil
+	}
+	f, _, err := r.FormFile(name)
+	if err == http.ErrMissingFile || err == http.ErrNotMultipart {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error reading %v: %v", name, err)
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return "", fmt.Errorf("error reading %v: %v", name, err)
+	}
+	return string(b), nil
+}
@@ -0,0 +1,108 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/IzakMarais/reporter/report"
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTemplateCheckCmd(t *testing.T) {
+	Convey("When running the template check command", t, func() {
+		dir, err := ioutil.TempDir("", "templates")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		file := filepath.Join(dir, "custom.tex")
+		var out bytes.Buffer
+
+		Convey("It should report a valid template as OK", func() {
+			ioutil.WriteFile(filepath.Join(dir, "_title.tex"), []byte(`[[.Title]]`), 0666)
+			ioutil.WriteFile(file, []byte(`[[template "title" .]]`), 0666)
+			So(templateCheckCmd([]string{"-compile=false", file}, &out), ShouldEqual, 0)
+			So(out.String(), ShouldContainSubstring, "Template OK")
+		})
+
+		Convey("It should report missing fields and fail", func() {
+			ioutil.WriteFile(file, []byte(`[[.Titel]]`), 0666)
+			So(templateCheckCmd([]string{"-compile=false", file}, &out), ShouldEqual, 1)
+			So(out.String(), ShouldContainSubstring, "failed at the execute stage")
+			So(out.String(), ShouldContainSubstring, `no field "Titel"`)
+		})
+
+		Convey("It should require a template file", func() {
+			So(templateCheckCmd([]string{}, &out), ShouldEqual, 2)
+		})
+
+		Convey("The last change should cover the template, its partials and the dashboard", func() {
+			ioutil.WriteFile(file, []byte(`x`), 0666)
+			partial := filepath.Join(dir, "_partial.tex")
+			ioutil.WriteFile(partial, []byte(`y`), 0666)
+			later := time.Now().Add(time.Hour)
+			os.Chtimes(partial, later, later)
+			cfg := templateCheckConfig{templateFile: file, templateDir: dir}
+			So(cfg.lastChange().Unix(), ShouldEqual, later.Unix())
+		})
+	})
+}
+
+func TestTemplateCheckHandler(t *testing.T) {
+	Convey("When posting a template to the check endpoint", t, func() {
+		router := mux.NewRouter()
+		RegisterHandlers(router, ServeReportHandler{nil, nil}, ServeReportHandler{nil, nil})
+		post := func(templ string) *httptest.ResponseRecorder {
+			var body bytes.Buffer
+			mw := multipart.NewWriter(&body)
+			fw, _ := mw.CreateFormFile("template", "custom.tex")
+			fw.Write([]byte(templ))
+			mw.WriteField("dashboard", `{"title": "Posted", "panels": [{"id": 1, "type": "graph"}]}`)
+			mw.Close()
+			req, _ := http.NewRequest("POST", "/api/template/check?compile=false", &body)
+			req.Header.Set("Content-Type", mw.FormDataContentType())
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			return rec
+		}
+
+		Convey("A valid template should pass", func() {
+			rec := post(`[[.Title]]`)
+			So(rec.Code, ShouldEqual, 200)
+			var res report.CheckResult
+			So(json.Unmarshal(rec.Body.Bytes(), &res), ShouldBeNil)
+			So(res.OK, ShouldBeTrue)
+		})
+
+		Convey("A broken template should be reported as unprocessable", func() {
+			rec := post(`[[if .Title]]`)
+			So(rec.Code, ShouldEqual, 422)
+			var res report.CheckResult
+			So(json.Unmarshal(rec.Body.Bytes(), &res), ShouldBeNil)
+			So(res.Stage, ShouldEqual, report.StageParse)
+		})
+	})
+}
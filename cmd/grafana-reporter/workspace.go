@@ -0,0 +1,64 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/IzakMarais/reporter/report"
+)
+
+// newReportWorkspace creates the work directory selected by the -work-dir flags, and keeps job results in it
+func newReportWorkspace() *report.Workspace {
+	ws := report.NewWorkspace(*workDir, *workDirQuota<<20)
+	reportJobs.dir = filepath.Join(*workDir, "jobs")
+	if *workDirQuota > 0 {
+		log.Printf("Generating reports in '%s' with a %d MB quota", *workDir, *workDirQuota)
+	} else {
+		log.Printf("Generating reports in '%s'", *workDir)
+	}
+	return ws
+}
+
+// sweepWorkDir removes the results of jobs from before a restart, which can no longer be requested,
+// and orphaned report directories, then keeps sweeping periodically
+func sweepWorkDir(ws *report.Workspace, maxAge time.Duration) {
+	if err := os.RemoveAll(reportJobs.dir); err != nil {
+		log.Println("Error removing old job results:", err)
+	}
+	sweep := func() {
+		n, err := ws.Sweep(maxAge)
+		if err != nil {
+			log.Println("Error sweeping work directory:", err)
+		}
+		if n > 0 {
+			log.Printf("Removed %d orphaned directories from '%s'", n, ws.Root())
+		}
+	}
+	sweep()
+	if maxAge <= 0 {
+		return
+	}
+	go func() {
+		for range time.Tick(maxAge / 4) {
+			sweep()
+		}
+	}()
+}
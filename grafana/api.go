@@ -33,18 +33,43 @@ import (
 type Client interface {
 	GetDashboard(dashName string) (Dashboard, error)
 	GetPanelPng(p Panel, dashName string, t TimeRange) (io.ReadCloser, error)
+	GetPanelPngURL(p Panel, dashName string, t TimeRange) string
+	GetDashboardPath(dashName string, t TimeRange) string
+	GetPanelPath(p Panel, dashName string, t TimeRange) string
 }
 
 type client struct {
 	url              string
 	getDashEndpoint  func(dashName string) string
 	getPanelEndpoint func(dashName string, vals url.Values) string
+	getDashPath      func(dashName string) string
 	apiToken         string
 	variables        url.Values
 	sslCheck         bool
 	gridLayout       bool
 }
 
+// StatusError is returned when Grafana responds with an unexpected HTTP status
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return e.Message
+}
+
+// IsOverloaded reports whether err, or an error it wraps, is Grafana asking clients to slow down:
+// 429 Too Many Requests or 503 Service Unavailable
+func IsOverloaded(err error) bool {
+	var se *StatusError
+	return errors.As(err, &se) && isOverloadStatus(se.StatusCode)
+}
+
+func isOverloadStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable
+}
+
 var getPanelRetrySleepTime = time.Duration(10) * time.Second
 
 // NewV4Client creates a new Grafana 4 Client. If apiToken is the empty string,
@@ -62,7 +87,11 @@ func NewV4Client(grafanaURL string, apiToken string, variables url.Values, sslCh
 	getPanelEndpoint := func(dashName string, vals url.Values) string {
 		return fmt.Sprintf("%s/render/dashboard-solo/db/%s?%s", grafanaURL, dashName, vals.Encode())
 	}
-	return client{grafanaURL, getDashEndpoint, getPanelEndpoint, apiToken, variables, sslCheck, gridLayout}
+
+	getDashPath := func(dashName string) string {
+		return "/dashboard/db/" + dashName
+	}
+	return client{grafanaURL, getDashEndpoint, getPanelEndpoint, getDashPath, apiToken, variables, sslCheck, gridLayout}
 }
 
 // NewV5Client creates a new Grafana 5 Client. If apiToken is the empty string,
@@ -80,7 +109,11 @@ func NewV5Client(grafanaURL string, apiToken string, variables url.Values, sslCh
 	getPanelEndpoint := func(dashName string, vals url.Values) string {
 		return fmt.Sprintf("%s/render/d-solo/%s/_?%s", grafanaURL, dashName, vals.Encode())
 	}
-	return client{grafanaURL, getDashEndpoint, getPanelEndpoint, apiToken, variables, sslCheck, gridLayout}
+
+	getDashPath := func(dashName string) string {
+		return "/d/" + dashName
+	}
+	return client{grafanaURL, getDashEndpoint, getPanelEndpoint, getDashPath, apiToken, variables, sslCheck, gridLayout}
 }
 
 func (g client) GetDashboard(dashName string) (Dashboard, error) {
@@ -118,6 +151,7 @@ func (g client) GetDashboard(dashName string) (Dashboard, error) {
 
 func (g client) GetPanelPng(p Panel, dashName string, t TimeRange) (io.ReadCloser, error) {
 	panelURL := g.getPanelURL(p, dashName, t)
+	log.Println("Downloading image ", p.Id, panelURL)
 
 	tr := &http.Transport{
 		TLSClientConfig: &tls.Config{InsecureSkipVerify: !g.sslCheck},
@@ -140,7 +174,8 @@ func (g client) GetPanelPng(p Panel, dashName string, t TimeRange) (io.ReadClose
 		return nil, fmt.Errorf("error executing getPanelPng request for %v: %v", panelURL, err)
 	}
 
-	for retries := 1; retries < 3 && resp.StatusCode != 200; retries++ {
+	//overload statuses are returned straight away, for the caller to slow down rather than hold a render slot
+	for retries := 1; retries < 3 && resp.StatusCode != 200 && !isOverloadStatus(resp.StatusCode); retries++ {
 		delay := getPanelRetrySleepTime * time.Duration(retries)
 		log.Printf("Error obtaining render for panel %+v, Status: %v, Retrying after %v...", p, resp.StatusCode, delay)
 		time.Sleep(delay)
@@ -156,12 +191,43 @@ func (g client) GetPanelPng(p Panel, dashName string, t TimeRange) (io.ReadClose
 			panic(err)
 		}
 		log.Println("Error obtaining render:", string(body))
-		return nil, errors.New("Error obtaining render: " + resp.Status)
+		return nil, &StatusError{resp.StatusCode, "Error obtaining render: " + resp.Status}
 	}
 
 	return resp.Body, nil
 }
 
+// GetPanelPngURL returns the Grafana render URL of the panel image, as used by GetPanelPng
+func (g client) GetPanelPngURL(p Panel, dashName string, t TimeRange) string {
+	return g.getPanelURL(p, dashName, t)
+}
+
+// GetDashboardPath returns the path of the dashboard in the Grafana web UI, relative to Grafana's root URL,
+// with the time range and variables
+func (g client) GetDashboardPath(dashName string, t TimeRange) string {
+	return g.getDashPath(dashName) + "?" + g.linkValues(t).Encode()
+}
+
+// GetPanelPath returns the path of the panel, viewed on its own in the Grafana web UI, relative to Grafana's root URL
+func (g client) GetPanelPath(p Panel, dashName string, t TimeRange) string {
+	values := g.linkValues(t)
+	values.Add("panelId", strconv.Itoa(p.Id))
+	values.Add("fullscreen", "")
+	return g.getDashPath(dashName) + "?" + values.Encode()
+}
+
+func (g client) linkValues(t TimeRange) url.Values {
+	values := url.Values{}
+	values.Add("from", t.From)
+	values.Add("to", t.To)
+	for k, v := range g.variables {
+		for _, singleValue := range v {
+			values.Add(k, singleValue)
+		}
+	}
+	return values
+}
+
 func (g client) getPanelURL(p Panel, dashName string, t TimeRange) string {
 	values := url.Values{}
 	values.Add("theme", "light")
@@ -193,7 +259,5 @@ func (g client) getPanelURL(p Panel, dashName string, t TimeRange) string {
 		}
 	}
 
-	url := g.getPanelEndpoint(dashName, values)
-	log.Println("Downloading image ", p.Id, url)
-	return url
+	return g.getPanelEndpoint(dashName, values)
 }
@@ -57,6 +57,27 @@ func TestGrafanaClientFetchesDashboard(t *testing.T) {
 	})
 }
 
+func TestGrafanaClientLinks(t *testing.T) {
+	Convey("When building links to the Grafana web UI", t, func() {
+		variables := url.Values{}
+		variables.Add("var-host", "dev")
+		tr := TimeRange{From: "now-1h", To: "now"}
+		p := Panel{Id: 44}
+
+		Convey("The v4 client should link to the dashboard by slug", func() {
+			grf := NewV4Client("http://grafana", "", variables, true, false)
+			So(grf.GetDashboardPath("testDash", tr), ShouldEqual, "/dashboard/db/testDash?from=now-1h&to=now&var-host=dev")
+			So(grf.GetPanelPath(p, "testDash", tr), ShouldEqual, "/dashboard/db/testDash?from=now-1h&fullscreen=&panelId=44&to=now&var-host=dev")
+		})
+
+		Convey("The v5 client should link to the dashboard by uid", func() {
+			grf := NewV5Client("http://grafana", "", variables, true, false)
+			So(grf.GetDashboardPath("rYy7Paekz", tr), ShouldEqual, "/d/rYy7Paekz?from=now-1h&to=now&var-host=dev")
+			So(grf.GetPanelPath(p, "rYy7Paekz", tr), ShouldEqual, "/d/rYy7Paekz?from=now-1h&fullscreen=&panelId=44&to=now&var-host=dev")
+		})
+	})
+}
+
 func TestGrafanaClientFetchesPanelPNG(t *testing.T) {
 	Convey("When fetching a panel PNG", t, func() {
 		requestURI := ""
@@ -82,7 +103,7 @@ func TestGrafanaClientFetchesPanelPNG(t *testing.T) {
 		}
 		for clientDesc, cl := range cases {
 			grf := cl.client
-			grf.GetPanelPng(Panel{44, "singlestat", "title", GridPos{0, 0, 0, 0}}, "testDash", TimeRange{"now-1h", "now"})
+			grf.GetPanelPng(Panel{Id: 44, Type: "singlestat", Title: "title", GridPos: GridPos{0, 0, 0, 0}}, "testDash", TimeRange{"now-1h", "now"})
 
 			Convey(fmt.Sprintf("The %s client should use the render endpoint with the dashboard name", clientDesc), func() {
 				So(requestURI, ShouldStartWith, cl.pngEndpoint)
@@ -112,13 +133,13 @@ func TestGrafanaClientFetchesPanelPNG(t *testing.T) {
 			})
 
 			Convey(fmt.Sprintf("The %s client should request text panels with a small height", clientDesc), func() {
-				grf.GetPanelPng(Panel{44, "text", "title", GridPos{0, 0, 0, 0}}, "testDash", TimeRange{"now", "now-1h"})
+				grf.GetPanelPng(Panel{Id: 44, Type: "text", Title: "title", GridPos: GridPos{0, 0, 0, 0}}, "testDash", TimeRange{"now", "now-1h"})
 				So(requestURI, ShouldContainSubstring, "width=1000")
 				So(requestURI, ShouldContainSubstring, "height=100")
 			})
 
 			Convey(fmt.Sprintf("The %s client should request other panels in a larger size", clientDesc), func() {
-				grf.GetPanelPng(Panel{44, "graph", "title", GridPos{0, 0, 0, 0}}, "testDash", TimeRange{"now", "now-1h"})
+				grf.GetPanelPng(Panel{Id: 44, Type: "graph", Title: "title", GridPos: GridPos{0, 0, 0, 0}}, "testDash", TimeRange{"now", "now-1h"})
 				So(requestURI, ShouldContainSubstring, "width=1000")
 				So(requestURI, ShouldContainSubstring, "height=500")
 			})
@@ -135,13 +156,13 @@ func TestGrafanaClientFetchesPanelPNG(t *testing.T) {
 			grf := cl.client
 
 			Convey(fmt.Sprintf("The %s client should request grid layout panels with width=1000 and height=240", clientDesc), func() {
-				grf.GetPanelPng(Panel{44, "graph", "title", GridPos{6, 24, 0, 0}}, "testDash", TimeRange{"now", "now-1h"})
+				grf.GetPanelPng(Panel{Id: 44, Type: "graph", Title: "title", GridPos: GridPos{6, 24, 0, 0}}, "testDash", TimeRange{"now", "now-1h"})
 				So(requestURI, ShouldContainSubstring, "width=960")
 				So(requestURI, ShouldContainSubstring, "height=240")
 			})
 
 			Convey(fmt.Sprintf("The %s client should request grid layout panels with width=480 and height=120", clientDesc), func() {
-				grf.GetPanelPng(Panel{44, "graph", "title", GridPos{3, 12, 0, 0}}, "testDash", TimeRange{"now", "now-1h"})
+				grf.GetPanelPng(Panel{Id: 44, Type: "graph", Title: "title", GridPos: GridPos{3, 12, 0, 0}}, "testDash", TimeRange{"now", "now-1h"})
 				So(requestURI, ShouldContainSubstring, "width=480")
 				So(requestURI, ShouldContainSubstring, "height=120")
 			})
@@ -169,7 +190,7 @@ func TestGrafanaClientFetchPanelPNGErrorHandling(t *testing.T) {
 
 		grf := NewV4Client(ts.URL, "", url.Values{}, true, false)
 
-		_, err := grf.GetPanelPng(Panel{44, "singlestat", "title", GridPos{0, 0, 0, 0}}, "testDash", TimeRange{"now-1h", "now"})
+		_, err := grf.GetPanelPng(Panel{Id: 44, Type: "singlestat", Title: "title", GridPos: GridPos{0, 0, 0, 0}}, "testDash", TimeRange{"now-1h", "now"})
 
 		Convey("It should retry a couple of times if it receives errors", func() {
 			So(err, ShouldBeNil)
@@ -184,10 +205,34 @@ func TestGrafanaClientFetchPanelPNGErrorHandling(t *testing.T) {
 
 		grf := NewV4Client(ts.URL, "", url.Values{}, true, false)
 
-		_, err := grf.GetPanelPng(Panel{44, "singlestat", "title", GridPos{0, 0, 0, 0}}, "testDash", TimeRange{"now-1h", "now"})
+		_, err := grf.GetPanelPng(Panel{Id: 44, Type: "singlestat", Title: "title", GridPos: GridPos{0, 0, 0, 0}}, "testDash", TimeRange{"now-1h", "now"})
 
 		Convey("The Grafana API should return an error", func() {
 			So(err, ShouldNotBeNil)
+			So(err.(*StatusError).StatusCode, ShouldEqual, http.StatusInternalServerError)
+			So(IsOverloaded(err), ShouldBeFalse)
+		})
+	})
+
+	Convey("When the renderer is overloaded", t, func() {
+		requests := 0
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer ts.Close()
+
+		grf := NewV5Client(ts.URL, "", url.Values{}, true, false)
+
+		_, err := grf.GetPanelPng(Panel{Id: 44, Type: "graph"}, "testDash", TimeRange{"now-1h", "now"})
+
+		Convey("The error should say so", func() {
+			So(IsOverloaded(err), ShouldBeTrue)
+			So(IsOverloaded(fmt.Errorf("error getting panel: %w", err)), ShouldBeTrue)
+		})
+
+		Convey("It should not retry, so that the caller can slow down", func() {
+			So(requests, ShouldEqual, 1)
 		})
 	})
 }
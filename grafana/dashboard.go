@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"log"
 	"net/url"
+	"regexp"
 	"strings"
 )
 
@@ -47,6 +48,12 @@ type Panel struct {
 	Type    string
 	Title   string
 	GridPos GridPos
+	// Span is the width of a panel in a v4 dashboard row, out of 12 columns. Newer dashboards use GridPos instead
+	Span float64
+	// Tags are free-form labels, which can be added to a panel's JSON model in Grafana for use in templates
+	Tags []string
+	// Panels are the panels of a collapsed v5 row, which Grafana nests in the row panel instead of listing after it
+	Panels []Panel
 }
 
 // Panel represents a Grafana dashboard panel position
@@ -71,11 +78,35 @@ type Row struct {
 type Dashboard struct {
 	Title          string
 	Description    string
-	VariableValues string //Not present in the Grafana JSON structure. Enriched data passed used by the Tex templating
+	UID            string
+	Version        int        //Incremented by Grafana on every save
+	VariableValues string     //Not present in the Grafana JSON structure. Enriched data passed used by the Tex templating
+	Variables      url.Values //Not present in the Grafana JSON structure. The template variables the dashboard was requested with
 	Rows           []Row
 	Panels         []Panel
+	Tags           []string
+	Templating     Templating
 }
 
+// Templating holds the template variables of a dashboard
+type Templating struct {
+	List []TemplateVariable
+}
+
+// TemplateVariable is a dashboard template variable, with the options saved in the dashboard JSON.
+// Variables that Grafana refreshes from a data source may be saved without options.
+type TemplateVariable struct {
+	Name    string
+	Options []struct {
+		Text string
+		//a string, but left untyped so that unexpected JSON does not prevent loading the dashboard
+		Value interface{}
+	}
+}
+
+// allValue is the value of the All option of multi-value variables
+const allValue = "$__all"
+
 type dashContainer struct {
 	Dashboard Dashboard
 	Meta      struct {
@@ -100,6 +131,13 @@ func (dc dashContainer) NewDashboard(variables url.Values) Dashboard {
 	dash.Title = sanitizeLaTexInput(dc.Dashboard.Title)
 	dash.Description = sanitizeLaTexInput(dc.Dashboard.Description)
 	dash.VariableValues = sanitizeLaTexInput(getVariablesValues(variables))
+	dash.Variables = variables
+	dash.UID = dc.Dashboard.UID
+	dash.Version = dc.Dashboard.Version
+	dash.Templating = dc.Dashboard.Templating
+	for _, tag := range dc.Dashboard.Tags {
+		dash.Tags = append(dash.Tags, sanitizeLaTexInput(tag))
+	}
 
 	if len(dc.Dashboard.Rows) == 0 {
 		return populatePanelsFromV5JSON(dash, dc)
@@ -121,17 +159,63 @@ func populatePanelsFromV4JSON(dash Dashboard, dc dashContainer) Dashboard {
 	return dash
 }
 
+// populatePanelsFromV5JSON flattens the v5 panel list into Panels and groups it into Rows.
+// In v5 JSON a row is a panel of type "row", followed by the panels that belong to it.
+// Panels above the first row are placed in a Row without a visible title.
+// A collapsed row nests its panels in the row panel instead, and they are placed in that row.
 func populatePanelsFromV5JSON(dash Dashboard, dc dashContainer) Dashboard {
 	for _, p := range dc.Dashboard.Panels {
 		if p.Type == "row" {
+			dash.Rows = append(dash.Rows, Row{Id: p.Id, Showtitle: p.Title != "", Title: sanitizeLaTexInput(p.Title)})
+			for _, nested := range p.Panels {
+				dash = addV5Panel(dash, nested)
+			}
 			continue
 		}
-		p.Title = sanitizeLaTexInput(p.Title)
-		dash.Panels = append(dash.Panels, p)
+		dash = addV5Panel(dash, p)
 	}
 	return dash
 }
 
+// addV5Panel adds p to Panels and to the last row
+func addV5Panel(dash Dashboard, p Panel) Dashboard {
+	p.Title = sanitizeLaTexInput(p.Title)
+	p.Panels = nil
+	dash.Panels = append(dash.Panels, p)
+	if len(dash.Rows) == 0 {
+		dash.Rows = append(dash.Rows, Row{})
+	}
+	row := &dash.Rows[len(dash.Rows)-1]
+	row.Panels = append(row.Panels, p)
+	return dash
+}
+
+// VariableOptions returns the values of the options of the named template variable, without the All option
+func (d Dashboard) VariableOptions(name string) []string {
+	var values []string
+	for _, v := range d.Templating.List {
+		if v.Name != name {
+			continue
+		}
+		for _, o := range v.Options {
+			if value, ok := o.Value.(string); ok && value != allValue {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// IsAll reports whether the values of a template variable select all of its options
+func IsAll(values []string) bool {
+	for _, v := range values {
+		if v == allValue || v == "All" {
+			return true
+		}
+	}
+	return false
+}
+
 func (p Panel) IsSingleStat() bool {
 	return p.Is(SingleStat)
 }
@@ -167,6 +251,15 @@ func getVariablesValues(variables url.Values) string {
 	return strings.Join(values, ", ")
 }
 
+// rtlRun matches a run of right-to-left (Hebrew or Arabic) text, including the spaces, digits and punctuation inside it
+var rtlRun = regexp.MustCompile(`[\p{Hebrew}\p{Arabic}](?:[\p{Hebrew}\p{Arabic}\s\d\p{P}\p{S}]*[\p{Hebrew}\p{Arabic}])?`)
+
+// rtlCommand matches the \RL{...} command sanitizeLaTexInput wraps right-to-left text in
+var rtlCommand = regexp.MustCompile(`\\RL\{((?:\\.|[^\\}])*)\}`)
+
+// sanitizeLaTexInput escapes the characters that are special to TeX, turns line breaks into \newline
+// and marks right-to-left text with \RL{...}. Other Unicode characters are passed through unchanged:
+// they require a Unicode TeX engine (xelatex or lualatex) and a font that covers them.
 func sanitizeLaTexInput(input string) string {
 	input = strings.Replace(input, "\\", "\\textbackslash ", -1)
 	input = strings.Replace(input, "&", "\\&", -1)
@@ -178,5 +271,33 @@ func sanitizeLaTexInput(input string) string {
 	input = strings.Replace(input, "}", "\\}", -1)
 	input = strings.Replace(input, "~", "\\textasciitilde ", -1)
 	input = strings.Replace(input, "^", "\\textasciicircum ", -1)
+	input = strings.Replace(input, "\r\n", "\n", -1)
+	input = strings.Replace(input, "\n", "\\newline{}", -1)
+	input = rtlRun.ReplaceAllString(input, "\\RL{$0}")
 	return input
 }
+
+var latexUnescaper = strings.NewReplacer(
+	"\\textbackslash ", "\\",
+	"\\&", "&",
+	"\\%", "%",
+	"\\$", "$",
+	"\\#", "#",
+	"\\_", "_",
+	"\\{", "{",
+	"\\}", "}",
+	"\\textasciitilde ", "~",
+	"\\textasciicircum ", "^",
+	"\\newline{}", "\n",
+)
+
+// EscapeLaTeX escapes text for inclusion in a TeX document, the way dashboard titles are escaped
+func EscapeLaTeX(text string) string {
+	return sanitizeLaTexInput(text)
+}
+
+// PlainText reverses the TeX escaping applied to dashboard, row and panel titles,
+// for output formats that are not typeset with LaTeX
+func PlainText(sanitized string) string {
+	return latexUnescaper.Replace(rtlCommand.ReplaceAllString(sanitized, "$1"))
+}
@@ -87,8 +87,11 @@ func TestV5Dashboard(t *testing.T) {
 			{"Type":"singlestat", "Id":2, "Title":"Panel3Title #"},
 			{"Type":"text", "GridPos":{"H":6.5,"W":20.5,"X":0,"Y":0}, "Id":3},
 			{"Type":"table", "Id":4},
-			{"Type":"row", "Id":5}],
-		"Title":"DashTitle #"
+			{"Type":"row", "Id":5, "Title":"RowTitle"}],
+		"Title":"DashTitle #",
+		"uid":"rYy7Paekz",
+		"version":7,
+		"tags":["prod", "db_1"]
 	},
 
 "Meta":
@@ -119,11 +122,32 @@ func TestV5Dashboard(t *testing.T) {
 			So(dash.Title, ShouldEqual, "DashTitle \\#")
 		})
 
+		Convey("The UID should be parsed", func() {
+			So(dash.UID, ShouldEqual, "rYy7Paekz")
+		})
+
+		Convey("The version should be parsed", func() {
+			So(dash.Version, ShouldEqual, 7)
+		})
+
+		Convey("The tags should be parsed and sanitised", func() {
+			So(dash.Tags, ShouldResemble, []string{"prod", "db\\_1"})
+		})
+
 		Convey("Panels should contain GridPos H & W", func() {
 			So(dash.Panels[1].GridPos.H, ShouldEqual, 6)
 			So(dash.Panels[1].GridPos.W, ShouldEqual, 24)
 		})
 
+		Convey("Rows should group the panels that follow each row panel", func() {
+			So(dash.Rows, ShouldHaveLength, 2)
+			So(dash.Rows[0].IsVisible(), ShouldBeFalse)
+			So(dash.Rows[0].Panels, ShouldHaveLength, 5)
+			So(dash.Rows[1].Id, ShouldEqual, 5)
+			So(dash.Rows[1].IsVisible(), ShouldBeTrue)
+			So(dash.Rows[1].Panels, ShouldHaveLength, 0)
+		})
+
 		Convey("Panels GridPos should allow floatt", func() {
 			So(dash.Panels[3].GridPos.H, ShouldEqual, 6.5)
 			So(dash.Panels[3].GridPos.W, ShouldEqual, 20.5)
@@ -132,6 +156,49 @@ func TestV5Dashboard(t *testing.T) {
 	})
 }
 
+func TestV5DashboardCollapsedRow(t *testing.T) {
+	Convey("When creating a new dashboard from Grafana v5 dashboard JSON with a collapsed row", t, func() {
+		const v5DashJSON = `
+{"Dashboard":
+	{
+		"Panels":
+			[{"Type":"graph", "Id":1, "GridPos":{"H":6,"W":24,"X":0,"Y":0}},
+			{"Type":"row", "Id":2, "Title":"Collapsed #", "Collapsed":true, "GridPos":{"H":1,"W":24,"X":0,"Y":6},
+				"Panels":
+					[{"Type":"singlestat", "Id":3, "Title":"Nested #", "GridPos":{"H":4,"W":12,"X":0,"Y":7}},
+					{"Type":"table", "Id":4, "GridPos":{"H":4,"W":12,"X":12,"Y":7}}]},
+			{"Type":"row", "Id":5, "Title":"Expanded", "GridPos":{"H":1,"W":24,"X":0,"Y":7}},
+			{"Type":"text", "Id":6, "GridPos":{"H":3,"W":24,"X":0,"Y":8}}],
+		"Title":"DashTitle"
+	},
+
+"Meta":
+	{"Slug":"testDash"}
+}`
+		dash := NewDashboard([]byte(v5DashJSON), url.Values{})
+
+		Convey("Panels should contain the panels nested in the collapsed row", func() {
+			So(dash.Panels, ShouldHaveLength, 4)
+			So(dash.Panels[1].Id, ShouldEqual, 3)
+			So(dash.Panels[1].Title, ShouldEqual, "Nested \\#")
+			So(dash.Panels[1].GridPos.W, ShouldEqual, 12)
+			So(dash.Panels[2].Is(Table), ShouldBeTrue)
+			So(dash.Panels[3].Id, ShouldEqual, 6)
+		})
+
+		Convey("The collapsed row should group its nested panels", func() {
+			So(dash.Rows, ShouldHaveLength, 3)
+			So(dash.Rows[1].Id, ShouldEqual, 2)
+			So(dash.Rows[1].Title, ShouldEqual, "Collapsed \\#")
+			So(dash.Rows[1].Panels, ShouldHaveLength, 2)
+			So(dash.Rows[1].Panels[0].Id, ShouldEqual, 3)
+			So(dash.Rows[1].Panels[1].Id, ShouldEqual, 4)
+			So(dash.Rows[2].Panels, ShouldHaveLength, 1)
+			So(dash.Rows[2].Panels[0].Id, ShouldEqual, 6)
+		})
+	})
+}
+
 func TestVariableValues(t *testing.T) {
 	Convey("When creating a dashboard and passing url varialbes in", t, func() {
 		const v5DashJSON = `
@@ -151,3 +218,77 @@ func TestVariableValues(t *testing.T) {
 		})
 	})
 }
+
+func TestVariableOptions(t *testing.T) {
+	Convey("When creating a dashboard with template variables", t, func() {
+		const dashJSON = `
+{
+	"Dashboard":
+		{
+			"templating": {"list": [
+				{"name": "host", "options": [
+					{"text": "All", "value": "$__all"},
+					{"text": "db1", "value": "db1"},
+					{"text": "db2", "value": "db2"}
+				]},
+				{"name": "region", "options": []}
+			]}
+		}
+}`
+		dash := NewDashboard([]byte(dashJSON), url.Values{})
+
+		Convey("The options of a variable should be listed without the All option", func() {
+			So(dash.VariableOptions("host"), ShouldResemble, []string{"db1", "db2"})
+		})
+
+		Convey("Variables without options or unknown variables should have none", func() {
+			So(dash.VariableOptions("region"), ShouldBeEmpty)
+			So(dash.VariableOptions("port"), ShouldBeEmpty)
+		})
+
+		Convey("The All value should be recognised", func() {
+			So(IsAll([]string{"$__all"}), ShouldBeTrue)
+			So(IsAll([]string{"db1"}), ShouldBeFalse)
+		})
+	})
+}
+
+func TestSanitizeLaTexInput(t *testing.T) {
+	Convey("When sanitizing text for TeX", t, func() {
+		Convey("It should escape special characters", func() {
+			So(sanitizeLaTexInput(`50% & #1`), ShouldEqual, `50\% \& \#1`)
+		})
+
+		Convey("It should turn line breaks into \\newline", func() {
+			So(sanitizeLaTexInput("a\r\nb\nc"), ShouldEqual, `a\newline{}b\newline{}c`)
+		})
+
+		Convey("It should pass other Unicode characters through", func() {
+			So(sanitizeLaTexInput("Привет 日本 🚀"), ShouldEqual, "Привет 日本 🚀")
+		})
+
+		Convey("It should wrap right-to-left runs, including the spaces and digits inside them, in \\RL", func() {
+			So(sanitizeLaTexInput("Load שרת 12 ראשי (%)"), ShouldEqual, `Load \RL{שרת 12 ראשי} (\%)`)
+			So(sanitizeLaTexInput("لوحة"), ShouldEqual, `\RL{لوحة}`)
+		})
+	})
+}
+
+func TestPlainText(t *testing.T) {
+	Convey("When converting sanitised TeX input back to plain text", t, func() {
+		input := `Title #1 & 50% of $ {a_b} \ ~^`
+
+		Convey("It should reverse sanitizeLaTexInput", func() {
+			So(PlainText(sanitizeLaTexInput(input)), ShouldEqual, input)
+		})
+
+		Convey("It should leave escaped backslashes followed by special characters intact", func() {
+			So(PlainText(sanitizeLaTexInput(`\&`)), ShouldEqual, `\&`)
+		})
+
+		Convey("It should reverse line breaks and right-to-left markup", func() {
+			input := "שלום עולם 2018!\nHello & مرحبا"
+			So(PlainText(sanitizeLaTexInput(input)), ShouldEqual, input)
+		})
+	})
+}
@@ -0,0 +1,51 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package grafana
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const shiftRegExp = "^[0-9]+[mhdwMy]$"
+
+// Shift returns the time range moved back by shift, e.g. 1w for the week before, or 1y for the same period last year.
+// A shift is a number followed by one of the units of relative times: m, h, d, w, M or y.
+// The shifted range is absolute: relative times are resolved when Shift is called.
+func (tr TimeRange) Shift(shift string) (TimeRange, error) {
+	if err := ValidateShift(shift); err != nil {
+		return tr, err
+	}
+	from := now(tr.FromTime()).parseRelativeTime("now-" + shift)
+	to := now(tr.ToTime()).parseRelativeTime("now-" + shift)
+	return TimeRange{formatAbsTime(from), formatAbsTime(to)}, nil
+}
+
+// ValidateShift checks that shift is a number followed by one of the units of relative times
+func ValidateShift(shift string) error {
+	if matched, _ := regexp.MatchString(shiftRegExp, shift); !matched {
+		return fmt.Errorf("invalid time shift %q, expected a number followed by one of m, h, d, w, M or y", shift)
+	}
+	return nil
+}
+
+// formatAbsTime formats t as a unix time in milliseconds, the absolute time format of Grafana
+func formatAbsTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+}
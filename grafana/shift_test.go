@@ -0,0 +1,67 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package grafana
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTimeRangeShift(t *testing.T) {
+	Convey("When shifting a time range", t, func() {
+		from := time.Date(2016, time.March, 31, 10, 0, 0, 0, time.Local)
+		to := from.Add(time.Hour)
+		tr := TimeRange{formatAbsTime(from), formatAbsTime(to)}
+
+		Convey("Both ends should move back by the shift", func() {
+			s, err := tr.Shift("1w")
+			So(err, ShouldBeNil)
+			So(s.FromTime(), sameTimeAs, from.AddDate(0, 0, -7))
+			So(s.ToTime(), sameTimeAs, to.AddDate(0, 0, -7))
+
+			s, err = tr.Shift("90m")
+			So(err, ShouldBeNil)
+			So(s.FromTime(), sameTimeAs, from.Add(-90*time.Minute))
+		})
+
+		Convey("Calendar units should follow the calendar", func() {
+			s, err := tr.Shift("1y")
+			So(err, ShouldBeNil)
+			So(s.FromTime(), sameTimeAs, from.AddDate(-1, 0, 0))
+
+			s, err = tr.Shift("1M")
+			So(err, ShouldBeNil)
+			So(s.FromTime(), sameTimeAs, from.AddDate(0, -1, 0))
+		})
+
+		Convey("Relative ranges should be resolved to absolute times", func() {
+			s, err := TimeRange{"now-1h", "now"}.Shift("1d")
+			So(err, ShouldBeNil)
+			So(isRelativeTime(s.From), ShouldBeFalse)
+			So(s.ToTime(), ShouldHappenWithin, time.Minute, time.Now().AddDate(0, 0, -1))
+		})
+
+		Convey("Invalid shifts should be rejected", func() {
+			for _, shift := range []string{"", "1", "w", "-1w", "1s", "1w2d"} {
+				_, err := tr.Shift(shift)
+				So(err, ShouldNotBeNil)
+			}
+		})
+	})
+}
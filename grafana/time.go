@@ -80,6 +80,16 @@ func (tr TimeRange) ToFormatted() string {
 	return n.parseTo(tr.To).Format(time.UnixDate)
 }
 
+// FromTime resolves the Grafana 'From' time spec into an absolute time
+func (tr TimeRange) FromTime() time.Time {
+	return newNow().parseFrom(tr.From)
+}
+
+// ToTime resolves the Grafana 'To' time spec into an absolute time
+func (tr TimeRange) ToTime() time.Time {
+	return newNow().parseTo(tr.To)
+}
+
 func newNow() now {
 	return now(time.Now())
 }
@@ -94,6 +94,12 @@ func TestTimeParsing(tst *testing.T) {
 		So(t.parseTo("1463464226537"), sameTimeAs, time.Unix(1463464226537/1000, 0))
 	})
 
+	Convey("TimeRange should resolve absolute times", tst, func() {
+		tr := NewTimeRange("1463464226537", "1463472462258")
+		So(tr.FromTime(), sameTimeAs, time.Unix(1463464226, 0))
+		So(tr.ToTime(), sameTimeAs, time.Unix(1463472462, 0))
+	})
+
 	Convey("Should panic on accept unrecognised formats", tst, func() {
 		So(func() { t.parseTo("not-a-time") }, ShouldPanic)
 		So(func() { t.parseTo("now-43k") }, ShouldPanic)
@@ -0,0 +1,389 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+// Package pdf is a minimal PDF writer. It supports what the reporter needs to lay out
+// a report without a TeX installation: pages, raster images, text in the standard
+// Helvetica fonts and bookmarks.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg" //register jpeg decoder
+	_ "image/png"  //register png decoder
+	"io"
+	"strings"
+)
+
+// Page sizes in points (1/72 inch)
+const (
+	A4Width      = 595.28
+	A4Height     = 841.89
+	LetterWidth  = 612
+	LetterHeight = 792
+	A3Width      = 841.89
+	A3Height     = 1190.55
+)
+
+// Font selects one of the standard Type1 fonts every PDF viewer provides
+type Font int
+
+const (
+	Helvetica Font = iota
+	HelveticaBold
+)
+
+func (f Font) baseFont() string {
+	return [...]string{
+		"Helvetica",
+		"Helvetica-Bold",
+	}[f]
+}
+
+// Document is a PDF document under construction
+type Document struct {
+	Width     float64
+	Height    float64
+	Info      Info
+	pages     []*Page
+	images    []*Image
+	bookmarks []bookmark
+}
+
+// bookmark is an entry of the document outline
+type bookmark struct {
+	title string
+	page  *Page
+	y     float64
+	// parent is the index of the enclosing bookmark, or -1 for a top level one
+	parent int
+}
+
+// Info holds the PDF document information dictionary entries
+type Info struct {
+	Title    string
+	Author   string
+	Subject  string
+	Keywords string
+	Creator  string
+}
+
+// Page is a single page of a Document.
+// All coordinates are in points, measured from the top left corner of the page.
+type Page struct {
+	// Width and Height are the page size in points, the Document's unless added with AddPageSize
+	Width   float64
+	Height  float64
+	doc     *Document
+	content bytes.Buffer
+	images  map[*Image]bool
+}
+
+// Image is a raster image that can be drawn on any page of the Document it was added to
+type Image struct {
+	// Width and Height are the dimensions of the image in pixels
+	Width  int
+	Height int
+	name   string
+	data   []byte
+}
+
+// New creates an empty document with pages of the given size in points
+func New(width, height float64) *Document {
+	return &Document{Width: width, Height: height}
+}
+
+// AddPage appends a new blank page to the document
+func (d *Document) AddPage() *Page {
+	return d.AddPageSize(d.Width, d.Height)
+}
+
+// AddPageSize appends a new blank page of the given size in points, e.g. a landscape page
+func (d *Document) AddPageSize(width, height float64) *Page {
+	p := &Page{Width: width, Height: height, doc: d, images: map[*Image]bool{}}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// Pages returns the pages added to the document so far
+func (d *Document) Pages() []*Page {
+	return d.pages
+}
+
+// AddBookmark adds an entry to the document outline, which PDF viewers show as a navigation pane,
+// that jumps to y on page p. Nested bookmarks belong to the previous bookmark that is not nested,
+// or are top level if there is none.
+func (d *Document) AddBookmark(title string, p *Page, y float64, nested bool) {
+	parent := -1
+	if nested {
+		for i := len(d.bookmarks) - 1; i >= 0; i-- {
+			if d.bookmarks[i].parent == -1 {
+				parent = i
+				break
+			}
+		}
+	}
+	d.bookmarks = append(d.bookmarks, bookmark{title, p, y, parent})
+}
+
+// AddImage decodes a PNG or JPEG image so that it can be drawn on the document's pages.
+// Transparent areas are composed onto a white background.
+func (d *Document) AddImage(r io.Reader) (*Image, error) {
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image: %v", err)
+	}
+	b := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Over)
+
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	rgb := make([]byte, 0, 3*b.Dx())
+	for y := 0; y < b.Dy(); y++ {
+		rgb = rgb[:0]
+		row := rgba.Pix[y*rgba.Stride : y*rgba.Stride+4*b.Dx()]
+		for x := 0; x < len(row); x += 4 {
+			rgb = append(rgb, row[x], row[x+1], row[x+2])
+		}
+		if _, err := zw.Write(rgb); err != nil {
+			return nil, fmt.Errorf("error compressing image: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("error compressing image: %v", err)
+	}
+
+	img := &Image{
+		Width:  b.Dx(),
+		Height: b.Dy(),
+		name:   fmt.Sprintf("Im%d", len(d.images)+1),
+		data:   buf.Bytes(),
+	}
+	d.images = append(d.images, img)
+	return img, nil
+}
+
+// DrawImage draws img with its top left corner at (x, y), scaled to w x h points
+func (p *Page) DrawImage(img *Image, x, y, w, h float64) {
+	p.images[img] = true
+	fmt.Fprintf(&p.content, "q %.2f 0 0 %.2f %.2f %.2f cm /%s Do Q\n", w, h, x, p.Height-y-h, img.name)
+}
+
+// SetColor sets the colour of the text drawn after it on the page
+func (p *Page) SetColor(c color.Color) {
+	r, g, b, _ := c.RGBA()
+	fmt.Fprintf(&p.content, "%.3f %.3f %.3f rg\n", float64(r)/0xffff, float64(g)/0xffff, float64(b)/0xffff)
+}
+
+// Text draws s with the baseline starting at (x, y)
+func (p *Page) Text(x, y float64, font Font, size float64, s string) {
+	fmt.Fprintf(&p.content, "BT /F%d %.2f Tf %.2f %.2f Td (%s) Tj ET\n", int(font)+1, size, x, p.Height-y, escape(s))
+}
+
+// TextCentered draws s horizontally centred on the page with the baseline at y
+func (p *Page) TextCentered(y float64, font Font, size float64, s string) {
+	p.Text((p.Width-TextWidth(s, size))/2, y, font, size, s)
+}
+
+// Write serialises the document to w
+func (d *Document) Write(w io.Writer) error {
+	pw := &writer{}
+	pw.buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Object numbers are assigned up front so that objects can reference each other:
+	// 1 catalog, 2 page tree, 3 info, fonts, images, then a page and its content per page.
+	const catalogObj, pagesObj, infoObj = 1, 2, 3
+	fontObj := func(f Font) int { return 4 + int(f) }
+	fonts := []Font{Helvetica, HelveticaBold}
+	imageObj := map[*Image]int{}
+	next := 4 + len(fonts)
+	for _, img := range d.images {
+		imageObj[img] = next
+		next++
+	}
+	pageObj := func(i int) int { return next + 2*i }
+	pageIndex := map[*Page]int{}
+	for i, p := range d.pages {
+		pageIndex[p] = i
+	}
+	outlinesObj := pageObj(len(d.pages))
+	bookmarkObj := func(i int) int { return outlinesObj + 1 + i }
+
+	if len(d.bookmarks) > 0 {
+		pw.object(catalogObj, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R /Outlines %d 0 R /PageMode /UseOutlines >>", pagesObj, outlinesObj))
+	} else {
+		pw.object(catalogObj, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObj))
+	}
+
+	kids := []string{}
+	for i := range d.pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", pageObj(i)))
+	}
+	pw.object(pagesObj, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d /MediaBox [0 0 %.2f %.2f] >>",
+		strings.Join(kids, " "), len(d.pages), d.Width, d.Height))
+
+	pw.object(infoObj, d.Info.dictionary())
+
+	for _, f := range fonts {
+		pw.object(fontObj(f), fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", f.baseFont()))
+	}
+
+	for _, img := range d.images {
+		pw.stream(imageObj[img], fmt.Sprintf("/Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode",
+			img.Width, img.Height), img.data)
+	}
+
+	for i, p := range d.pages {
+		xobjects := ""
+		for _, img := range d.images {
+			if p.images[img] {
+				xobjects += fmt.Sprintf(" /%s %d 0 R", img.name, imageObj[img])
+			}
+		}
+		fontRefs := ""
+		for _, f := range fonts {
+			fontRefs += fmt.Sprintf(" /F%d %d 0 R", int(f)+1, fontObj(f))
+		}
+		mediaBox := ""
+		if p.Width != d.Width || p.Height != d.Height {
+			mediaBox = fmt.Sprintf(" /MediaBox [0 0 %.2f %.2f]", p.Width, p.Height)
+		}
+		pw.object(pageObj(i), fmt.Sprintf("<< /Type /Page /Parent %d 0 R%s /Resources << /Font <<%s >> /XObject <<%s >> >> /Contents %d 0 R >>",
+			pagesObj, mediaBox, fontRefs, xobjects, pageObj(i)+1))
+		pw.stream(pageObj(i)+1, "", p.content.Bytes())
+	}
+
+	if len(d.bookmarks) > 0 {
+		d.writeOutline(pw, outlinesObj, bookmarkObj, func(p *Page) int { return pageObj(pageIndex[p]) })
+	}
+
+	pw.trailer(catalogObj, infoObj)
+	_, err := pw.buf.WriteTo(w)
+	return err
+}
+
+// writeOutline writes the outline dictionary and an item per bookmark, linked to their parent and siblings
+func (d *Document) writeOutline(pw *writer, outlinesObj int, bookmarkObj func(int) int, pageObj func(*Page) int) {
+	children := map[int][]int{}
+	for i, b := range d.bookmarks {
+		children[b.parent] = append(children[b.parent], i)
+	}
+	links := func(kids []int) string {
+		if len(kids) == 0 {
+			return ""
+		}
+		return fmt.Sprintf(" /First %d 0 R /Last %d 0 R /Count %d", bookmarkObj(kids[0]), bookmarkObj(kids[len(kids)-1]), len(kids))
+	}
+	pw.object(outlinesObj, "<< /Type /Outlines"+links(children[-1])+" >>")
+	for i, b := range d.bookmarks {
+		parent := outlinesObj
+		if b.parent >= 0 {
+			parent = bookmarkObj(b.parent)
+		}
+		siblings := ""
+		kids := children[b.parent]
+		for k, j := range kids {
+			if j != i {
+				continue
+			}
+			if k > 0 {
+				siblings += fmt.Sprintf(" /Prev %d 0 R", bookmarkObj(kids[k-1]))
+			}
+			if k < len(kids)-1 {
+				siblings += fmt.Sprintf(" /Next %d 0 R", bookmarkObj(kids[k+1]))
+			}
+		}
+		pw.object(bookmarkObj(i), fmt.Sprintf("<< /Title (%s) /Parent %d 0 R%s%s /Dest [%d 0 R /XYZ 0 %.2f null] >>",
+			escape(b.title), parent, siblings, links(children[i]), pageObj(b.page), b.page.Height-b.y))
+	}
+}
+
+func (i Info) dictionary() string {
+	entries := []string{}
+	add := func(key, value string) {
+		if value != "" {
+			entries = append(entries, fmt.Sprintf("/%s (%s)", key, escape(value)))
+		}
+	}
+	add("Title", i.Title)
+	add("Author", i.Author)
+	add("Subject", i.Subject)
+	add("Keywords", i.Keywords)
+	add("Creator", i.Creator)
+	return "<< " + strings.Join(entries, " ") + " >>"
+}
+
+type writer struct {
+	buf     bytes.Buffer
+	offsets map[int]int
+}
+
+func (pw *writer) object(num int, body string) {
+	pw.start(num)
+	pw.buf.WriteString(body)
+	pw.buf.WriteString("\nendobj\n")
+}
+
+func (pw *writer) stream(num int, dict string, data []byte) {
+	pw.start(num)
+	fmt.Fprintf(&pw.buf, "<< %s /Length %d >>\nstream\n", dict, len(data))
+	pw.buf.Write(data)
+	pw.buf.WriteString("\nendstream\nendobj\n")
+}
+
+func (pw *writer) start(num int) {
+	if pw.offsets == nil {
+		pw.offsets = map[int]int{}
+	}
+	pw.offsets[num] = pw.buf.Len()
+	fmt.Fprintf(&pw.buf, "%d 0 obj\n", num)
+}
+
+func (pw *writer) trailer(root, info int) {
+	xref := pw.buf.Len()
+	size := len(pw.offsets) + 1
+	fmt.Fprintf(&pw.buf, "xref\n0 %d\n0000000000 65535 f \n", size)
+	for i := 1; i < size; i++ {
+		fmt.Fprintf(&pw.buf, "%010d 00000 n \n", pw.offsets[i])
+	}
+	fmt.Fprintf(&pw.buf, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", size, root, info, xref)
+}
+
+// escape encodes s as the contents of a PDF literal string in WinAnsiEncoding.
+// Characters outside Latin-1 cannot be represented by the standard fonts and are replaced by '?'.
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n' || r == '\r' || r == '\t':
+			b.WriteByte(' ')
+		case r < 32 || r > 255:
+			b.WriteByte('?')
+		default:
+			b.WriteByte(byte(r))
+		}
+	}
+	return b.String()
+}
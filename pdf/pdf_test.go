@@ -0,0 +1,158 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package pdf
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func testPNG(w, h int) *bytes.Buffer {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h)))
+	return &buf
+}
+
+func TestDocument(t *testing.T) {
+	Convey("When writing a document with text and an image", t, func() {
+		doc := New(A4Width, A4Height)
+		doc.Info.Title = "My (first) report"
+		img, err := doc.AddImage(testPNG(20, 10))
+		So(err, ShouldBeNil)
+
+		p1 := doc.AddPage()
+		p1.Text(72, 72, HelveticaBold, 17, "Title")
+		p1.DrawImage(img, 72, 100, 200, 100)
+		doc.AddPage().DrawImage(img, 0, 0, 20, 10)
+
+		var buf bytes.Buffer
+		So(doc.Write(&buf), ShouldBeNil)
+		s := buf.String()
+
+		Convey("It should be a PDF file", func() {
+			So(s, ShouldStartWith, "%PDF-1.4")
+			So(s, ShouldEndWith, "%%EOF\n")
+		})
+
+		Convey("It should contain all pages", func() {
+			So(s, ShouldContainSubstring, "/Count 2")
+			So(strings.Count(s, "/Type /Page "), ShouldEqual, 2)
+		})
+
+		Convey("It should embed the image once with its pixel dimensions", func() {
+			So(img.Width, ShouldEqual, 20)
+			So(img.Height, ShouldEqual, 10)
+			So(strings.Count(s, "/Subtype /Image"), ShouldEqual, 1)
+			So(s, ShouldContainSubstring, "/Width 20 /Height 10")
+		})
+
+		Convey("It should convert top-left coordinates to PDF coordinates", func() {
+			So(s, ShouldContainSubstring, "q 200.00 0 0 100.00 72.00 641.89 cm /Im1 Do Q")
+		})
+
+		Convey("It should escape string delimiters in text", func() {
+			So(s, ShouldContainSubstring, `/Title (My \(first\) report)`)
+		})
+
+		Convey("It should write an xref table with an entry per object", func() {
+			So(s, ShouldContainSubstring, "xref\n0 11\n")
+		})
+	})
+
+	Convey("When adding bookmarks", t, func() {
+		doc := New(A4Width, A4Height)
+		p1 := doc.AddPage()
+		p2 := doc.AddPage()
+		doc.AddBookmark("Overview", p1, 100, true)
+		doc.AddBookmark("Database", p1, 200, false)
+		doc.AddBookmark("Queries", p2, 72, true)
+		doc.AddBookmark("Failures", p2, 400, false)
+
+		var buf bytes.Buffer
+		So(doc.Write(&buf), ShouldBeNil)
+		s := buf.String()
+
+		Convey("The viewer should show the outline", func() {
+			So(s, ShouldContainSubstring, "/Outlines 10 0 R /PageMode /UseOutlines")
+			So(s, ShouldContainSubstring, "10 0 obj\n<< /Type /Outlines /First 11 0 R /Last 14 0 R /Count 3 >>")
+		})
+
+		Convey("Nested bookmarks should belong to the previous top level bookmark", func() {
+			So(s, ShouldContainSubstring, "<< /Title (Database) /Parent 10 0 R /Prev 11 0 R /Next 14 0 R /First 13 0 R /Last 13 0 R /Count 1 /Dest [6 0 R /XYZ 0 641.89 null] >>")
+			So(s, ShouldContainSubstring, "<< /Title (Queries) /Parent 12 0 R /Dest [8 0 R /XYZ 0 769.89 null] >>")
+		})
+
+		Convey("Nested bookmarks before any top level one should be top level", func() {
+			So(s, ShouldContainSubstring, "<< /Title (Overview) /Parent 10 0 R /Next 12 0 R /Dest")
+		})
+	})
+
+	Convey("When adding a landscape page to a portrait document", t, func() {
+		doc := New(A4Width, A4Height)
+		img, _ := doc.AddImage(testPNG(20, 10))
+		doc.AddPage()
+		p := doc.AddPageSize(A4Height, A4Width)
+		p.DrawImage(img, 0, 0, 100, 50)
+
+		var buf bytes.Buffer
+		So(doc.Write(&buf), ShouldBeNil)
+		s := buf.String()
+
+		Convey("Only that page should have its own media box", func() {
+			So(strings.Count(s, "/MediaBox"), ShouldEqual, 2)
+			So(s, ShouldContainSubstring, "/MediaBox [0 0 841.89 595.28]")
+		})
+
+		Convey("It should place images from the top of that page", func() {
+			So(s, ShouldContainSubstring, "q 100.00 0 0 50.00 0.00 545.28 cm /Im1 Do Q")
+		})
+	})
+
+	Convey("When adding an invalid image", t, func() {
+		_, err := New(A4Width, A4Height).AddImage(strings.NewReader("Not actually a png"))
+
+		Convey("It should return an error", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestText(t *testing.T) {
+	Convey("When measuring and wrapping text", t, func() {
+		Convey("TextWidth should use the Helvetica metrics", func() {
+			So(TextWidth("Hi", 10), ShouldAlmostEqual, 9.44)
+		})
+
+		Convey("WrapText should break lines between words", func() {
+			lines := WrapText("aaa bbb ccc", 10, TextWidth("aaa bbb", 10))
+			So(lines, ShouldResemble, []string{"aaa bbb", "ccc"})
+		})
+
+		Convey("WrapText should keep explicit line breaks", func() {
+			So(WrapText("a\nb", 10, 1000), ShouldResemble, []string{"a", "b"})
+		})
+
+		Convey("escape should replace characters outside Latin-1", func() {
+			So(escape("café ✓"), ShouldEqual, "caf\xe9 ?")
+		})
+	})
+}
@@ -0,0 +1,216 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Branding is the corporate identity shown by the default templates
+type Branding struct {
+	// Logo is the path of a PNG or JPEG logo image, shown on the title or cover page and in the page header
+	Logo string `json:"logo"`
+	// Color is the primary colour of titles and headings, as #rrggbb
+	Color string `json:"color"`
+	// Company is the company name, shown on the title or cover page and in the page footer
+	Company string `json:"company"`
+	// Cover puts the title, logo and company name on a cover page of their own
+	Cover bool `json:"cover"`
+	// Disclaimer is printed at the bottom of the cover page, or at the end of reports without one
+	Disclaimer string `json:"disclaimer"`
+}
+
+// BrandingConfig holds the branding of a server, with overrides for some backends
+type BrandingConfig struct {
+	Branding
+	// Backends are the complete settings for the backends or formats that override some of the server's:
+	// BackendLaTeX and BackendNative for PDF reports, or the other formats, e.g. FormatHTML
+	Backends map[string]Branding
+}
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// LoadBranding reads a branding configuration file. It is a JSON object with the server's settings,
+// and a "backends" object of the settings that each backend overrides, e.g.
+//
+//	{"logo": "logo.png", "company": "Example Ltd", "cover": true, "backends": {"native": {"cover": false}}}
+//
+// Logo paths are relative to the directory of the file.
+func LoadBranding(path string) (BrandingConfig, error) {
+	var config BrandingConfig
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("error reading branding file %v: %v", path, err)
+	}
+	var file struct {
+		Branding
+		Backends map[string]json.RawMessage `json:"backends"`
+	}
+	if err = json.Unmarshal(data, &file); err != nil {
+		return config, fmt.Errorf("error parsing branding file %v: %v", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	config.Branding = file.Branding.relativeTo(dir)
+	config.Backends = map[string]Branding{}
+	for name, override := range file.Backends {
+		b := file.Branding
+		if err = json.Unmarshal(override, &b); err != nil {
+			return config, fmt.Errorf("error parsing branding of backend %v in %v: %v", name, path, err)
+		}
+		config.Backends[name] = b.relativeTo(dir)
+	}
+
+	if err = config.validate(); err != nil {
+		return config, fmt.Errorf("error in branding file %v: %v", path, err)
+	}
+	return config, nil
+}
+
+func (b Branding) relativeTo(dir string) Branding {
+	if b.Logo != "" && !filepath.IsAbs(b.Logo) {
+		b.Logo = filepath.Join(dir, b.Logo)
+	}
+	return b
+}
+
+// For returns the branding of a backend or format, see Backends
+func (c BrandingConfig) For(backend string) Branding {
+	if b, ok := c.Backends[backend]; ok {
+		return b
+	}
+	return c.Branding
+}
+
+func (c BrandingConfig) validate() error {
+	if err := c.Branding.validate(); err != nil {
+		return err
+	}
+	for name, b := range c.Backends {
+		if !brandingBackend(name) {
+			return fmt.Errorf("unknown branding backend %q, expected %q, %q or a format other than %q", name, BackendLaTeX, BackendNative, FormatPDF)
+		}
+		if err := b.validate(); err != nil {
+			return fmt.Errorf("backend %v: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func brandingBackend(name string) bool {
+	if name == BackendLaTeX || name == BackendNative {
+		return true
+	}
+	for _, f := range formats {
+		if name == f && f != FormatPDF {
+			return true
+		}
+	}
+	return false
+}
+
+func (b Branding) validate() error {
+	if b.Color != "" && !colorPattern.MatchString(b.Color) {
+		return fmt.Errorf("invalid branding colour %q, expected #rrggbb", b.Color)
+	}
+	if b.Logo != "" {
+		if _, err := logoMIMEType(b.Logo); err != nil {
+			return err
+		}
+		if _, err := os.Stat(b.Logo); err != nil {
+			return fmt.Errorf("error finding logo: %v", err)
+		}
+	}
+	return nil
+}
+
+// HexColor returns Color without the leading #, as expected by xcolor's HTML colour model
+func (b Branding) HexColor() string {
+	return strings.TrimPrefix(b.Color, "#")
+}
+
+func (b Branding) rgb() color.RGBA {
+	v, _ := strconv.ParseUint(b.HexColor(), 16, 32) //validated by newRenderer
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 0xff}
+}
+
+func logoMIMEType(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png":
+		return "image/png", nil
+	case ".jpg", ".jpeg":
+		return "image/jpeg", nil
+	}
+	return "", fmt.Errorf("unsupported logo image %v, expected a .png, .jpg or .jpeg file", path)
+}
+
+// brandingKey is the backend or format whose branding the report uses
+func (o Options) brandingKey() string {
+	switch o.Format {
+	case "", FormatPDF:
+		if o.Backend == "" {
+			return BackendLaTeX
+		}
+		return o.Backend
+	}
+	return o.Format
+}
+
+func (o Options) branding() Branding {
+	return o.Branding.For(o.brandingKey())
+}
+
+// logoFile is the name of the logo in the report directory, next to the TeX file
+func (b Branding) logoFile() string {
+	return "logo" + strings.ToLower(filepath.Ext(b.Logo))
+}
+
+// copyLogo copies the logo into the report directory for the TeX engine
+func (rep *report) copyLogo(b Branding) error {
+	data, err := ioutil.ReadFile(b.Logo)
+	if err != nil {
+		return fmt.Errorf("error reading logo: %v", err)
+	}
+	path := filepath.Join(rep.tmpDir, b.logoFile())
+	if err = ioutil.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("error copying logo to %v: %v", path, err)
+	}
+	return nil
+}
+
+// logoDataURI returns the logo as a data URI, for HTML reports
+func logoDataURI(b Branding) (template.URL, error) {
+	mime, err := logoMIMEType(b.Logo)
+	if err != nil {
+		return "", err
+	}
+	data, err := ioutil.ReadFile(b.Logo)
+	if err != nil {
+		return "", fmt.Errorf("error reading logo: %v", err)
+	}
+	return template.URL("data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data)), nil
+}
@@ -0,0 +1,209 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/IzakMarais/reporter/grafana"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func writeLogo(dir string) string {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 40, 20)))
+	path := filepath.Join(dir, "logo.png")
+	ioutil.WriteFile(path, buf.Bytes(), 0666)
+	return path
+}
+
+func TestLoadBranding(t *testing.T) {
+	Convey("When loading a branding file", t, func() {
+		dir, err := ioutil.TempDir("", "branding")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		writeLogo(dir)
+		write := func(json string) string {
+			path := filepath.Join(dir, "branding.json")
+			ioutil.WriteFile(path, []byte(json), 0666)
+			return path
+		}
+
+		Convey("Backends should override only the settings they set", func() {
+			c, err := LoadBranding(write(`{"logo": "logo.png", "color": "#004080", "company": "ACME", "cover": true,
+				"backends": {"native": {"cover": false}, "html": {"company": "ACME Web"}}}`))
+			So(err, ShouldBeNil)
+			So(c.Logo, ShouldEqual, filepath.Join(dir, "logo.png"))
+			So(c.For(BackendLaTeX), ShouldResemble, c.Branding)
+			So(c.For(BackendNative), ShouldResemble, Branding{c.Logo, "#004080", "ACME", false, ""})
+			So(c.For(FormatHTML).Company, ShouldEqual, "ACME Web")
+			So(c.For(FormatHTML).Cover, ShouldBeTrue)
+		})
+
+		Convey("The branding should be selected by backend or format", func() {
+			c := BrandingConfig{Branding{Company: "server"}, map[string]Branding{BackendNative: {Company: "native"}, FormatHTML: {Company: "html"}}}
+			So(Options{Branding: c}.branding().Company, ShouldEqual, "server")
+			So(Options{Branding: c, Backend: BackendNative}.branding().Company, ShouldEqual, "native")
+			So(Options{Branding: c, Backend: BackendNative, Format: FormatHTML}.branding().Company, ShouldEqual, "html")
+		})
+
+		Convey("Invalid settings should be errors", func() {
+			for _, json := range []string{
+				`{"color": "blue"}`,
+				`{"logo": "missing.png"}`,
+				`{"logo": "logo.gif"}`,
+				`{"backends": {"word": {}}}`,
+				`{"backends": {"native": {"color": "#12345"}}}`,
+				`{"company": `,
+			} {
+				_, err := LoadBranding(write(json))
+				So(err, ShouldNotBeNil)
+			}
+			_, err = LoadBranding(filepath.Join(dir, "missing.json"))
+			So(err, ShouldNotBeNil)
+			So(Options{Branding: BrandingConfig{Branding: Branding{Color: "red"}}}.Validate(), ShouldHaveSameTypeAs, &OptionsError{})
+		})
+	})
+}
+
+func TestBrandedReport(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "branding")
+	defer os.RemoveAll(dir)
+	logo := writeLogo(dir)
+	branding := Branding{Logo: logo, Color: "#004080", Company: "ACME & Sons", Disclaimer: "Confidential_"}
+
+	Convey("When generating the TeX file of a branded report", t, func() {
+		gClient := &mockGrafanaClient{0, url.Values{}}
+		rep := new(gClient, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Branding: BrandingConfig{Branding: branding}})
+		defer rep.Clean()
+		dash, _ := gClient.GetDashboard("")
+		So(rep.generateTeXFile(dash), ShouldBeNil)
+		b, _ := ioutil.ReadFile(rep.texPath())
+		s := string(b)
+
+		Convey("It should copy the logo next to the TeX file and show it in the title and header", func() {
+			_, err := os.Stat(filepath.Join(rep.tmpDir, "logo.png"))
+			So(err, ShouldBeNil)
+			So(s, ShouldContainSubstring, `\title{\includegraphics[height=2cm,keepaspectratio]{logo.png}`)
+			So(s, ShouldContainSubstring, `\fancyhead[L]{\small \includegraphics[height=10pt]{logo.png}\ My first dashboard}`)
+		})
+
+		Convey("It should define the brand colour for the title and headings", func() {
+			So(s, ShouldContainSubstring, `\definecolor{brand}{HTML}{004080}`)
+			So(s, ShouldContainSubstring, `\color{brand}My first dashboard`)
+		})
+
+		Convey("It should escape the company name and disclaimer", func() {
+			So(s, ShouldContainSubstring, `\author{ACME \& Sons}`)
+			So(s, ShouldContainSubstring, `\fancyfoot[L]{\small ACME \& Sons}`)
+			So(s, ShouldContainSubstring, `{\footnotesize Confidential\_\par}`)
+			So(s, ShouldNotContainSubstring, `titlepage`)
+		})
+	})
+
+	Convey("When generating the TeX file of a report with a cover page", t, func() {
+		cover := branding
+		cover.Cover = true
+		gClient := &mockGrafanaClient{0, url.Values{}}
+		rep := new(gClient, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"},
+			Options{GridLayout: true, Branding: BrandingConfig{Branding: cover}})
+		defer rep.Clean()
+		dash, _ := gClient.GetDashboard("")
+		So(rep.generateTeXFile(dash), ShouldBeNil)
+		b, _ := ioutil.ReadFile(rep.texPath())
+		s := string(b)
+
+		Convey("The title, company and disclaimer should be on the cover page", func() {
+			So(s, ShouldContainSubstring, `\begin{titlepage}`)
+			So(s, ShouldNotContainSubstring, `\maketitle`)
+			So(s, ShouldContainSubstring, "{\\large ACME \\& Sons\\par}\n\\vspace{0.5cm}\n{\\footnotesize Confidential\\_\\par}\n\\end{titlepage}")
+			So(strings.Count(s, `Confidential`), ShouldEqual, 1)
+		})
+	})
+
+	Convey("When generating a branded HTML report", t, func() {
+		gClient := &mockGrafanaClient{0, url.Values{}}
+		rep := new(gClient, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"},
+			Options{Format: FormatHTML, Branding: BrandingConfig{Branding: branding}})
+		defer rep.Clean()
+
+		html, err := rep.Generate()
+		So(err, ShouldBeNil)
+		defer html.Close()
+		var buf bytes.Buffer
+		io.Copy(&buf, html)
+		s := buf.String()
+
+		Convey("It should embed the logo and show the branding", func() {
+			So(s, ShouldContainSubstring, `<img class="logo" src="data:image/png;base64,`)
+			So(s, ShouldContainSubstring, `h1, h2 { color: #004080; }`)
+			So(s, ShouldContainSubstring, `<p class="company">ACME &amp; Sons</p>`)
+			So(s, ShouldContainSubstring, `<p class="disclaimer">Confidential_</p>`)
+		})
+	})
+
+	Convey("When generating a branded report with the native backend", t, func() {
+		cover := branding
+		cover.Cover = true
+		gClient := &pngClient{mockGrafanaClient{0, url.Values{}}}
+		rep := new(gClient, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"},
+			Options{Backend: BackendNative, Branding: BrandingConfig{Branding: cover}})
+		defer rep.Clean()
+
+		pdf, err := rep.Generate()
+		So(err, ShouldBeNil)
+		defer pdf.Close()
+		var buf bytes.Buffer
+		io.Copy(&buf, pdf)
+		s := buf.String()
+
+		Convey("It should draw the logo, the title in the brand colour and the company name", func() {
+			So(strings.Count(s, "/Subtype /Image"), ShouldEqual, 10)
+			So(s, ShouldContainSubstring, "0.000 0.251 0.502 rg\nBT /F2 24.00 Tf")
+			So(s, ShouldContainSubstring, "(ACME & Sons)")
+			So(s, ShouldContainSubstring, "(Confidential_)")
+		})
+	})
+
+	Convey("When generating a report with a cover page in grid layout with the native backend", t, func() {
+		gClient := &pngClient{mockGrafanaClient{0, url.Values{}}}
+		rep := new(gClient, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"},
+			Options{Backend: BackendNative, GridLayout: true, Branding: BrandingConfig{Branding: Branding{Cover: true}}})
+		defer rep.Clean()
+
+		pdf, err := rep.Generate()
+		So(err, ShouldBeNil)
+		defer pdf.Close()
+		var buf bytes.Buffer
+		io.Copy(&buf, pdf)
+		s := buf.String()
+
+		Convey("It should fill the first page after the cover, without room for the title", func() {
+			So(s, ShouldContainSubstring, "/Count 3 ")
+			So(s, ShouldContainSubstring, "(Page 1 of 2)")
+		})
+	})
+}
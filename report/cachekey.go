@@ -0,0 +1,86 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+)
+
+// CacheKey identifies the report by everything its content depends on: the dashboard UID and version,
+// the time range resolved to absolute times, the variables, the templates, the locale and the options.
+// Relative time ranges such as now-1h resolve to different times on every request, while rounded
+// ones such as now/d resolve to the same times until the period ends.
+// CacheKey fetches the dashboard, which Generate() then reuses.
+func (rep *report) CacheKey() (string, error) {
+	dash, err := rep.dashboard()
+	if err != nil {
+		return "", err
+	}
+	sources, err := rep.templateSources("", TemplateExtension(rep.opts.Format))
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "dashboard %q %q %d\n", rep.dashName, dash.UID, dash.Version)
+	fmt.Fprintf(h, "time %d %d\n", rep.time.FromTime().UnixNano(), rep.time.ToTime().UnixNano())
+	fmt.Fprintf(h, "variables %q\n", dash.Variables.Encode())
+	for _, src := range sources {
+		fmt.Fprintf(h, "template %q %d\n%s\n", src.name, len(src.text), src.text)
+	}
+	l := rep.locale()
+	fmt.Fprintf(h, "locale %q %q\n", l.name, l.messages)
+	rep.opts.writeCacheKey(h)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeCacheKey writes the options that affect the content of reports.
+// Options that only affect how reports are produced, such as DiagnosticsRetention, are left out.
+func (o Options) writeCacheKey(w io.Writer) {
+	fmt.Fprintf(w, "grid %v strict %v toc %v rowbreak %v\n", o.GridLayout, o.Strict, o.TOC, o.RowBreak)
+	fmt.Fprintf(w, "paper %q orientation %q margin %q\n", o.Paper, o.Orientation, o.Margin)
+	fmt.Fprintf(w, "filter %+v\n", o.Filter)
+	for _, t := range o.Compare {
+		fmt.Fprintf(w, "compare %d %d\n", t.FromTime().UnixNano(), t.ToTime().UnixNano())
+	}
+	b := o.branding()
+	fmt.Fprintf(w, "branding %+v\n", b)
+	if b.Logo != "" {
+		writeFileKey(w, b.Logo)
+	}
+	fmt.Fprintf(w, "compareShift %q layout %q\n", o.CompareShift, o.CompareLayout)
+	fmt.Fprintf(w, "backend %q format %q slides %q\n", o.Backend, o.Format, o.Slides)
+	fmt.Fprintf(w, "engine %q fonts %q font %q\n", o.Engine, o.FontsDir, o.MainFont)
+	fmt.Fprintf(w, "url %q user %q version %q\n", o.PublicURL, o.User, o.Version)
+	//fmt prints maps sorted by key
+	fmt.Fprintf(w, "meta %q\n", o.Meta)
+}
+
+// writeFileKey writes the content of a file, which can be replaced at the same path while the server runs.
+// Unreadable files are left out, as the report fails to generate with them.
+func writeFileKey(w io.Writer, path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	io.Copy(w, f)
+}
@@ -0,0 +1,85 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/IzakMarais/reporter/grafana"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCacheKey(t *testing.T) {
+	Convey("When computing a report's cache key", t, func() {
+		key := func(version int, from string, variables url.Values, opts Options) string {
+			g, err := NewSampleClient([]byte(fmt.Sprintf(`{"uid": "abc", "version": %d, "panels": [{"type": "graph", "id": 1}]}`, version)), variables, false)
+			So(err, ShouldBeNil)
+			k, err := new(g, "abc", grafana.NewTimeRange(from, "1453213647000"), opts).CacheKey()
+			So(err, ShouldBeNil)
+			return k
+		}
+		base := key(1, "1453206447000", url.Values{}, Options{})
+
+		Convey("It should be the same for the same report", func() {
+			So(key(1, "1453206447000", url.Values{}, Options{}), ShouldEqual, base)
+		})
+
+		Convey("It should change with the dashboard version", func() {
+			So(key(2, "1453206447000", url.Values{}, Options{}), ShouldNotEqual, base)
+		})
+
+		Convey("It should change with the time range", func() {
+			So(key(1, "1453206448000", url.Values{}, Options{}), ShouldNotEqual, base)
+		})
+
+		Convey("It should change with the variables", func() {
+			So(key(1, "1453206447000", url.Values{"var-host": {"a"}}, Options{}), ShouldNotEqual, base)
+		})
+
+		Convey("It should change with the template and options that affect the content", func() {
+			So(key(1, "1453206447000", url.Values{}, Options{Template: "[[.Title]]"}), ShouldNotEqual, base)
+			So(key(1, "1453206447000", url.Values{}, Options{Format: FormatHTML}), ShouldNotEqual, base)
+			So(key(1, "1453206447000", url.Values{}, Options{Meta: map[string]string{"a": "b"}}), ShouldNotEqual, base)
+			So(key(1, "1453206447000", url.Values{}, Options{Paper: PaperA3}), ShouldNotEqual, base)
+			So(key(1, "1453206447000", url.Values{}, Options{Locale: LocaleFrench}), ShouldNotEqual, base)
+			So(key(1, "1453206447000", url.Values{}, Options{Filter: PanelFilter{Types: []string{"graph"}}}), ShouldNotEqual, base)
+			So(key(1, "1453206447000", url.Values{}, Options{CompareShift: []string{"1w"}}), ShouldNotEqual, base)
+			compare := []grafana.TimeRange{{From: "1453206447000", To: "1453210047000"}}
+			So(key(1, "1453206447000", url.Values{}, Options{Compare: compare}), ShouldNotEqual, base)
+		})
+
+		Convey("It should change with the content of the logo, at the same path", func() {
+			logo := filepath.Join(os.TempDir(), "cachekey_logo.png")
+			defer os.Remove(logo)
+			opts := Options{Branding: BrandingConfig{Branding: Branding{Logo: logo}}}
+			So(ioutil.WriteFile(logo, []byte("first logo"), 0644), ShouldBeNil)
+			first := key(1, "1453206447000", url.Values{}, opts)
+			So(ioutil.WriteFile(logo, []byte("second logo"), 0644), ShouldBeNil)
+			So(key(1, "1453206447000", url.Values{}, opts), ShouldNotEqual, first)
+		})
+
+		Convey("It should not change with options that only affect how the report is produced", func() {
+			So(key(1, "1453206447000", url.Values{}, Options{DiagnosticsRetention: time.Minute}), ShouldEqual, base)
+		})
+	})
+}
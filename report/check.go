@@ -0,0 +1,99 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+
+	"github.com/IzakMarais/reporter/grafana"
+)
+
+// The stages of a template check, in order
+const (
+	StageDashboard = "dashboard"
+	StageImages    = "images"
+	StageParse     = "parse"
+	StageExecute   = "execute"
+	StageCompile   = "compile"
+)
+
+// CheckResult reports whether a TeX template could be parsed, filled in and compiled, and if not, why
+type CheckResult struct {
+	OK bool `json:"ok"`
+	// Stage is the stage that failed
+	Stage string `json:"stage,omitempty"`
+	Error string `json:"error,omitempty"`
+	// MissingField is the name of the field the template referred to, if it does not exist in the template data
+	MissingField string `json:"missingField,omitempty"`
+	// LaTeX describes the error if the compile stage failed
+	LaTeX *LaTeXError `json:"latex,omitempty"`
+}
+
+var missingField = regexp.MustCompile(`can't evaluate field (\w+)`)
+
+// Check goes through the steps of generating a PDF report with opts.Template, and stops at the first that fails.
+// Use a client from NewSampleClient to check templates without a Grafana server.
+// If compile is false the template is not compiled with LaTeX. Otherwise, and if preview is not nil,
+// the compiled PDF is written to preview.
+func Check(g grafana.Client, dashName string, t grafana.TimeRange, opts Options, compile bool, preview io.Writer) CheckResult {
+	rep := new(g, dashName, t, opts)
+	defer rep.Clean()
+
+	dash, err := g.GetDashboard(dashName)
+	if err != nil {
+		return failedCheck(StageDashboard, err)
+	}
+	if err = rep.renderPNGsParallel(dash); err != nil {
+		return failedCheck(StageImages, err)
+	}
+	tmpl, err := rep.parseTeXTemplate()
+	if err != nil {
+		return failedCheck(StageParse, err)
+	}
+	if err = rep.executeTeXTemplate(tmpl, dash); err != nil {
+		res := failedCheck(StageExecute, err)
+		if m := missingField.FindStringSubmatch(err.Error()); m != nil {
+			res.MissingField = m[1]
+		}
+		return res
+	}
+	if !compile {
+		return CheckResult{OK: true}
+	}
+
+	pdf, err := rep.runLaTeX(dash)
+	if err != nil {
+		res := failedCheck(StageCompile, err)
+		if latexErr, ok := err.(*LaTeXError); ok {
+			res.LaTeX = latexErr
+		}
+		return res
+	}
+	defer pdf.Close()
+	if preview != nil {
+		if _, err = io.Copy(preview, pdf); err != nil {
+			return failedCheck(StageCompile, fmt.Errorf("error writing preview: %v", err))
+		}
+	}
+	return CheckResult{OK: true}
+}
+
+func failedCheck(stage string, err error) CheckResult {
+	return CheckResult{Stage: stage, Error: err.Error()}
+}
@@ -0,0 +1,94 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"image/png"
+	"net/url"
+	"testing"
+
+	"github.com/IzakMarais/reporter/grafana"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSampleClient(t *testing.T) {
+	Convey("When creating a sample client", t, func() {
+		Convey("Without dashboard JSON it should serve the sample dashboard", func() {
+			g, err := NewSampleClient(nil, url.Values{}, false)
+			So(err, ShouldBeNil)
+			dash, _ := g.GetDashboard("")
+			So(dash.Title, ShouldEqual, "Sample dashboard")
+			So(dash.Panels, ShouldHaveLength, 6)
+			So(dash.Rows, ShouldHaveLength, 3)
+		})
+
+		Convey("It should accept dashboards as exported from Grafana", func() {
+			g, err := NewSampleClient([]byte(`{"title": "Exported", "panels": [{"id": 3, "type": "graph"}]}`), url.Values{}, false)
+			So(err, ShouldBeNil)
+			dash, _ := g.GetDashboard("")
+			So(dash.Title, ShouldEqual, "Exported")
+			So(dash.Panels, ShouldHaveLength, 1)
+		})
+
+		Convey("It should return an error for invalid JSON", func() {
+			_, err := NewSampleClient([]byte(`{"title": `), url.Values{}, false)
+			So(err, ShouldNotBeNil)
+			_, err = NewSampleClient([]byte(`{"dashboard": {"panels": "none"}}`), url.Values{}, false)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("It should serve placeholder images sized like Grafana's renders", func() {
+			g, _ := NewSampleClient(nil, url.Values{}, true)
+			body, err := g.GetPanelPng(grafana.Panel{Id: 1, GridPos: grafana.GridPos{W: 12, H: 8}}, "", grafana.TimeRange{})
+			So(err, ShouldBeNil)
+			img, err := png.Decode(body)
+			So(err, ShouldBeNil)
+			So(img.Bounds().Dx(), ShouldEqual, 480)
+			So(img.Bounds().Dy(), ShouldEqual, 320)
+		})
+	})
+}
+
+func TestCheck(t *testing.T) {
+	Convey("When checking a template without compiling it", t, func() {
+		g, _ := NewSampleClient(nil, url.Values{}, false)
+		check := func(templ string) CheckResult {
+			return Check(g, "sample", grafana.NewTimeRange("now-1h", "now"), Options{Template: templ}, false, nil)
+		}
+
+		Convey("A valid template should pass", func() {
+			So(check(`[[.Title]] [[range .Panels]][[.Id]][[end]]`), ShouldResemble, CheckResult{OK: true})
+		})
+
+		Convey("The default template should pass", func() {
+			So(check("").OK, ShouldBeTrue)
+		})
+
+		Convey("Syntax errors should fail the parse stage", func() {
+			res := check(`[[range .Panels]]`)
+			So(res.OK, ShouldBeFalse)
+			So(res.Stage, ShouldEqual, StageParse)
+			So(res.Error, ShouldContainSubstring, "unexpected EOF")
+		})
+
+		Convey("Missing fields should fail the execute stage", func() {
+			res := check(`[[.Dashbaord]]`)
+			So(res.Stage, ShouldEqual, StageExecute)
+			So(res.MissingField, ShouldEqual, "Dashbaord")
+		})
+	})
+}
@@ -0,0 +1,186 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"github.com/IzakMarais/reporter/grafana"
+)
+
+const (
+	// CompareSideBySide places the images of the compared periods next to each other
+	CompareSideBySide = "side"
+	// CompareStacked places the images of the compared periods above each other
+	CompareStacked = "stacked"
+)
+
+const (
+	// compareCaptionHeight is the space taken by the caption under each image in grid layout, as a fraction of the text width
+	compareCaptionHeight = 0.02
+)
+
+// period is one of the time ranges of a comparison report
+type period struct {
+	grafana.TimeRange
+	// Shift is how far the report's time range was shifted back for the period, e.g. 1w. It is empty for explicit ranges.
+	Shift  string
+	locale locale
+	// tex escapes the caption for TeX
+	tex bool
+}
+
+// Caption is the period's start and end, and how far it was shifted if it was, for the captions of TeX reports
+func (p period) Caption() string {
+	start, end := p.locale.formatPeriod(p.FromTime(), p.ToTime())
+	caption := start + " -- " + end
+	if p.Shift != "" {
+		label := p.locale.messageWith("earlier", "{shift}", p.Shift)
+		if p.tex {
+			label = grafana.EscapeLaTeX(label)
+		}
+		caption += " (" + label + ")"
+	}
+	return caption
+}
+
+// comparing reports whether the options ask for a comparison report
+func (o Options) comparing() bool {
+	return len(o.Compare) > 0 || len(o.CompareShift) > 0
+}
+
+// periodCount is the number of time ranges that every panel is rendered for
+func (o Options) periodCount() int {
+	if !o.comparing() {
+		return 1
+	}
+	return 1 + len(o.Compare) + len(o.CompareShift)
+}
+
+// validateComparison checks the comparison options. Comparison reports are only supported as PDF with the LaTeX backend.
+func (o Options) validateComparison() error {
+	switch o.CompareLayout {
+	case "", CompareSideBySide, CompareStacked:
+	default:
+		return fmt.Errorf("unknown comparison layout %q, expected %q or %q", o.CompareLayout, CompareSideBySide, CompareStacked)
+	}
+	if !o.comparing() {
+		return nil
+	}
+	for _, shift := range o.CompareShift {
+		if err := grafana.ValidateShift(shift); err != nil {
+			return err
+		}
+	}
+	if (o.Format != "" && o.Format != FormatPDF) || o.Backend == BackendNative {
+		return fmt.Errorf("comparison reports are only supported as PDF with the LaTeX backend")
+	}
+	return nil
+}
+
+// comparePeriods returns the periods of a comparison report: the report's time range, the explicit ranges of
+// Options.Compare and the shifted ranges of Options.CompareShift. It returns nil if the report is not a comparison.
+func (rep *report) comparePeriods() ([]period, error) {
+	if !rep.opts.comparing() {
+		return nil, nil
+	}
+	l := rep.locale()
+	periods := []period{{TimeRange: rep.time, locale: l}}
+	for _, t := range rep.opts.Compare {
+		periods = append(periods, period{TimeRange: t, locale: l})
+	}
+	for _, shift := range rep.opts.CompareShift {
+		t, err := rep.time.Shift(shift)
+		if err != nil {
+			return nil, err
+		}
+		periods = append(periods, period{TimeRange: t, Shift: shift, locale: l})
+	}
+	return periods, nil
+}
+
+// periodImgFileName is the name of the image of panel p for the k-th period of a comparison.
+// The first period is the report's own time range, whose images have the usual names.
+func periodImgFileName(p grafana.Panel, k int) string {
+	if k == 0 {
+		return imgFileName(p)
+	}
+	return fmt.Sprintf("image%d-%d.png", p.Id, k)
+}
+
+func (rep *report) periodImgFilePath(p grafana.Panel, k int) string {
+	return filepath.Join(rep.imgDirPath(), periodImgFileName(p, k))
+}
+
+// comparison is a panel with its image for each period of a comparison report
+type comparison struct {
+	grafana.Panel
+	Images []periodImage
+	// Stacked is true if the images are above each other, rather than side by side
+	Stacked bool
+	// Width is the width of each image and its caption, as a fraction of the text width.
+	// Height is the height of each image: at most this fraction of the text height in the default layout,
+	// and exactly this fraction of the text width in grid layout.
+	Width, Height float64
+}
+
+// periodImage is the image of a panel for one period
+type periodImage struct {
+	// Name is the image file name without extension, for \includegraphics
+	Name    string
+	Caption string
+}
+
+func (d templData) comparison(p grafana.Panel) comparison {
+	c := comparison{Panel: p, Stacked: d.compareStacked}
+	for k, period := range d.Periods {
+		name := strings.TrimSuffix(periodImgFileName(p, k), ".png")
+		c.Images = append(c.Images, periodImage{name, period.Caption()})
+	}
+	return c
+}
+
+// Comparison returns the panel's images of all periods of a comparison report, sized for the default layout
+func (d templData) Comparison(p grafana.Panel) comparison {
+	c := d.comparison(p)
+	n := float64(len(c.Images))
+	if c.Stacked {
+		c.Width, c.Height = 1, round(0.85/n)
+	} else {
+		c.Width, c.Height = round(0.96/n), 0.9
+	}
+	return c
+}
+
+// GridComparison returns the panel's images of all periods of a comparison report, sized to share the panel's place
+// in grid layout with their captions
+func (d templData) GridComparison(p gridPanel) comparison {
+	c := d.comparison(p.Panel)
+	n := float64(len(c.Images))
+	if c.Stacked {
+		c.Height = math.Max((p.ImageHeight-n*compareCaptionHeight)/n, compareCaptionHeight)
+		c.Width = c.Height * p.ImageWidth / p.ImageHeight
+	} else {
+		c.Width = p.ImageWidth / n
+		c.Height = c.Width * p.ImageHeight / p.ImageWidth
+	}
+	c.Width, c.Height = round(c.Width), round(c.Height)
+	return c
+}
@@ -0,0 +1,137 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/IzakMarais/reporter/grafana"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestComparison(t *testing.T) {
+	from := time.Date(2016, time.January, 19, 12, 0, 0, 0, time.Local)
+	tr := grafana.TimeRange{From: formatMs(from), To: formatMs(from.Add(2 * time.Hour))}
+
+	Convey("When describing a period", t, func() {
+		en, _ := loadLocale(LocaleEnglish, "")
+		de, _ := loadLocale(LocaleGerman, "")
+
+		Convey("The caption should show the start and end, and the shift", func() {
+			So(period{tr, "", en, false}.Caption(), ShouldEqual, "2016-01-19 12:00 -- 14:00")
+			So(period{tr, "1w", en, false}.Caption(), ShouldEqual, "2016-01-19 12:00 -- 14:00 (1w earlier)")
+		})
+
+		Convey("The end should have a date if it is on another day", func() {
+			long := grafana.TimeRange{From: tr.From, To: formatMs(from.AddDate(0, 0, 1))}
+			So(period{long, "", en, false}.Caption(), ShouldEqual, "2016-01-19 12:00 -- 2016-01-20 12:00")
+		})
+
+		Convey("The caption should be translated", func() {
+			So(period{tr, "1w", de, false}.Caption(), ShouldEqual, "19.01.2016 12:00 -- 14:00 (1w früher)")
+		})
+	})
+
+	Convey("When validating the comparison options", t, func() {
+		So(Options{}.validateComparison(), ShouldBeNil)
+		So(Options{CompareShift: []string{"1w"}, CompareLayout: CompareStacked}.validateComparison(), ShouldBeNil)
+		So(Options{CompareLayout: "diagonal"}.validateComparison(), ShouldNotBeNil)
+		So(Options{CompareShift: []string{"a week"}}.validateComparison(), ShouldNotBeNil)
+		So(Options{CompareShift: []string{"1w"}, Format: FormatHTML}.validateComparison(), ShouldNotBeNil)
+		So(Options{CompareShift: []string{"1w"}, Backend: BackendNative}.validateComparison(), ShouldNotBeNil)
+		So(Options{CompareShift: []string{"1w"}, Format: FormatHTML}.Validate(), ShouldHaveSameTypeAs, &OptionsError{})
+	})
+
+	Convey("When rendering a comparison report", t, func() {
+		gClient := &v5Client{mockGrafanaClient{0, url.Values{}}}
+		earlier := grafana.TimeRange{From: formatMs(from.AddDate(0, -1, 0)), To: formatMs(from.AddDate(0, -1, 0).Add(2 * time.Hour))}
+		opts := Options{Compare: []grafana.TimeRange{earlier}, CompareShift: []string{"1w"}}
+		rep := new(gClient, "abc123", tr, opts)
+		defer rep.Clean()
+		dash, _ := gClient.GetDashboard("")
+		So(rep.renderPNGsParallel(dash), ShouldBeNil)
+
+		Convey("Every panel should be rendered for every period", func() {
+			So(gClient.getPanelCallCount, ShouldEqual, 3*len(dash.Panels))
+			for _, name := range []string{"image4.png", "image4-1.png", "image4-2.png"} {
+				_, err := os.Stat(rep.imgDirPath() + "/" + name)
+				So(err, ShouldBeNil)
+			}
+		})
+
+		Convey("The periods should be the report's time range, the explicit ranges and the shifted ranges", func() {
+			So(rep.periods, ShouldHaveLength, 3)
+			So(rep.periods[0].TimeRange, ShouldResemble, tr)
+			So(rep.periods[1].TimeRange, ShouldResemble, earlier)
+			So(rep.periods[2].Caption(), ShouldEqual, "2016-01-12 12:00 -- 14:00 (1w earlier)")
+		})
+
+		Convey("The TeX file should show the images of each period side by side with captions", func() {
+			So(rep.generateTeXFile(dash), ShouldBeNil)
+			b, _ := ioutil.ReadFile(rep.texPath())
+			s := string(b)
+			So(s, ShouldContainSubstring, `\begin{minipage}[t]{0.32\textwidth}`)
+			So(s, ShouldContainSubstring, `\includegraphics[width=\textwidth,height=0.9\textheight,keepaspectratio]{image4-2}\\`)
+			So(s, ShouldContainSubstring, `{\small 2016-01-12 12:00 -- 14:00 (1w earlier)}`)
+			So(s, ShouldContainSubstring, `\hfill`)
+		})
+	})
+
+	Convey("When a compared period fails to render", t, func() {
+		gClient := &errClient{0, url.Values{}}
+		rep := new(gClient, "abc123", tr, Options{CompareShift: []string{"1d"}})
+		defer rep.Clean()
+		dash, _ := gClient.GetDashboard("")
+		So(rep.renderPNGsParallel(dash), ShouldBeNil)
+
+		Convey("The failure should be listed once", func() {
+			So(rep.failures, ShouldHaveLength, 1)
+		})
+	})
+
+	Convey("When generating a stacked comparison in grid layout", t, func() {
+		gClient := &v5Client{mockGrafanaClient{0, url.Values{}}}
+		rep := new(gClient, "abc123", tr, Options{GridLayout: true, CompareShift: []string{"1y"}, CompareLayout: CompareStacked})
+		defer rep.Clean()
+		dash, _ := gClient.GetDashboard("")
+		So(rep.renderPNGsParallel(dash), ShouldBeNil)
+		So(rep.generateTeXFile(dash), ShouldBeNil)
+		b, _ := ioutil.ReadFile(rep.texPath())
+		s := string(b)
+
+		Convey("The images should share the panel's place, one above the other", func() {
+			So(s, ShouldContainSubstring, `at (12.1,0.1) {\begin{minipage}{0.183728\textwidth}\begin{minipage}[t]{0.183728\textwidth}\centering\includegraphics[width=\textwidth,height=0.059167\textwidth]{image2}\\\tiny 2016-01-19 12:00 -- 14:00\end{minipage}\par`)
+			So(s, ShouldContainSubstring, `{image2-1}\\\tiny 2015-01-19 12:00 -- 14:00 (1y earlier)\end{minipage}\par\end{minipage}};`)
+		})
+
+		Convey("Side by side images should share the panel's width", func() {
+			d := templData{Periods: rep.periods}
+			c := d.GridComparison(gridPanel{ImageWidth: 0.5, ImageHeight: 0.25})
+			So(c.Width, ShouldEqual, 0.25)
+			So(c.Height, ShouldEqual, 0.125)
+		})
+	})
+}
+
+func formatMs(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+}
@@ -0,0 +1,180 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sync"
+
+	"github.com/IzakMarais/reporter/grafana"
+)
+
+// Part is a dashboard of a composite report. Its Client holds the dashboard's variables.
+type Part struct {
+	Client   grafana.Client
+	DashName string
+	Time     grafana.TimeRange
+	// Heading is the title of the part's chapter. The dashboard title is used if it is empty.
+	Heading string
+}
+
+// composite is a report of several dashboards: a cover page, a table of contents and a chapter per dashboard.
+// The embedded report is the cover, and holds the parts and the directory that they are generated in.
+type composite struct {
+	*report
+}
+
+// NewComposite creates a report that combines the dashboards of parts into one PDF with a cover page titled title,
+// a table of contents and a chapter per dashboard. The panels of all parts are rendered together, sharing opts.Scheduler.
+// The cover shows the time range t. Composite reports are only available as PDF from the LaTeX backend.
+func NewComposite(title string, t grafana.TimeRange, parts []Part, opts Options) Report {
+	return newComposite(title, t, parts, opts)
+}
+
+func newComposite(title string, t grafana.TimeRange, parts []Part, opts Options) *composite {
+	c := &composite{report: new(nil, "", t, opts)}
+	c.dash = &grafana.Dashboard{Title: grafana.EscapeLaTeX(title)}
+	c.dashTitle = title
+	for _, p := range parts {
+		c.addPart(p)
+	}
+	return c
+}
+
+// addPart adds the report of a part, generated in a directory of its own and reporting progress through the composite
+func (c *composite) addPart(p Part) {
+	opts := c.opts
+	opts.Progress = func(Progress) { c.panelRendered() }
+	c.parts = append(c.parts, &report{
+		gClient:     p.Client,
+		time:        p.Time,
+		opts:        opts,
+		texTemplate: c.texTemplate,
+		dashName:    p.DashName,
+		tmpDir:      filepath.Join(c.tmpDir, fmt.Sprintf("dash%d", len(c.parts)+1)),
+		generatedAt: c.generatedAt,
+		heading:     p.Heading,
+	})
+}
+
+// ValidateComposite checks the options of a composite report like Validate, and that they select a PDF from
+// the LaTeX backend, the only one that composite reports are available from. The error is an *OptionsError.
+func (o Options) ValidateComposite() error {
+	return o.validateLaTeXOnly("composite reports")
+}
+
+// validateLaTeXOnly checks options like Validate, and that they select a PDF from the LaTeX backend,
+// the only one that reports of the given kind are available from
+func (o Options) validateLaTeXOnly(kind string) error {
+	if (o.Format != "" && o.Format != FormatPDF) || (o.Backend != "" && o.Backend != BackendLaTeX) {
+		return &OptionsError{fmt.Sprintf("%v are only available as PDF from the %v backend", kind, BackendLaTeX)}
+	}
+	return o.Validate()
+}
+
+// Generate fetches the dashboards, renders the panels of all of them and typesets the report
+func (c *composite) Generate() (io.ReadCloser, error) {
+	if err := c.workspace().CheckQuota(); err != nil {
+		return nil, err
+	}
+	if err := c.opts.ValidateComposite(); err != nil {
+		return nil, err
+	}
+
+	c.setPhase(PhaseDashboard, 0)
+	dashes := make([]grafana.Dashboard, len(c.parts))
+	total := 0
+	for i, p := range c.parts {
+		dash, err := p.dashboard()
+		if err != nil {
+			return nil, err
+		}
+		dashes[i] = dash
+		total += len(dash.Panels) * p.opts.periodCount()
+		for _, tag := range dash.Tags {
+			if !containsString(c.dash.Tags, tag) {
+				c.dash.Tags = append(c.dash.Tags, tag)
+			}
+		}
+	}
+
+	c.setPhase(PhaseRendering, total)
+	errs := make([]error, len(c.parts))
+	var wg sync.WaitGroup
+	for i, p := range c.parts {
+		wg.Add(1)
+		go func(i int, p *report) {
+			defer wg.Done()
+			errs[i] = p.renderPNGsParallel(dashes[i])
+		}(i, p)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("error rendering PNGs in parralel for dash %v: %v", c.parts[i].dashName, err)
+		}
+	}
+
+	c.setPhase(PhaseGenerating, total)
+	return latexRenderer{}.render(c.report, *c.dash)
+}
+
+// CacheKey identifies the report by its title, the time range of its cover and the cache keys of its parts
+func (c *composite) CacheKey() (string, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "composite %q\n", c.dashTitle)
+	fmt.Fprintf(h, "time %d %d\n", c.time.FromTime().UnixNano(), c.time.ToTime().UnixNano())
+	for _, p := range c.parts {
+		key, err := p.CacheKey()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "part %s\n", key)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Complete reports whether all panels of all parts were rendered
+func (c *composite) Complete() bool {
+	for _, p := range c.parts {
+		if !p.Complete() {
+			return false
+		}
+	}
+	return true
+}
+
+// chapters returns the template data of each part, with its images in the part's directory
+func (rep *report) chapters() []templData {
+	var chapters []templData
+	for _, p := range rep.parts {
+		if p.dash == nil {
+			continue
+		}
+		ch := p.texData(*p.dash)
+		ch.ImageDir = filepath.ToSlash(filepath.Join(filepath.Base(p.tmpDir), imgDir))
+		if p.heading != "" {
+			ch.Title = grafana.EscapeLaTeX(p.heading)
+		}
+		chapters = append(chapters, ch)
+	}
+	return chapters
+}
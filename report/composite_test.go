@@ -0,0 +1,156 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/IzakMarais/reporter/grafana"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// taggedClient serves the mock dashboard with tags
+type taggedClient struct {
+	mockGrafanaClient
+	tags []string
+}
+
+func (m *taggedClient) GetDashboard(dashName string) (grafana.Dashboard, error) {
+	dash, err := m.mockGrafanaClient.GetDashboard(dashName)
+	dash.Tags = m.tags
+	return dash, err
+}
+
+func TestCompositeReport(t *testing.T) {
+	Convey("When generating a composite report of two dashboards", t, func() {
+		v5 := &v5Client{mockGrafanaClient{0, url.Values{}}}
+		v4 := &mockGrafanaClient{0, url.Values{}}
+		var mu sync.Mutex
+		var last Progress
+		parts := []Part{
+			{v5, "abc123", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, ""},
+			{v4, "testDash", grafana.TimeRange{From: "now-7d", To: "now"}, ""},
+		}
+		c := newComposite("Service review", grafana.TimeRange{From: "now-1d", To: "now"}, parts, Options{Progress: func(p Progress) {
+			mu.Lock()
+			last = p
+			mu.Unlock()
+		}})
+		defer c.Clean()
+		c.Generate() //fails without a TeX installation, after writing the TeX file
+		b, err := ioutil.ReadFile(c.texPath())
+		So(err, ShouldBeNil)
+		s := string(b)
+
+		Convey("It should render the panels of all dashboards into their own directories", func() {
+			So(v5.getPanelCallCount, ShouldEqual, 3)
+			So(v4.getPanelCallCount, ShouldEqual, 9)
+			_, err := os.Stat(filepath.Join(c.tmpDir, "dash1", imgDir, "image4.png"))
+			So(err, ShouldBeNil)
+			_, err = os.Stat(filepath.Join(c.tmpDir, "dash2", imgDir, "image99.png"))
+			So(err, ShouldBeNil)
+			So(last.PanelsRendered, ShouldEqual, 12)
+			So(last.PanelsTotal, ShouldEqual, 12)
+		})
+
+		Convey("It should have a cover page, a table of contents and a chapter per dashboard", func() {
+			So(s, ShouldContainSubstring, `\documentclass{report}`)
+			So(s, ShouldContainSubstring, `\title{Service review`)
+			So(s, ShouldContainSubstring, `\tableofcontents`)
+			So(s, ShouldContainSubstring, `\chapter{Rows \& panels}`)
+			So(s, ShouldContainSubstring, `\chapter{My first dashboard}`)
+			So(s, ShouldContainSubstring, `\section*{Database}`)
+		})
+
+		Convey("Each chapter should include its own images and time range", func() {
+			So(s, ShouldContainSubstring, `\graphicspath{ {dash1/images/} }`)
+			So(s, ShouldContainSubstring, `\graphicspath{ {dash2/images/} }`)
+			So(s, ShouldContainSubstring, parts[0].Time.FromFormatted())
+		})
+
+		Convey("Its title should be the composite title", func() {
+			So(c.Title(), ShouldEqual, "Service review")
+		})
+	})
+
+	Convey("When generating a composite report with a public URL", t, func() {
+		parts := []Part{{&mockGrafanaClient{0, url.Values{}}, "testDash", grafana.TimeRange{From: "now-7d", To: "now"}, ""}}
+		c := newComposite("Service review", grafana.TimeRange{From: "now-1d", To: "now"}, parts, Options{PublicURL: "http://localhost:3000"})
+		defer c.Clean()
+
+		Convey("It should write the TeX file without links from the cover", func() {
+			So(func() { c.Generate() }, ShouldNotPanic)
+			_, err := os.Stat(c.texPath())
+			So(err, ShouldBeNil)
+			So(c.templData(*c.dash, true).DashboardURL, ShouldEqual, "")
+			So(c.chapters()[0].DashboardURL, ShouldStartWith, "http://localhost:3000/")
+		})
+	})
+
+	Convey("When computing the cache key of a composite report", t, func() {
+		part := func() []Part {
+			return []Part{{&v5Client{mockGrafanaClient{0, url.Values{}}}, "abc123", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, ""}}
+		}
+		key := func(title string, t grafana.TimeRange, parts []Part) string {
+			c := newComposite(title, t, parts, Options{})
+			defer c.Clean()
+			k, err := c.CacheKey()
+			So(err, ShouldBeNil)
+			return k
+		}
+		tr := grafana.TimeRange{From: "1453206447000", To: "1453213647000"}
+
+		Convey("It should depend on the title, the time range of the cover and the parts", func() {
+			So(key("A", tr, part()), ShouldEqual, key("A", tr, part()))
+			So(key("A", tr, part()), ShouldNotEqual, key("B", tr, part()))
+			So(key("A", tr, part()), ShouldNotEqual, key("A", grafana.TimeRange{From: "1453206447000", To: "1453217247000"}, part()))
+			So(key("A", tr, part()), ShouldNotEqual, key("A", tr, append(part(), part()...)))
+		})
+	})
+
+	Convey("When combining dashboards with the same tags", t, func() {
+		tr := grafana.TimeRange{From: "1453206447000", To: "1453213647000"}
+		parts := []Part{
+			{&taggedClient{mockGrafanaClient{0, url.Values{}}, []string{"prod", "db"}}, "db", tr, ""},
+			{&taggedClient{mockGrafanaClient{0, url.Values{}}, []string{"prod", "web"}}, "web", tr, ""},
+		}
+		c := newComposite("Service review", tr, parts, Options{})
+		defer c.Clean()
+		c.Generate() //fails without a TeX installation, after writing the TeX file
+
+		Convey("Each tag should be a keyword once", func() {
+			So(c.dash.Tags, ShouldResemble, []string{"prod", "db", "web"})
+		})
+	})
+
+	Convey("When requesting a composite report in another format", t, func() {
+		c := newComposite("Service review", grafana.TimeRange{}, nil, Options{Format: FormatHTML})
+		defer c.Clean()
+		_, err := c.Generate()
+
+		Convey("It should be an options error", func() {
+			So(err, ShouldHaveSameTypeAs, &OptionsError{})
+			So(Options{Backend: BackendNative}.ValidateComposite(), ShouldHaveSameTypeAs, &OptionsError{})
+			So(Options{}.ValidateComposite(), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,242 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/pborman/uuid"
+)
+
+const reportLogFile = "report.log"
+
+// DefaultDiagnosticsRetention is a suitable Options.DiagnosticsRetention for servers
+const DefaultDiagnosticsRetention = 10 * time.Minute
+
+// LaTeXError describes the first error reported by the TeX engine, and where it came from in the template
+type LaTeXError struct {
+	// Message is the TeX error message, e.g. "Undefined control sequence."
+	Message string `json:"message"`
+	// Context is the part of the log that describes the error, up to the offending input line
+	Context []string `json:"context"`
+	// TeXLine is the line of the generated report.tex that caused the error, 0 if unknown
+	TeXLine int `json:"texLine,omitempty"`
+	// TeXSource is the content of TeXLine
+	TeXSource string `json:"texSource,omitempty"`
+	// Template is the name of the template that most likely produced TeXLine: "report", or the name of a partial
+	Template string `json:"template,omitempty"`
+	// TemplateLine is the line of Template that most likely produced TeXLine, 0 if unknown
+	TemplateLine int `json:"templateLine,omitempty"`
+	// TemplateSource is the content of TemplateLine
+	TemplateSource string `json:"templateSource,omitempty"`
+	// Data maps the actions on TemplateLine to the values they produced, where they can be evaluated on their own
+	Data map[string]string `json:"data,omitempty"`
+	// ID identifies the kept report.tex and report.log, see Workspace.DiagnosticsFile. Empty if they were not kept.
+	ID string `json:"id,omitempty"`
+	// Output is the console output of the TeX engine
+	Output string `json:"-"`
+}
+
+func (e *LaTeXError) Error() string {
+	msg := "LaTeX error: " + e.Message
+	if e.TeXLine > 0 {
+		msg += fmt.Sprintf(" on line %d of %s", e.TeXLine, reportTexFile)
+	}
+	if e.TemplateLine > 0 {
+		msg += fmt.Sprintf(" (template %s line %d: %s)", e.Template, e.TemplateLine, strings.TrimSpace(e.TemplateSource))
+	}
+	return msg
+}
+
+var texLogLine = regexp.MustCompile(`^l\.(\d+) `)
+
+// parseLaTeXLog finds the first error in a TeX log. It returns nil if the log contains no error.
+func parseLaTeXLog(texLog string) *LaTeXError {
+	lines := strings.Split(strings.Replace(texLog, "\r\n", "\n", -1), "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "! ") {
+			continue
+		}
+		e := &LaTeXError{Message: strings.TrimPrefix(line, "! ")}
+		for j := i; j < len(lines) && j < i+20; j++ {
+			if lines[j] == "" {
+				break
+			}
+			e.Context = append(e.Context, lines[j])
+			if m := texLogLine.FindStringSubmatch(lines[j]); m != nil {
+				e.TeXLine, _ = strconv.Atoi(m[1])
+				//the rest of the offending line is printed below, indented to where TeX stopped reading
+				if j+1 < len(lines) && strings.TrimSpace(lines[j+1]) != "" {
+					e.Context = append(e.Context, lines[j+1])
+				}
+				break
+			}
+		}
+		return e
+	}
+	return nil
+}
+
+var templateAction = regexp.MustCompile(`\[\[.*?\]\]`)
+
+// mapToTemplate finds the template line that most likely produced texSource, the content of line texLine of the
+// generated TeX file: the line whose literal text, between its actions, appears in texSource in order.
+// If several lines match equally well the one nearest to texLine is used.
+// Of equally good matches in different sources the later one wins, as later sources override earlier definitions.
+func mapToTemplate(sources []templateSource, texSource string, texLine int) (string, int, string) {
+	bestName, bestLine, bestSource := "", 0, ""
+	bestScore, bestDist := 0, 0
+	for _, src := range sources {
+		for i, line := range strings.Split(src.text, "\n") {
+			score := matchTemplateLine(line, texSource)
+			if score == 0 {
+				continue
+			}
+			dist := i + 1 - texLine
+			if dist < 0 {
+				dist = -dist
+			}
+			if score > bestScore || (score == bestScore && dist <= bestDist) {
+				bestName, bestLine, bestSource = src.name, i+1, line
+				bestScore, bestDist = score, dist
+			}
+		}
+	}
+	return bestName, bestLine, bestSource
+}
+
+// matchTemplateLine returns the length of the literal text of a template line if it appears in texSource, otherwise 0
+func matchTemplateLine(line, texSource string) int {
+	var literals []string
+	score := 0
+	for _, lit := range templateAction.Split(line, -1) {
+		if strings.TrimSpace(lit) != "" {
+			literals = append(literals, regexp.QuoteMeta(lit))
+			score += len(strings.TrimSpace(lit))
+		}
+	}
+	if score == 0 || !regexp.MustCompile(strings.Join(literals, ".*")).MatchString(texSource) {
+		return 0
+	}
+	return score
+}
+
+// evalActions evaluates each action of a template line against data. Actions that cannot be evaluated on their own,
+// such as control structures or fields of range elements, are left out.
+func evalActions(line string, data interface{}) map[string]string {
+	values := map[string]string{}
+	for _, action := range templateAction.FindAllString(line, -1) {
+		tmpl, err := template.New("action").Delims("[[", "]]").Funcs(texFuncs()).Parse(action)
+		if err != nil {
+			continue
+		}
+		var buf bytes.Buffer
+		if err = tmpl.Execute(&buf, data); err != nil {
+			continue
+		}
+		values[action] = buf.String()
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return values
+}
+
+// latexError builds the structured error for a failed TeX run from the log file, or from output if there is no log
+func (rep *report) latexError(output string, data interface{}) *LaTeXError {
+	texLog, err := ioutil.ReadFile(filepath.Join(rep.tmpDir, reportLogFile))
+	if err != nil {
+		texLog = []byte(output)
+	}
+	e := parseLaTeXLog(string(texLog))
+	if e == nil {
+		e = &LaTeXError{Message: "no error found in LaTeX log"}
+	}
+	e.Output = output
+
+	if tex, err := ioutil.ReadFile(rep.texPath()); err == nil && e.TeXLine > 0 {
+		texLines := strings.Split(string(tex), "\n")
+		if e.TeXLine <= len(texLines) {
+			e.TeXSource = texLines[e.TeXLine-1]
+			if sources, err := rep.templateSources(rep.texTemplate, ".tex"); err == nil {
+				e.Template, e.TemplateLine, e.TemplateSource = mapToTemplate(sources, e.TeXSource, e.TeXLine)
+				e.Data = evalActions(e.TemplateSource, data)
+			}
+		}
+	}
+
+	if rep.opts.DiagnosticsRetention > 0 {
+		id, err := rep.keepDiagnostics(rep.opts.DiagnosticsRetention)
+		if err != nil {
+			log.Println("Error keeping LaTeX diagnostics:", err)
+		} else {
+			e.ID = id
+		}
+	}
+	return e
+}
+
+// keepDiagnostics copies the TeX source and log of a failed run out of the temporary directory and
+// deletes them again after retention
+func (rep *report) keepDiagnostics(retention time.Duration) (string, error) {
+	id := uuid.New()
+	dir := filepath.Join(rep.workspace().root, diagnosticsDir, id)
+	err := os.MkdirAll(dir, 0777)
+	if err != nil {
+		return "", fmt.Errorf("error creating diagnostics directory: %v", err)
+	}
+	for _, name := range []string{reportTexFile, reportLogFile} {
+		if err = copyFile(filepath.Join(dir, name), filepath.Join(rep.tmpDir, name)); err != nil && !os.IsNotExist(err) {
+			os.RemoveAll(dir)
+			return "", err
+		}
+	}
+	time.AfterFunc(retention, func() {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Println("Error removing LaTeX diagnostics:", err)
+		}
+	})
+	return id, nil
+}
+
+func copyFile(dst, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
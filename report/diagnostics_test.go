@@ -0,0 +1,136 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/IzakMarais/reporter/grafana"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const undefinedControlSequenceLog = `This is pdfTeX, Version 3.14159265-2.6-1.40.18 (TeX Live 2017) (preloaded format=pdflatex 2018.1.1)  1 FEB 2018 10:00
+entering extended mode
+(./report.tex
+LaTeX2e <2017-04-15>
+! Undefined control sequence.
+l.9 \title{My first dashboard \foo
+                                  }
+Here is how much of TeX's memory you used:
+`
+
+func TestParseLaTeXLog(t *testing.T) {
+	Convey("When parsing a TeX log", t, func() {
+		Convey("It should find the first error, its line and context", func() {
+			e := parseLaTeXLog(undefinedControlSequenceLog)
+			So(e, ShouldNotBeNil)
+			So(e.Message, ShouldEqual, "Undefined control sequence.")
+			So(e.TeXLine, ShouldEqual, 9)
+			So(e.Context, ShouldResemble, []string{
+				"! Undefined control sequence.",
+				`l.9 \title{My first dashboard \foo`,
+				"                                  }",
+			})
+		})
+
+		Convey("It should return nil if there is no error", func() {
+			So(parseLaTeXLog("This is pdfTeX\nOutput written on report.pdf (1 page).\n"), ShouldBeNil)
+		})
+	})
+}
+
+func TestMapToTemplate(t *testing.T) {
+	Convey("When mapping a line of TeX back to the template", t, func() {
+		templ := "\\documentclass{article}\n\\begin{document}\n\\title{[[.Title]] \\foo}\n\\section{[[.Title]]}\n\\end{document}"
+
+		sources := []templateSource{{"report", templ}}
+
+		Convey("It should find the line whose literal text matches", func() {
+			name, line, source := mapToTemplate(sources, `\title{My first dashboard \foo}`, 3)
+			So(name, ShouldEqual, "report")
+			So(line, ShouldEqual, 3)
+			So(source, ShouldEqual, `\title{[[.Title]] \foo}`)
+		})
+
+		Convey("It should prefer partials parsed after the template", func() {
+			sources = append(sources, templateSource{"title", `\title{[[.Title]] \foo}`})
+			name, line, _ := mapToTemplate(sources, `\title{My first dashboard \foo}`, 1)
+			So(name, ShouldEqual, "title")
+			So(line, ShouldEqual, 1)
+		})
+
+		Convey("It should return 0 if no line matches", func() {
+			_, line, _ := mapToTemplate(sources, "nothing like it", 3)
+			So(line, ShouldEqual, 0)
+		})
+
+		Convey("It should evaluate the actions on the line against the template data", func() {
+			data := templData{Dashboard: grafana.Dashboard{Title: "My first dashboard"}}
+			So(evalActions(`\title{[[.Title]] [[range .Panels]]}`, data), ShouldResemble, map[string]string{"[[.Title]]": "My first dashboard"})
+		})
+	})
+}
+
+func TestLaTeXError(t *testing.T) {
+	Convey("When a LaTeX run fails", t, func() {
+		templ := "\n%comment\n\\documentclass{article}\n\\begin{document}\n\\title{[[.Title]] \\foo}\n\\end{document}\n"
+		root, err := ioutil.TempDir("", "workspace")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(root)
+		ws := NewWorkspace(root, 0)
+		rep := new(&mockGrafanaClient{0, url.Values{}}, "testDash", grafana.TimeRange{From: "now-1h", To: "now"},
+			Options{Template: templ, DiagnosticsRetention: time.Minute, Workspace: ws})
+		defer rep.Clean()
+		dash := grafana.Dashboard{Title: "My first dashboard"}
+		So(rep.generateTeXFile(dash), ShouldBeNil)
+		texLog := "! Undefined control sequence.\nl.5 \\title{My first dashboard \\foo\n                                  }\n"
+		So(ioutil.WriteFile(filepath.Join(rep.tmpDir, reportLogFile), []byte(texLog), 0666), ShouldBeNil)
+
+		e := rep.latexError("console output", rep.texData(dash))
+
+		Convey("It should map the error back to the template and its data", func() {
+			So(e.TeXLine, ShouldEqual, 5)
+			So(e.TeXSource, ShouldEqual, `\title{My first dashboard \foo}`)
+			So(e.TemplateLine, ShouldEqual, 5)
+			So(e.Data, ShouldResemble, map[string]string{"[[.Title]]": "My first dashboard"})
+			So(e.Output, ShouldEqual, "console output")
+			So(e.Error(), ShouldEqual, `LaTeX error: Undefined control sequence. on line 5 of report.tex (template report line 5: \title{[[.Title]] \foo})`)
+		})
+
+		Convey("It should keep the TeX source and log for download", func() {
+			So(e.ID, ShouldNotBeEmpty)
+			path, err := ws.DiagnosticsFile(e.ID, "report.log")
+			So(err, ShouldBeNil)
+			b, _ := ioutil.ReadFile(path)
+			So(string(b), ShouldEqual, texLog)
+			_, err = ws.DiagnosticsFile(e.ID, "report.tex")
+			So(err, ShouldBeNil)
+		})
+
+		Convey("It should not serve other files", func() {
+			_, err := ws.DiagnosticsFile(e.ID, "../../report.tex")
+			So(err, ShouldNotBeNil)
+			_, err = ws.DiagnosticsFile("..", "report.tex")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
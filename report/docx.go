@@ -0,0 +1,202 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/IzakMarais/reporter/grafana"
+)
+
+const (
+	emuPerInch = 914400
+	// A4 with 1in margins, in twentieths of a point and in EMUs
+	docxPageWidthTwips  = 11906
+	docxPageHeightTwips = 16838
+	docxMarginTwips     = 1440
+	docxTextWidthEMU    = (docxPageWidthTwips - 2*docxMarginTwips) * emuPerInch / 1440
+	docxTextHeightEMU   = (docxPageHeightTwips - 2*docxMarginTwips) * emuPerInch / 1440
+)
+
+// docxRenderer writes an Office Open XML word processing document: a title page like the default
+// TeX template's, then a Heading 1 per visible row followed by one picture per panel, sized from its gridPos.
+type docxRenderer struct{}
+
+func (docxRenderer) render(rep *report, dash grafana.Dashboard) (io.ReadCloser, error) {
+	file, err := os.Create(rep.docxPath())
+	if err != nil {
+		return nil, fmt.Errorf("error creating docx file at %v: %v", rep.docxPath(), err)
+	}
+	zw := zip.NewWriter(file)
+	err = rep.writeDocx(zw, plainDashboard(dash))
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error writing docx file at %v: %v", rep.docxPath(), err)
+	}
+
+	docx, err := os.Open(rep.docxPath())
+	if err != nil {
+		return nil, err
+	}
+	return docx, nil
+}
+
+func (rep *report) writeDocx(zw *zip.Writer, dash grafana.Dashboard) error {
+	var body bytes.Buffer
+	rels := []ooxmlRel{{"rIdStyles", relTypeStyles, "styles.xml"}}
+
+	docxParagraph(&body, "Title", "", dash.Title)
+	if dash.VariableValues != "" {
+		docxParagraph(&body, "Subtitle", "", dash.VariableValues)
+	}
+	if dash.Description != "" {
+		docxParagraph(&body, "", "center", dash.Description)
+	}
+	loc := rep.locale()
+	docxParagraph(&body, "", "center", loc.formatTime(rep.time.FromTime()))
+	docxParagraph(&body, "", "center", loc.message("to"))
+	docxParagraph(&body, "", "center", loc.formatTime(rep.time.ToTime()))
+	body.WriteString(`<w:p><w:r><w:br w:type="page"/></w:r></w:p>`)
+
+	media := []grafana.Panel{}
+	for i, row := range panelGroups(dash) {
+		if row.Title != "" {
+			if rep.opts.RowBreak && i > 0 {
+				body.WriteString(`<w:p><w:r><w:br w:type="page"/></w:r></w:p>`)
+			}
+			docxParagraph(&body, "Heading1", "", row.Title)
+		}
+		lineWidth := 0.0
+		inLine := false
+		for _, p := range row.Panels {
+			width := panelWidth(p)
+			if !inLine || lineWidth+width > 1.001 {
+				if inLine {
+					body.WriteString(`</w:p>`)
+				}
+				body.WriteString(`<w:p><w:pPr><w:jc w:val="center"/></w:pPr>`)
+				inLine, lineWidth = true, 0
+			}
+			lineWidth += width
+			media = append(media, p)
+			relID := fmt.Sprintf("rIdImage%d", p.Id)
+			rels = append(rels, ooxmlRel{relID, relTypeImage, "media/" + imgFileName(p)})
+			cx := int64(width * docxTextWidthEMU)
+			cy := int64(float64(cx) * rep.panelAspect(p))
+			if cy > docxTextHeightEMU {
+				cx, cy = cx*docxTextHeightEMU/cy, docxTextHeightEMU
+			}
+			docxPicture(&body, len(media), relID, p, cx, cy)
+		}
+		if inLine {
+			body.WriteString(`</w:p>`)
+		}
+	}
+	if len(rep.failures) > 0 {
+		body.WriteString(`<w:p><w:r><w:br w:type="page"/></w:r></w:p>`)
+		docxParagraph(&body, "Heading1", "", loc.message("failures"))
+		for _, f := range rep.failures {
+			docxParagraph(&body, "", "", loc.failureText(f))
+		}
+	}
+
+	parts := []ooxmlPart{
+		{"[Content_Types].xml", docxContentTypes},
+		{"_rels/.rels", docxPackageRels},
+		{"docProps/core.xml", fmt.Sprintf(ooxmlCoreProps, xmlEscape(dash.Title))},
+		{"word/_rels/document.xml.rels", ooxmlRelationships(rels)},
+		{"word/styles.xml", docxStyles},
+		{"word/document.xml", fmt.Sprintf(docxDocument, body.String(), docxPageWidthTwips, docxPageHeightTwips,
+			docxMarginTwips, docxMarginTwips, docxMarginTwips, docxMarginTwips)},
+	}
+	if err := writeOOXMLParts(zw, parts); err != nil {
+		return err
+	}
+	for _, p := range media {
+		if err := addFileToZip(zw, "word/media/"+imgFileName(p), rep.imgFilePath(p)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func docxParagraph(w *bytes.Buffer, style, align, text string) {
+	w.WriteString(`<w:p>`)
+	if style != "" || align != "" {
+		w.WriteString(`<w:pPr>`)
+		if style != "" {
+			fmt.Fprintf(w, `<w:pStyle w:val="%s"/>`, style)
+		}
+		if align != "" {
+			fmt.Fprintf(w, `<w:jc w:val="%s"/>`, align)
+		}
+		w.WriteString(`</w:pPr>`)
+	}
+	fmt.Fprintf(w, `<w:r><w:t xml:space="preserve">%s</w:t></w:r></w:p>`, xmlEscape(text))
+}
+
+func docxPicture(w *bytes.Buffer, id int, relID string, p grafana.Panel, cx, cy int64) {
+	fmt.Fprintf(w, `<w:r><w:drawing><wp:inline distT="0" distB="0" distL="0" distR="0">`+
+		`<wp:extent cx="%d" cy="%d"/><wp:docPr id="%d" name="Panel %d" descr="%s"/>`+
+		`<a:graphic><a:graphicData uri="http://schemas.openxmlformats.org/drawingml/2006/picture"><pic:pic>`+
+		`<pic:nvPicPr><pic:cNvPr id="%d" name="%s"/><pic:cNvPicPr/></pic:nvPicPr>`+
+		`<pic:blipFill><a:blip r:embed="%s"/><a:stretch><a:fillRect/></a:stretch></pic:blipFill>`+
+		`<pic:spPr><a:xfrm><a:off x="0" y="0"/><a:ext cx="%d" cy="%d"/></a:xfrm><a:prstGeom prst="rect"><a:avLst/></a:prstGeom></pic:spPr>`+
+		`</pic:pic></a:graphicData></a:graphic></wp:inline></w:drawing></w:r>`,
+		cx, cy, id, p.Id, xmlEscape(p.Title), id, imgFileName(p), relID, cx, cy)
+}
+
+const docxContentTypes = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Default Extension="png" ContentType="image/png"/>` +
+	`<Override PartName="/word/document.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"/>` +
+	`<Override PartName="/word/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.wordprocessingml.styles+xml"/>` +
+	`<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>` +
+	`</Types>`
+
+const docxPackageRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="` + relTypeOfficeDocument + `" Target="word/document.xml"/>` +
+	`<Relationship Id="rId2" Type="` + relTypeCoreProps + `" Target="docProps/core.xml"/>` +
+	`</Relationships>`
+
+const docxStyles = xml.Header + `<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">` +
+	`<w:docDefaults><w:rPrDefault><w:rPr><w:sz w:val="22"/></w:rPr></w:rPrDefault></w:docDefaults>` +
+	`<w:style w:type="paragraph" w:default="1" w:styleId="Normal"><w:name w:val="Normal"/></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Title"><w:name w:val="Title"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/>` +
+	`<w:pPr><w:jc w:val="center"/><w:spacing w:before="2400" w:after="240"/></w:pPr><w:rPr><w:sz w:val="52"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Subtitle"><w:name w:val="Subtitle"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/>` +
+	`<w:pPr><w:jc w:val="center"/><w:spacing w:after="240"/></w:pPr><w:rPr><w:sz w:val="32"/></w:rPr></w:style>` +
+	`<w:style w:type="paragraph" w:styleId="Heading1"><w:name w:val="heading 1"/><w:basedOn w:val="Normal"/><w:next w:val="Normal"/>` +
+	`<w:pPr><w:keepNext/><w:spacing w:before="480" w:after="120"/><w:outlineLvl w:val="0"/></w:pPr><w:rPr><w:b/><w:sz w:val="32"/></w:rPr></w:style>` +
+	`</w:styles>`
+
+const docxDocument = xml.Header + `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"` +
+	` xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"` +
+	` xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing"` +
+	` xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"` +
+	` xmlns:pic="http://schemas.openxmlformats.org/drawingml/2006/picture">` +
+	`<w:body>%s<w:sectPr><w:pgSz w:w="%d" w:h="%d"/>` +
+	`<w:pgMar w:top="%d" w:right="%d" w:bottom="%d" w:left="%d" w:header="708" w:footer="708" w:gutter="0"/></w:sectPr></w:body></w:document>`
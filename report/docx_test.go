@@ -0,0 +1,173 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"image"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/IzakMarais/reporter/grafana"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const v5DashJSON = `
+{"Dashboard":
+	{
+		"Title":"Rows & panels",
+		"uid":"abc123",
+		"Panels":
+		[
+			{"Type":"singlestat", "Id":1, "GridPos":{"H":4,"W":12,"X":0,"Y":0}},
+			{"Type":"singlestat", "Id":2, "GridPos":{"H":4,"W":12,"X":12,"Y":0}},
+			{"Type":"row", "Id":3, "Title":"Database"},
+			{"Type":"graph", "Id":4, "Title":"Queries <per second>", "GridPos":{"H":8,"W":24,"X":0,"Y":5}}
+		]
+	}
+}`
+
+type v5Client struct {
+	mockGrafanaClient
+}
+
+func (m *v5Client) GetDashboard(dashName string) (grafana.Dashboard, error) {
+	return grafana.NewDashboard([]byte(v5DashJSON), m.variables), nil
+}
+
+func readZipFiles(path string) map[string]string {
+	zr, err := zip.OpenReader(path)
+	So(err, ShouldBeNil)
+	defer zr.Close()
+	files := map[string]string{}
+	for _, f := range zr.File {
+		r, _ := f.Open()
+		b, _ := ioutil.ReadAll(r)
+		r.Close()
+		files[f.Name] = string(b)
+	}
+	return files
+}
+
+// v5PngClient renders the panels of the v5 dashboard as 4:1 PNG images
+type v5PngClient struct {
+	v5Client
+}
+
+func (m *v5PngClient) GetPanelPng(p grafana.Panel, dashName string, t grafana.TimeRange) (io.ReadCloser, error) {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 100, 25)))
+	return ioutil.NopCloser(&buf), nil
+}
+
+func TestDocxReport(t *testing.T) {
+	Convey("When generating a DOCX report of panels with a gridPos without grid layout", t, func() {
+		gClient := &v5PngClient{v5Client{mockGrafanaClient{0, url.Values{}}}}
+		rep := new(gClient, "abc123", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Format: FormatDOCX})
+		defer rep.Clean()
+
+		file, err := rep.Generate()
+		So(err, ShouldBeNil)
+		file.Close()
+		doc := readZipFiles(rep.docxPath())["word/document.xml"]
+
+		Convey("Pictures should keep the aspect ratio of the rendered image", func() {
+			So(doc, ShouldContainSubstring, `<wp:extent cx="2865755" cy="716438"/>`)
+			So(doc, ShouldContainSubstring, `<wp:extent cx="5731510" cy="1432877"/>`)
+		})
+	})
+
+	Convey("When generating a DOCX report", t, func() {
+		gClient := &v5Client{mockGrafanaClient{0, url.Values{}}}
+		rep := new(gClient, "abc123", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Format: FormatDOCX})
+		defer rep.Clean()
+
+		file, err := rep.Generate()
+		So(err, ShouldBeNil)
+		file.Close()
+		files := readZipFiles(rep.docxPath())
+
+		Convey("It should contain the parts of a word processing document", func() {
+			So(files, ShouldContainKey, "[Content_Types].xml")
+			So(files, ShouldContainKey, "_rels/.rels")
+			So(files, ShouldContainKey, "word/document.xml")
+			So(files, ShouldContainKey, "word/styles.xml")
+		})
+
+		Convey("Every XML part should be well formed", func() {
+			for name, content := range files {
+				if strings.HasSuffix(name, ".xml") || strings.HasSuffix(name, ".rels") {
+					d := xml.NewDecoder(strings.NewReader(content))
+					var err error
+					for err == nil {
+						_, err = d.Token()
+					}
+					So(err.Error(), ShouldEqual, "EOF")
+				}
+			}
+		})
+
+		doc := files["word/document.xml"]
+		Convey("It should start with a title page", func() {
+			So(doc, ShouldContainSubstring, `<w:pStyle w:val="Title"/></w:pPr><w:r><w:t xml:space="preserve">Rows &amp; panels</w:t>`)
+			So(doc, ShouldContainSubstring, `<w:br w:type="page"/>`)
+		})
+
+		Convey("It should add row titles as headings", func() {
+			So(doc, ShouldContainSubstring, `<w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t xml:space="preserve">Database</w:t>`)
+		})
+
+		Convey("It should embed one picture per panel", func() {
+			So(strings.Count(doc, "<pic:pic>"), ShouldEqual, 3)
+			So(files, ShouldContainKey, "word/media/image1.png")
+			So(files, ShouldContainKey, "word/media/image4.png")
+			So(files["word/_rels/document.xml.rels"], ShouldContainSubstring, `Target="media/image4.png"`)
+		})
+
+		Convey("Pictures should be sized from gridPos in grid layout", func() {
+			rep := new(gClient, "abc123", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Format: FormatDOCX, GridLayout: true})
+			defer rep.Clean()
+			file, err := rep.Generate()
+			So(err, ShouldBeNil)
+			file.Close()
+			doc := readZipFiles(rep.docxPath())["word/document.xml"]
+			So(doc, ShouldContainSubstring, `<wp:extent cx="2865755" cy="955251"/>`)
+			So(doc, ShouldContainSubstring, `<wp:extent cx="5731510" cy="1910503"/>`)
+		})
+
+		Convey("Half width panels should share a paragraph", func() {
+			So(doc, ShouldContainSubstring, `</w:drawing></w:r><w:r><w:drawing>`)
+		})
+
+		Convey("Rows should start on a new page if requested", func() {
+			So(strings.Count(doc, `<w:br w:type="page"/>`), ShouldEqual, 1)
+			rep := new(gClient, "abc123", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Format: FormatDOCX, RowBreak: true})
+			defer rep.Clean()
+			file, err := rep.Generate()
+			So(err, ShouldBeNil)
+			file.Close()
+			doc := readZipFiles(rep.docxPath())["word/document.xml"]
+			So(strings.Count(doc, `<w:br w:type="page"/>`), ShouldEqual, 2)
+		})
+	})
+}
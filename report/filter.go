@@ -0,0 +1,133 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/IzakMarais/reporter/grafana"
+)
+
+// PanelFilter selects the panels of a dashboard to include in a report. A panel is included if it passes every filter
+// that is set: empty filters pass all panels.
+type PanelFilter struct {
+	// IDs includes only the panels with these ids
+	IDs []int
+	// ExcludeIDs leaves out the panels with these ids
+	ExcludeIDs []int
+	// Title is a regular expression that the plain text panel title must match
+	Title string
+	// Types includes only panels of these types, e.g. graph or singlestat
+	Types []string
+	// Rows includes only the panels of the dashboard rows with these titles, compared case-insensitively
+	Rows []string
+}
+
+// FilterError is returned when the panel filters are invalid or leave no panels to report on
+type FilterError struct {
+	Reason string
+}
+
+func (e *FilterError) Error() string {
+	return "panel filter error: " + e.Reason
+}
+
+// IsEmpty is true if the filter passes all panels
+func (f PanelFilter) IsEmpty() bool {
+	return len(f.IDs) == 0 && len(f.ExcludeIDs) == 0 && f.Title == "" && len(f.Types) == 0 && len(f.Rows) == 0
+}
+
+// apply returns dash with only the panels that pass the filter, and without the rows left empty
+func (f PanelFilter) apply(dash grafana.Dashboard) (grafana.Dashboard, error) {
+	if f.IsEmpty() {
+		return dash, nil
+	}
+	var title *regexp.Regexp
+	if f.Title != "" {
+		re, err := regexp.Compile(f.Title)
+		if err != nil {
+			return dash, &FilterError{fmt.Sprintf("error parsing panel title pattern %q: %v", f.Title, err)}
+		}
+		title = re
+	}
+	include := func(p grafana.Panel) bool {
+		return (len(f.IDs) == 0 || containsID(f.IDs, p.Id)) &&
+			!containsID(f.ExcludeIDs, p.Id) &&
+			(title == nil || title.MatchString(grafana.PlainText(p.Title))) &&
+			(len(f.Types) == 0 || containsString(f.Types, p.Type))
+	}
+	filter := func(panels []grafana.Panel) []grafana.Panel {
+		var filtered []grafana.Panel
+		for _, p := range panels {
+			if include(p) {
+				filtered = append(filtered, p)
+			}
+		}
+		return filtered
+	}
+
+	if len(dash.Rows) == 0 && len(f.Rows) == 0 {
+		dash.Panels = filter(dash.Panels)
+	} else {
+		var rows []grafana.Row
+		var panels []grafana.Panel
+		for _, r := range dash.Rows {
+			if len(f.Rows) > 0 && !containsFold(f.Rows, grafana.PlainText(r.Title)) {
+				continue
+			}
+			r.Panels = filter(r.Panels)
+			if len(r.Panels) > 0 {
+				rows = append(rows, r)
+				panels = append(panels, r.Panels...)
+			}
+		}
+		dash.Rows, dash.Panels = rows, panels
+	}
+	if len(dash.Panels) == 0 {
+		return dash, &FilterError{"no panels of the dashboard match the filters"}
+	}
+	return dash, nil
+}
+
+func containsID(ids []int, id int) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, s string) bool {
+	for _, l := range list {
+		if strings.EqualFold(l, s) {
+			return true
+		}
+	}
+	return false
+}
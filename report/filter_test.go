@@ -0,0 +1,81 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/IzakMarais/reporter/grafana"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPanelFilter(t *testing.T) {
+	Convey("When filtering the panels of a dashboard", t, func() {
+		dash := grafana.NewDashboard([]byte(v5DashJSON), url.Values{})
+		ids := func(f PanelFilter) []int {
+			filtered, err := f.apply(dash)
+			So(err, ShouldBeNil)
+			ids := []int{}
+			for _, p := range filtered.Panels {
+				ids = append(ids, p.Id)
+			}
+			return ids
+		}
+
+		Convey("An empty filter should keep all panels", func() {
+			So(ids(PanelFilter{}), ShouldResemble, []int{1, 2, 4})
+		})
+
+		Convey("It should include and exclude panels by id", func() {
+			So(ids(PanelFilter{IDs: []int{1, 4}}), ShouldResemble, []int{1, 4})
+			So(ids(PanelFilter{ExcludeIDs: []int{1}}), ShouldResemble, []int{2, 4})
+		})
+
+		Convey("It should match the plain text title, the type and the row", func() {
+			So(ids(PanelFilter{Title: "^Queries <"}), ShouldResemble, []int{4})
+			So(ids(PanelFilter{Types: []string{"singlestat"}}), ShouldResemble, []int{1, 2})
+			So(ids(PanelFilter{Rows: []string{"database"}}), ShouldResemble, []int{4})
+		})
+
+		Convey("It should drop the rows left without panels", func() {
+			filtered, _ := PanelFilter{Types: []string{"graph"}}.apply(dash)
+			So(filtered.Rows, ShouldHaveLength, 1)
+			So(filtered.Rows[0].Title, ShouldEqual, "Database")
+		})
+
+		Convey("An invalid title pattern or a filter that matches no panels should be an error", func() {
+			_, err := PanelFilter{Title: "("}.apply(dash)
+			So(err, ShouldHaveSameTypeAs, &FilterError{})
+			_, err = PanelFilter{IDs: []int{99}}.apply(dash)
+			So(err, ShouldHaveSameTypeAs, &FilterError{})
+		})
+	})
+
+	Convey("When generating a report with a panel filter", t, func() {
+		gClient := &v5Client{mockGrafanaClient{0, url.Values{}}}
+		rep := new(gClient, "abc123", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Filter: PanelFilter{IDs: []int{4}}})
+		defer rep.Clean()
+		dash, err := rep.dashboard()
+		So(err, ShouldBeNil)
+		So(rep.renderPNGsParallel(dash), ShouldBeNil)
+
+		Convey("Only the included panels should be rendered", func() {
+			So(gClient.getPanelCallCount, ShouldEqual, 1)
+		})
+	})
+}
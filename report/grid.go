@@ -0,0 +1,228 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"math"
+	"sort"
+
+	"github.com/IzakMarais/reporter/grafana"
+)
+
+const (
+	// gridColumns is the width of a Grafana dashboard in gridPos units
+	gridColumns = 24
+	// gridDefaultHeight is the height given to panels without a gridPos, e.g. from Grafana v4 dashboards
+	gridDefaultHeight = 8
+	// gridPadding is the space left around each panel, in gridPos units, like the gaps between panels on the dashboard
+	gridPadding = 0.1
+	// gridWideAspect is the width to height ratio from which a panel is turned sideways with OrientationAuto
+	gridWideAspect = 4
+	// gridMinScale is how far the bands of a page are scaled down to fit one more band, rather than starting a new page
+	gridMinScale = 0.85
+	// gridTitleHeight, gridHeadingHeight and gridBandSpace estimate the space in bp taken by the title,
+	// a section heading and the line break after a band
+	gridTitleHeight   = 2.5 * inch
+	gridHeadingHeight = 0.6 * inch
+	gridBandSpace     = 2
+)
+
+// gridPage is a page of bands in grid layout
+type gridPage struct {
+	// Landscape is true if the page is turned sideways in a portrait document
+	Landscape bool
+	Bands     []gridBand
+	// wide is true if the page is wider than high, either turned sideways or in a landscape document
+	wide bool
+	// width and height are the size of the text block, used is the height of the bands at full size and
+	// fixed the height of everything that is not scaled, in bp
+	width, height, used, fixed float64
+}
+
+// gridBand is a horizontal strip of a dashboard section that no panel crosses.
+// The grid layout breaks pages between bands, never through one.
+type gridBand struct {
+	// Heading is the title of the section that starts with this band, if any
+	Heading string
+	// Unit is the size of a gridPos unit as a fraction of the text width: 1/24, or less if the band is scaled down to fit on a page
+	Unit float64
+	// Height is the height of the band in gridPos units, including empty space above its panels
+	Height float64
+	Panels []gridPanel
+}
+
+// gridPanel is a panel placed in a band
+type gridPanel struct {
+	grafana.Panel
+	// X and Y are the top left corner of the panel's image in gridPos units, relative to the top left of the band
+	X, Y float64
+	// ImageWidth and ImageHeight are the size of the panel's image as fractions of the text width
+	ImageWidth, ImageHeight float64
+	w, h                    float64
+}
+
+type gridBox struct {
+	panel      grafana.Panel
+	x, y, w, h float64
+}
+
+// gridBands places the panels at their gridPos and cuts them into bands at full size.
+// Panels without a gridPos take the full width below the panels before them.
+func gridBands(panels []grafana.Panel) []gridBand {
+	boxes := make([]gridBox, 0, len(panels))
+	bottom := 0.0
+	for _, p := range panels {
+		g := p.GridPos
+		b := gridBox{p, g.X, g.Y, g.W, g.H}
+		if g.W <= 0 || g.H <= 0 {
+			b = gridBox{p, 0, bottom, gridColumns, gridDefaultHeight}
+		}
+		boxes = append(boxes, b)
+		bottom = math.Max(bottom, b.y+b.h)
+	}
+	if len(boxes) == 0 {
+		return nil
+	}
+	sort.SliceStable(boxes, func(i, j int) bool {
+		if boxes[i].y != boxes[j].y {
+			return boxes[i].y < boxes[j].y
+		}
+		return boxes[i].x < boxes[j].x
+	})
+
+	var bands []gridBand
+	start := boxes[0].y //the space above the first panel is not kept
+	for i := 0; i < len(boxes); {
+		end := boxes[i].y + boxes[i].h
+		j := i + 1
+		for ; j < len(boxes) && boxes[j].y < end; j++ {
+			end = math.Max(end, boxes[j].y+boxes[j].h)
+		}
+		band := gridBand{Height: end - start}
+		for _, b := range boxes[i:j] {
+			band.Panels = append(band.Panels, gridPanel{Panel: b.panel, X: round(b.x + gridPadding), Y: round(b.y - start + gridPadding), w: b.w, h: b.h})
+		}
+		band.scale(1)
+		bands = append(bands, band)
+		start, i = end, j
+	}
+	return bands
+}
+
+// scale sizes the band's images for a gridPos unit of s/24 of the text width
+func (b *gridBand) scale(s float64) {
+	b.Unit = round(s / gridColumns)
+	for i, p := range b.Panels {
+		b.Panels[i].ImageWidth = round((p.w - 2*gridPadding) * s / gridColumns)
+		b.Panels[i].ImageHeight = round((p.h - 2*gridPadding) * s / gridColumns)
+	}
+}
+
+// wide is true if the band has a panel that is much wider than it is high
+func (b gridBand) wide() bool {
+	for _, p := range b.Panels {
+		if p.w >= gridWideAspect*p.h {
+			return true
+		}
+	}
+	return false
+}
+
+// gridPages cuts the sections into bands and fills pages with them. The bands of a page that overflows by a little
+// are scaled down to fit, and a band taller than a page is scaled down on a page of its own. The first page leaves room
+// for the title if title is set, a titled section starts a new page if rowBreak is set, and with OrientationAuto
+// bands with very wide panels are put on landscape pages.
+func gridPages(sections []grafana.Row, s pageSettings, title, rowBreak bool) []gridPage {
+	var pages []gridPage
+	page := newGridPage(s, s.Landscape)
+	if title {
+		page.fixed = gridTitleHeight
+	}
+	next := func(landscape bool) {
+		if len(page.Bands) > 0 {
+			pages = append(pages, page.finish())
+		}
+		page = newGridPage(s, landscape)
+	}
+	for i, sec := range sections {
+		bands := gridBands(sec.Panels)
+		if len(bands) == 0 && sec.Title != "" {
+			bands = []gridBand{{}}
+		}
+		for j, b := range bands {
+			landscape := s.Landscape || (s.Auto && b.wide())
+			if j == 0 {
+				b.Heading = sec.Title
+			}
+			if landscape != page.wide || (j == 0 && sec.Title != "" && rowBreak && i > 0) || !page.fits(b) {
+				next(landscape)
+			}
+			page.add(b)
+		}
+	}
+	next(s.Landscape)
+	return pages
+}
+
+func newGridPage(s pageSettings, landscape bool) gridPage {
+	w, h := s.textSize(landscape)
+	return gridPage{Landscape: landscape && !s.Landscape, wide: landscape, width: w, height: h}
+}
+
+func (p gridPage) space(b gridBand) float64 {
+	if b.Heading != "" {
+		return gridBandSpace + gridHeadingHeight
+	}
+	return gridBandSpace
+}
+
+// fits is true if b fits on the page, at least when scaled down by gridMinScale together with the page's other bands
+func (p gridPage) fits(b gridBand) bool {
+	if len(p.Bands) == 0 {
+		return true
+	}
+	used := p.used + b.Height*p.width/gridColumns
+	return used*gridMinScale <= p.height-p.fixed-p.space(b)
+}
+
+func (p *gridPage) add(b gridBand) {
+	p.fixed += p.space(b)
+	p.used += b.Height * p.width / gridColumns
+	p.Bands = append(p.Bands, b)
+}
+
+// finish scales the bands down if they overflow the page
+func (p gridPage) finish() gridPage {
+	available := math.Max(p.height-p.fixed, p.height/4)
+	if p.used > available {
+		for i := range p.Bands {
+			p.Bands[i].scale(available / p.used)
+		}
+	}
+	return p
+}
+
+// round rounds to 6 decimals, so that numbers are printed without an exponent in TeX templates
+func round(f float64) float64 {
+	return math.Round(f*1e6) / 1e6
+}
+
+// GridPages returns the sections' panels placed at their gridPos and paginated for the grid layout.
+// The first page leaves room for the title, unless the title is on a cover page or followed by a table of contents.
+func (d templData) GridPages() []gridPage {
+	return gridPages(d.Sections(), d.Page, !d.TOC && !d.Branding.Cover, d.RowBreak)
+}
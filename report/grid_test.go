@@ -0,0 +1,161 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"io/ioutil"
+	"net/url"
+	"testing"
+
+	"github.com/IzakMarais/reporter/grafana"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func panelAt(id int, x, y, w, h float64) grafana.Panel {
+	return grafana.Panel{Id: id, GridPos: grafana.GridPos{X: x, Y: y, W: w, H: h}}
+}
+
+func TestGridBands(t *testing.T) {
+	Convey("When laying out panels at their gridPos", t, func() {
+		ids := func(b gridBand) []int {
+			ids := []int{}
+			for _, p := range b.Panels {
+				ids = append(ids, p.Id)
+			}
+			return ids
+		}
+
+		Convey("Panels next to and stacked beside each other should share a band", func() {
+			bands := gridBands([]grafana.Panel{panelAt(1, 0, 10, 12, 8), panelAt(2, 12, 10, 12, 4), panelAt(3, 12, 14, 12, 4)})
+			So(bands, ShouldHaveLength, 1)
+			So(bands[0].Height, ShouldEqual, 8)
+			So(bands[0].Unit, ShouldEqual, round(1.0/24))
+			So(ids(bands[0]), ShouldResemble, []int{1, 2, 3})
+			p := bands[0].Panels[2]
+			So(p.X, ShouldEqual, 12.1)
+			So(p.Y, ShouldEqual, 4.1)
+			So(p.ImageWidth, ShouldEqual, round(11.8/24))
+			So(p.ImageHeight, ShouldEqual, round(3.8/24))
+		})
+
+		Convey("Bands should be cut where no panel crosses, keeping the gaps between them", func() {
+			bands := gridBands([]grafana.Panel{panelAt(2, 0, 10, 24, 4), panelAt(1, 0, 0, 24, 4)})
+			So(bands, ShouldHaveLength, 2)
+			So(ids(bands[0]), ShouldResemble, []int{1})
+			So(bands[1].Height, ShouldEqual, 10)
+			So(bands[1].Panels[0].Y, ShouldEqual, 6.1)
+		})
+
+		Convey("Panels without a gridPos should take the full width below the others", func() {
+			bands := gridBands([]grafana.Panel{panelAt(1, 0, 0, 12, 4), {Id: 2}, {Id: 3}})
+			So(bands, ShouldHaveLength, 3)
+			So(bands[1].Height, ShouldEqual, gridDefaultHeight)
+			So(bands[2].Panels[0].ImageWidth, ShouldEqual, round(23.8/24))
+		})
+
+		Convey("No panels should give no bands", func() {
+			So(gridBands(nil), ShouldBeEmpty)
+		})
+	})
+
+	Convey("When paginating panels at their gridPos on letter paper with 0.5in margins", t, func() {
+		settings, _ := Options{GridLayout: true}.pageSettings(PaperLetter)
+		graphs := func(ids ...int) []grafana.Panel {
+			var panels []grafana.Panel
+			for i, id := range ids {
+				panels = append(panels, grafana.Panel{Id: id, GridPos: grafana.GridPos{X: 0, Y: float64(8 * i), W: 24, H: 8}})
+			}
+			return panels
+		}
+		bands := func(pages []gridPage) []int {
+			n := []int{}
+			for _, p := range pages {
+				n = append(n, len(p.Bands))
+			}
+			return n
+		}
+
+		Convey("Pages should be filled with bands, leaving room for the title on the first", func() {
+			pages := gridPages([]grafana.Row{{Panels: graphs(1, 2, 3, 4, 5, 6)}}, settings, true, false)
+			So(bands(pages), ShouldResemble, []int{3, 3})
+			So(pages[1].Bands[0].Unit, ShouldEqual, round(1.0/24))
+		})
+
+		Convey("A page that overflows by a little should be scaled down to fit", func() {
+			pages := gridPages([]grafana.Row{{Panels: graphs(1, 2, 3, 4, 5, 6)}}, settings, true, false)
+			So(pages[0].Bands[0].Unit, ShouldBeLessThan, round(1.0/24))
+			So(pages[0].Bands[0].Unit, ShouldBeGreaterThan, round(gridMinScale/24))
+		})
+
+		Convey("The first page should not leave room for a title on a cover page", func() {
+			dash := grafana.Dashboard{Rows: []grafana.Row{{Panels: graphs(1, 2, 3, 4, 5, 6)}}}
+			pages := templData{Dashboard: dash, Page: settings, Branding: Branding{Cover: true}}.GridPages()
+			So(bands(pages), ShouldResemble, []int{4, 2})
+			So(pages[0].fixed, ShouldBeLessThan, gridTitleHeight)
+
+			pages = templData{Dashboard: dash, Page: settings}.GridPages()
+			So(bands(pages), ShouldResemble, []int{3, 3})
+		})
+
+		Convey("A band taller than a page should be scaled down on a page of its own", func() {
+			pages := gridPages([]grafana.Row{{Panels: []grafana.Panel{panelAt(1, 0, 0, 24, 60)}}}, settings, false, false)
+			So(bands(pages), ShouldResemble, []int{1})
+			So(pages[0].Bands[0].Unit, ShouldEqual, round(718.0/1350/24))
+			So(pages[0].Bands[0].Panels[0].ImageWidth, ShouldEqual, round(23.8*718/1350/24))
+		})
+
+		Convey("Titled sections should start with a heading, and a new page with row breaks", func() {
+			sections := []grafana.Row{{Title: "A", Panels: graphs(1)}, {Title: "B", Panels: graphs(2)}, {Title: "C"}}
+			pages := gridPages(sections, settings, true, false)
+			So(bands(pages), ShouldResemble, []int{3})
+			So(pages[0].Bands[1].Heading, ShouldEqual, "B")
+			So(pages[0].Bands[2].Panels, ShouldBeEmpty)
+			So(bands(gridPages(sections, settings, true, true)), ShouldResemble, []int{1, 1, 1})
+		})
+
+		Convey("With automatic orientation, very wide panels should go on a landscape page", func() {
+			sections := []grafana.Row{{Panels: []grafana.Panel{panelAt(1, 0, 0, 24, 8), panelAt(2, 0, 8, 24, 4), panelAt(3, 0, 12, 24, 8)}}}
+			auto, _ := Options{GridLayout: true, Orientation: OrientationAuto}.pageSettings(PaperLetter)
+			pages := gridPages(sections, auto, true, false)
+			So(bands(pages), ShouldResemble, []int{1, 1, 1})
+			So(pages[1].Landscape, ShouldBeTrue)
+			So(pages[2].Landscape, ShouldBeFalse)
+
+			landscape, _ := Options{GridLayout: true, Orientation: OrientationLandscape}.pageSettings(PaperLetter)
+			pages = gridPages(sections, landscape, false, false)
+			So(bands(pages), ShouldResemble, []int{3})
+			So(pages[0].Landscape, ShouldBeFalse)
+		})
+	})
+
+	Convey("When generating the TeX file in grid layout", t, func() {
+		gClient := &v5Client{mockGrafanaClient{0, url.Values{}}}
+		rep := new(gClient, "abc123", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{GridLayout: true})
+		defer rep.Clean()
+		dash, _ := gClient.GetDashboard("")
+		So(rep.generateTeXFile(dash), ShouldBeNil)
+		b, _ := ioutil.ReadFile(rep.texPath())
+		s := string(b)
+
+		Convey("It should place the panels with tikz", func() {
+			So(s, ShouldContainSubstring, `\usepackage{tikz}`)
+			So(s, ShouldContainSubstring, `\usepackage[letterpaper,margin=0.5in]{geometry}`)
+			So(s, ShouldContainSubstring, `\begin{tikzpicture}[x=0.041667\textwidth,y=-0.041667\textwidth]`)
+			So(s, ShouldContainSubstring, `\node[anchor=north west,inner sep=0pt] at (12.1,0.1) {\includegraphics[width=0.491667\textwidth,height=0.158333\textwidth]{image2}};`)
+		})
+	})
+}
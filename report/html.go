@@ -0,0 +1,95 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/IzakMarais/reporter/grafana"
+)
+
+// htmlRenderer fills in an HTML template. Panel images are inlined as base64 data URIs
+// so that the report is a single portable file.
+type htmlRenderer struct{}
+
+func (htmlRenderer) render(rep *report, dash grafana.Dashboard) (io.ReadCloser, error) {
+	htmlTemplate := defaultHTMLTemplate
+	if rep.opts.GridLayout {
+		htmlTemplate = defaultGridHTMLTemplate
+	}
+	sources, err := rep.templateSources(htmlTemplate, FileExtension(FormatHTML))
+	if err != nil {
+		return nil, err
+	}
+
+	funcs := template.FuncMap(templateFuncs())
+	funcs["image"] = func(p grafana.Panel) (template.URL, error) {
+		return rep.imageDataURI(p)
+	}
+	funcs["logo"] = func() (template.URL, error) {
+		return logoDataURI(rep.opts.branding())
+	}
+	funcs["width"] = panelWidth
+	funcs["percent"] = func(fraction float64) string {
+		return strconv.FormatFloat(fraction*100, 'f', 2, 64) + "%"
+	}
+	tmpl := template.New("report").Delims("[[", "]]").Funcs(funcs)
+	for _, src := range sources {
+		t := tmpl
+		if src.name != tmpl.Name() {
+			t = tmpl.New(src.name)
+		}
+		if _, err = t.Parse(src.text); err != nil {
+			return nil, fmt.Errorf("error parsing html template %s: %v", src.name, err)
+		}
+	}
+
+	err = os.MkdirAll(rep.tmpDir, 0777)
+	if err != nil {
+		return nil, fmt.Errorf("error creating temporary directory at %v: %v", rep.tmpDir, err)
+	}
+	file, err := os.Create(rep.htmlPath())
+	if err != nil {
+		return nil, fmt.Errorf("error creating html file at %v : %v", rep.htmlPath(), err)
+	}
+	data := rep.templData(plainDashboard(dash), false)
+	err = tmpl.Execute(file, data)
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error executing html template:%v", err)
+	}
+
+	html, err := os.Open(rep.htmlPath())
+	if err != nil {
+		return nil, err
+	}
+	return html, nil
+}
+
+func (rep *report) imageDataURI(p grafana.Panel) (template.URL, error) {
+	png, err := ioutil.ReadFile(rep.imgFilePath(p))
+	if err != nil {
+		return "", fmt.Errorf("error reading image for panel %d: %v", p.Id, err)
+	}
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(png)), nil
+}
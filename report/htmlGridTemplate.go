@@ -0,0 +1,70 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+const defaultGridHTMLTemplate = `<!DOCTYPE html>
+<!-- use square brackets as golang html templating delimiters, as in the TeX templates -->
+<!-- custom templates can override the blocks below by defining templates of the same name, e.g. "title" -->
+<html lang="[[.Locale]]">
+<head>
+<meta charset="utf-8">
+<title>[[.Title]]</title>
+<style>
+[[block "style" .]]body { font-family: sans-serif; max-width: 1200px; margin: 0.5in auto; text-align: center; }
+.title { margin-bottom: 1cm; }
+.panel { width: 100%; margin: 0.5cm 0; }
+.partial { vertical-align: middle; }
+.row h2 { text-align: left; }
+.break { page-break-before: always; }
+.failures { text-align: left; page-break-before: always; }
+.logo { max-height: 2cm; }
+.cover { min-height: 90vh; page-break-after: always; }
+.disclaimer { font-size: small; margin-top: 1cm; }
+[[if .Branding.Color]]h1, h2 { color: [[.Branding.Color]]; }
+[[end]][[end]]</style>
+[[block "head" .]][[end]]
+</head>
+<body>
+[[block "title" .]]<div class="title[[if .Branding.Cover]] cover[[end]]">
+[[if .Branding.Logo]]<img class="logo" src="[[logo]]" alt="[[.Branding.Company]]">
+[[end]]<h1>[[.Title]]</h1>
+[[if .VariableValues]]<h3>[[.VariableValues]]</h3>[[end]]
+[[if .Description]]<p><small>[[.Description]]</small></p>[[end]]
+<p>[[.FromFormatted]]<br>[[.Message "to"]]<br>[[.ToFormatted]]</p>
+[[if .Branding.Company]]<p class="company">[[.Branding.Company]]</p>
+[[end]][[if and .Branding.Cover .Branding.Disclaimer]]<p class="disclaimer">[[.Branding.Disclaimer]]</p>
+[[end]]</div>
+[[end]]
+[[block "panels" .]][[range $i, $row := .Sections]]<div class="row[[if and $.RowBreak $i .Title]] break[[end]]">
+[[if .Title]]<h2>[[.Title]]</h2>
+[[end]][[range .Panels]][[block "panel" .]][[if lt (width .) 1.0]]<img class="partial" style="width: [[percent (width .)]]" src="[[image .]]" alt="[[.Title]]">
+[[else]]<div><img class="panel" src="[[image .]]" alt="[[.Title]]"></div>
+[[end]][[end]][[end]]</div>
+[[end]][[end]]
+[[block "failures" .]][[if .Failures]]<div class="failures">
+<h2>[[.Message "failures"]]</h2>
+<ul>
+[[range .Failures]]<li>[[$.Message "panel"]] [[.Panel.Id]][[if .Panel.Title]] &ldquo;[[.Panel.Title]]&rdquo;[[end]]: [[.Error]]</li>
+[[end]]</ul>
+</div>
+[[end]][[end]]
+[[block "disclaimer" .]][[if and .Branding.Disclaimer (not .Branding.Cover)]]<p class="disclaimer">[[.Branding.Disclaimer]]</p>
+[[end]][[end]]
+[[block "closing" .]][[end]]
+</body>
+</html>
+`
@@ -0,0 +1,174 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/IzakMarais/reporter/grafana"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const v4SpanDashJSON = `
+{"Dashboard":
+	{
+		"Title":"Spans",
+		"Rows":
+		[{"Panels":
+			[{"Type":"graph", "Id":1, "Span":6},
+			 {"Type":"graph", "Id":2, "Span":6},
+			 {"Type":"graph", "Id":3, "Span":12}]
+		}]
+	}
+}`
+
+type spanClient struct {
+	mockGrafanaClient
+}
+
+func (m *spanClient) GetDashboard(dashName string) (grafana.Dashboard, error) {
+	return grafana.NewDashboard([]byte(v4SpanDashJSON), m.variables), nil
+}
+
+func TestHTMLReport(t *testing.T) {
+	Convey("When generating an HTML report", t, func() {
+		variables := url.Values{}
+		variables.Add("var-test", "test<value>")
+		gClient := &mockGrafanaClient{0, variables}
+		rep := new(gClient, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Format: FormatHTML})
+		defer rep.Clean()
+
+		html, err := rep.Generate()
+		So(err, ShouldBeNil)
+		defer html.Close()
+		var buf bytes.Buffer
+		io.Copy(&buf, html)
+		s := buf.String()
+
+		Convey("It should contain the title and escaped variable values", func() {
+			So(s, ShouldContainSubstring, "<h1>My first dashboard</h1>")
+			So(s, ShouldContainSubstring, "test&lt;value&gt;")
+		})
+
+		Convey("It should inline every panel image as a data URI", func() {
+			encoded := base64.StdEncoding.EncodeToString([]byte("Not actually a png"))
+			So(strings.Count(s, "data:image/png;base64,"+encoded), ShouldEqual, 9)
+		})
+
+		Convey("It should lay out singlestat panels side by side", func() {
+			So(strings.Count(s, `class="singlestat"`), ShouldEqual, 2)
+		})
+	})
+
+	Convey("When generating an HTML report with grid layout", t, func() {
+		gClient := &mockGrafanaClient{0, url.Values{}}
+		rep := new(gClient, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Format: FormatHTML, GridLayout: true})
+		defer rep.Clean()
+
+		html, err := rep.Generate()
+		So(err, ShouldBeNil)
+		defer html.Close()
+		var buf bytes.Buffer
+		io.Copy(&buf, html)
+
+		Convey("Panels without a gridPos should be full width, except for singlestat panels", func() {
+			So(strings.Count(buf.String(), `<img class="partial" style="width: 30.00%"`), ShouldEqual, 2)
+			So(strings.Count(buf.String(), `<div><img class="panel"`), ShouldEqual, 7)
+		})
+	})
+
+	Convey("When generating an HTML report with grid layout of a v4 dashboard with panel spans", t, func() {
+		gClient := &spanClient{mockGrafanaClient{0, url.Values{}}}
+		rep := new(gClient, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Format: FormatHTML, GridLayout: true})
+		defer rep.Clean()
+
+		html, err := rep.Generate()
+		So(err, ShouldBeNil)
+		defer html.Close()
+		var buf bytes.Buffer
+		io.Copy(&buf, html)
+
+		Convey("Partial width panels should be sized from their span", func() {
+			So(strings.Count(buf.String(), `<img class="partial" style="width: 50.00%"`), ShouldEqual, 2)
+			So(strings.Count(buf.String(), `<div><img class="panel"`), ShouldEqual, 1)
+		})
+	})
+
+	Convey("When generating an HTML report of a dashboard with rows", t, func() {
+		gClient := &v5Client{mockGrafanaClient{0, url.Values{}}}
+		rep := new(gClient, "abc123", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Format: FormatHTML, RowBreak: true})
+		defer rep.Clean()
+
+		html, err := rep.Generate()
+		So(err, ShouldBeNil)
+		defer html.Close()
+		var buf bytes.Buffer
+		io.Copy(&buf, html)
+		s := buf.String()
+
+		Convey("It should add a section per row, with a heading if the row shows its title", func() {
+			So(strings.Count(s, `<div class="row`), ShouldEqual, 2)
+			So(s, ShouldContainSubstring, "<h2>Database</h2>")
+		})
+
+		Convey("Titled rows after the first should start on a new page", func() {
+			So(s, ShouldContainSubstring, `<div class="row break">
+<h2>Database</h2>`)
+			So(strings.Count(s, `class="row break"`), ShouldEqual, 1)
+		})
+	})
+
+	Convey("When generating an HTML report with a custom template", t, func() {
+		gClient := &mockGrafanaClient{0, url.Values{}}
+		rep := new(gClient, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Format: FormatHTML, Template: "<p>[[.Title]]</p>"})
+		defer rep.Clean()
+
+		html, err := rep.Generate()
+		So(err, ShouldBeNil)
+		defer html.Close()
+		var buf bytes.Buffer
+		io.Copy(&buf, html)
+
+		Convey("It should use the custom template", func() {
+			So(buf.String(), ShouldEqual, "<p>My first dashboard</p>")
+		})
+	})
+
+	Convey("When generating an HTML report with a custom template that overrides a block", t, func() {
+		gClient := &mockGrafanaClient{0, url.Values{}}
+		opts := Options{Format: FormatHTML, Template: `[[define "title"]]<h2>[[.Title]]</h2>[[end]]`}
+		rep := new(gClient, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, opts)
+		defer rep.Clean()
+
+		html, err := rep.Generate()
+		So(err, ShouldBeNil)
+		defer html.Close()
+		var buf bytes.Buffer
+		io.Copy(&buf, html)
+
+		Convey("It should keep the rest of the default template", func() {
+			So(buf.String(), ShouldContainSubstring, "<h2>My first dashboard</h2>")
+			So(buf.String(), ShouldNotContainSubstring, "<h1>")
+			So(buf.String(), ShouldContainSubstring, `<img class="panel"`)
+		})
+	})
+}
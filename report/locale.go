@@ -0,0 +1,268 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// LocaleEnglish is the default locale
+	LocaleEnglish = "en"
+	LocaleFrench  = "fr"
+	LocaleGerman  = "de"
+)
+
+// locale holds the translated text of the default templates and the conventions for formatting dates and numbers
+type locale struct {
+	name string
+	// region is the country of a built-in locale's language tag, e.g. FR for fr-FR
+	region   string
+	messages map[string]string
+	// months, shortMonths, days and shortDays replace the English names in date layouts. English names are kept if nil.
+	months, shortMonths, days, shortDays []string
+	// dateLayout is the layout of the report's time range, as a Go time layout
+	dateLayout string
+	// shortLayout is the layout of the start and end of compared periods
+	shortLayout        string
+	decimal, thousands string
+}
+
+var locales = map[string]locale{
+	LocaleEnglish: {
+		name:   LocaleEnglish,
+		region: "US",
+		messages: map[string]string{
+			"to":       "to",
+			"page":     "Page",
+			"of":       "of",
+			"contents": "Contents",
+			"failures": "Panels that failed to render",
+			"panel":    "Panel",
+			"earlier":  "{shift} earlier",
+			"compared": "compared period {from} to {to}",
+		},
+		dateLayout:  time.UnixDate,
+		shortLayout: "2006-01-02 15:04",
+		decimal:     ".",
+		thousands:   ",",
+	},
+	LocaleFrench: {
+		name:   LocaleFrench,
+		region: "FR",
+		messages: map[string]string{
+			"to":       "au",
+			"page":     "Page",
+			"of":       "sur",
+			"contents": "Table des matières",
+			"failures": "Panneaux dont le rendu a échoué",
+			"panel":    "Panneau",
+			"earlier":  "{shift} plus tôt",
+			"compared": "période comparée du {from} au {to}",
+		},
+		months:      []string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		shortMonths: []string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		days:        []string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		shortDays:   []string{"dim.", "lun.", "mar.", "mer.", "jeu.", "ven.", "sam."},
+		dateLayout:  "Monday 2 January 2006 15:04:05 MST",
+		shortLayout: "02/01/2006 15:04",
+		decimal:     ",",
+		thousands:   "\u00a0", //a no-break space
+	},
+	LocaleGerman: {
+		name:   LocaleGerman,
+		region: "DE",
+		messages: map[string]string{
+			"to":       "bis",
+			"page":     "Seite",
+			"of":       "von",
+			"contents": "Inhaltsverzeichnis",
+			"failures": "Panels, die nicht gerendert werden konnten",
+			"panel":    "Panel",
+			"earlier":  "{shift} früher",
+			"compared": "Vergleichszeitraum {from} bis {to}",
+		},
+		months:      []string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		shortMonths: []string{"Jan.", "Feb.", "März", "Apr.", "Mai", "Juni", "Juli", "Aug.", "Sep.", "Okt.", "Nov.", "Dez."},
+		days:        []string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		shortDays:   []string{"So.", "Mo.", "Di.", "Mi.", "Do.", "Fr.", "Sa."},
+		dateLayout:  "Monday, 2. January 2006 15:04:05 MST",
+		shortLayout: "02.01.2006 15:04",
+		decimal:     ",",
+		thousands:   ".",
+	},
+}
+
+// catalogueFile is the name of the message catalogue of a locale in the templates directory
+// languageTag returns the language tag of the locale with its region, e.g. fr-FR for fr, or fr-CH,
+// for formats like pptx that tag text with a region
+func (l locale) languageTag() string {
+	if l.region == "" || strings.Contains(l.name, "-") {
+		return l.name
+	}
+	return l.name + "-" + l.region
+}
+
+func catalogueFile(name string) string {
+	return "messages." + name + ".json"
+}
+
+// loadLocale returns the named locale, e.g. fr or fr-CH, with the messages of its catalogues in templateDir, if any:
+// messages.fr.json and then messages.fr-CH.json, each a JSON object of message keys and their text.
+// A locale without built-in support needs a catalogue, and formats dates and numbers like English.
+func loadLocale(name, templateDir string) (locale, error) {
+	if name == "" {
+		name = LocaleEnglish
+	}
+	name = strings.Replace(name, "_", "-", -1)
+	lang := strings.SplitN(name, "-", 2)[0]
+	l, builtin := locales[lang]
+	if !builtin {
+		l = locales[LocaleEnglish]
+		l.region = ""
+	}
+	l.name = name
+	l.messages = copyMessages(l.messages)
+
+	names := []string{lang}
+	if name != lang {
+		names = append(names, name)
+	}
+	found := false
+	if templateDir != "" {
+		for _, n := range names {
+			b, err := ioutil.ReadFile(filepath.Join(templateDir, catalogueFile(n)))
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return l, fmt.Errorf("error reading message catalogue: %v", err)
+			}
+			var messages map[string]string
+			if err = json.Unmarshal(b, &messages); err != nil {
+				return l, fmt.Errorf("error parsing message catalogue %v: %v", catalogueFile(n), err)
+			}
+			for k, v := range messages {
+				l.messages[k] = v
+			}
+			found = true
+		}
+	}
+	if !builtin && !found {
+		return l, fmt.Errorf("unknown locale %q, expected one of %s, %s or %s, or a message catalogue %s in the templates directory",
+			name, LocaleEnglish, LocaleFrench, LocaleGerman, catalogueFile(name))
+	}
+	return l, nil
+}
+
+func copyMessages(m map[string]string) map[string]string {
+	c := map[string]string{}
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// message returns the text of the message key, in English if the locale does not translate it, or else the key itself
+func (l locale) message(key string) string {
+	if m, ok := l.messages[key]; ok {
+		return m
+	}
+	if m, ok := locales[LocaleEnglish].messages[key]; ok {
+		return m
+	}
+	return key
+}
+
+// messageWith returns the text of the message key with its placeholders replaced, given as pairs of placeholder and value
+func (l locale) messageWith(key string, placeholders ...string) string {
+	return strings.NewReplacer(placeholders...).Replace(l.message(key))
+}
+
+// dateNames matches the month and day names of Go time layouts, which Format only writes in English
+var dateNames = regexp.MustCompile(`January|Jan|Monday|Mon`)
+
+// formatDate formats t with a Go time layout, with the month and day names of the locale
+func (l locale) formatDate(layout string, t time.Time) string {
+	if l.months == nil {
+		return t.Format(layout)
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range dateNames.FindAllStringIndex(layout, -1) {
+		b.WriteString(t.Format(layout[last:m[0]]))
+		switch layout[m[0]:m[1]] {
+		case "January":
+			b.WriteString(l.months[t.Month()-1])
+		case "Jan":
+			b.WriteString(l.shortMonths[t.Month()-1])
+		case "Monday":
+			b.WriteString(l.days[t.Weekday()])
+		case "Mon":
+			b.WriteString(l.shortDays[t.Weekday()])
+		}
+		last = m[1]
+	}
+	b.WriteString(t.Format(layout[last:]))
+	return b.String()
+}
+
+// formatTime formats t like the report's time range
+func (l locale) formatTime(t time.Time) string {
+	return l.formatDate(l.dateLayout, t)
+}
+
+// formatPeriod formats the start and end of a compared period. The end has no date if it is on the day of the start.
+func (l locale) formatPeriod(from, to time.Time) (string, string) {
+	end := l.formatDate(l.shortLayout, to)
+	if from.Format("2006-01-02") == to.Format("2006-01-02") {
+		end = to.Format("15:04")
+	}
+	return l.formatDate(l.shortLayout, from), end
+}
+
+// formatNumber formats f with the given number of decimals and the locale's decimal and thousands separators
+func (l locale) formatNumber(f float64, decimals int) string {
+	s := strconv.FormatFloat(math.Abs(f), 'f', decimals, 64)
+	whole, frac := s, ""
+	if i := strings.Index(s, "."); i >= 0 {
+		whole, frac = s[:i], s[i+1:]
+	}
+	var b strings.Builder
+	if f < 0 && strings.Trim(s, "0.") != "" {
+		b.WriteString("-")
+	}
+	for i, d := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteString(l.thousands)
+		}
+		b.WriteRune(d)
+	}
+	if frac != "" {
+		b.WriteString(l.decimal + frac)
+	}
+	return b.String()
+}
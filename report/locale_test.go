@@ -0,0 +1,126 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/IzakMarais/reporter/grafana"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLocale(t *testing.T) {
+	Convey("When formatting for a locale", t, func() {
+		date := time.Date(2016, time.February, 9, 14, 5, 0, 0, time.UTC)
+		en, _ := loadLocale("", "")
+		fr, _ := loadLocale("fr", "")
+		de, _ := loadLocale("de_DE", "")
+
+		Convey("English should keep the UnixDate format", func() {
+			So(en.formatTime(date), ShouldEqual, "Tue Feb  9 14:05:00 UTC 2016")
+		})
+
+		Convey("Month and day names should be translated", func() {
+			So(fr.formatTime(date), ShouldEqual, "mardi 9 février 2016 14:05:00 UTC")
+			So(de.formatTime(date), ShouldEqual, "Dienstag, 9. Februar 2016 14:05:00 UTC")
+			So(de.formatDate("Mon 2 Jan 2006", date), ShouldEqual, "Di. 9 Feb. 2016")
+		})
+
+		Convey("Numbers should use the locale's separators", func() {
+			So(en.formatNumber(1234567.891, 2), ShouldEqual, "1,234,567.89")
+			So(fr.formatNumber(1234.5, 1), ShouldEqual, "1\u00a0234,5")
+			So(de.formatNumber(-1234, 0), ShouldEqual, "-1.234")
+			So(de.formatNumber(999, 0), ShouldEqual, "999")
+			So(en.formatNumber(-0.001, 1), ShouldEqual, "0.0")
+		})
+
+		Convey("Messages should fall back to English and then to the key", func() {
+			So(fr.message("to"), ShouldEqual, "au")
+			So(de.message("page"), ShouldEqual, "Seite")
+			So(fr.message("unknown"), ShouldEqual, "unknown")
+		})
+
+		Convey("Placeholders should be replaced in messages", func() {
+			So(fr.messageWith("earlier", "{shift}", "1w"), ShouldEqual, "1w plus tôt")
+			start, end := de.formatPeriod(date, date.Add(time.Hour))
+			So(de.messageWith("compared", "{from}", start, "{to}", end), ShouldEqual, "Vergleichszeitraum 09.02.2016 14:05 bis 15:05")
+		})
+	})
+
+	Convey("When loading a locale with message catalogues", t, func() {
+		dir, err := ioutil.TempDir("", "templates")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		ioutil.WriteFile(filepath.Join(dir, "messages.fr.json"), []byte(`{"to": "jusqu'au", "summary": "Résumé"}`), 0666)
+		ioutil.WriteFile(filepath.Join(dir, "messages.fr-CH.json"), []byte(`{"summary": "Synthèse"}`), 0666)
+		ioutil.WriteFile(filepath.Join(dir, "messages.es.json"), []byte(`{"to": "a"}`), 0666)
+		ioutil.WriteFile(filepath.Join(dir, "messages.it.json"), []byte(`{"to": `), 0666)
+
+		Convey("The catalogues should override and add to the built-in messages", func() {
+			l, err := loadLocale("fr-CH", dir)
+			So(err, ShouldBeNil)
+			So(l.message("to"), ShouldEqual, "jusqu'au")
+			So(l.message("summary"), ShouldEqual, "Synthèse")
+			So(l.message("page"), ShouldEqual, "Page")
+			So(l.languageTag(), ShouldEqual, "fr-CH")
+		})
+
+		Convey("A catalogue should add a locale without built-in support", func() {
+			l, err := loadLocale("es", dir)
+			So(err, ShouldBeNil)
+			So(l.message("to"), ShouldEqual, "a")
+			So(l.formatNumber(1000, 0), ShouldEqual, "1,000")
+			So(l.languageTag(), ShouldEqual, "es")
+		})
+
+		Convey("Unknown locales and invalid catalogues should be errors", func() {
+			_, err := loadLocale("pt", dir)
+			So(err, ShouldNotBeNil)
+			_, err = loadLocale("it", dir)
+			So(err, ShouldNotBeNil)
+			So(Options{Locale: "pt"}.Validate(), ShouldHaveSameTypeAs, &OptionsError{})
+		})
+	})
+
+	Convey("When generating the TeX file of a French report", t, func() {
+		gClient := &mockGrafanaClient{0, url.Values{}}
+		rep := new(gClient, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Locale: LocaleFrench})
+		defer rep.Clean()
+		rep.failures = []PanelFailure{{grafana.Panel{Id: 7}, "timeout"}}
+		dash, _ := gClient.GetDashboard("")
+		So(rep.generateTeXFile(dash), ShouldBeNil)
+		b, _ := ioutil.ReadFile(rep.texPath())
+		s := string(b)
+
+		Convey("The text of the default template should be translated", func() {
+			So(s, ShouldContainSubstring, `\\au\\`)
+			So(s, ShouldContainSubstring, `{\small Page \thepage\ sur \pageref*{LastPage}}`)
+			So(s, ShouldContainSubstring, `\renewcommand{\contentsname}{Table des matières}`)
+			So(s, ShouldContainSubstring, `\section*{Panneaux dont le rendu a échoué}`)
+			So(s, ShouldContainSubstring, `\item Panneau 7`)
+		})
+
+		Convey("The time range should be formatted for the locale", func() {
+			So(s, ShouldContainSubstring, `janvier 2016`)
+		})
+	})
+}
@@ -0,0 +1,449 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"fmt"
+	"image/color"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/IzakMarais/reporter/grafana"
+	"github.com/IzakMarais/reporter/pdf"
+)
+
+const (
+	inch       = 72.0
+	cm         = inch / 2.54
+	panelSpace = 0.5 * cm
+)
+
+// nativeRenderer lays out the report in Go, mirroring the default TeX templates:
+// a title block or cover page, then singlestat panels side by side and all other panels one per line, or in grid layout
+// the panels at their gridPos, with running headers, "page X of Y" footers and a bookmark per row and panel.
+type nativeRenderer struct{}
+
+func (nativeRenderer) render(rep *report, dash grafana.Dashboard) (io.ReadCloser, error) {
+	settings, err := rep.opts.pageSettings(PaperA4)
+	if err != nil {
+		return nil, err
+	}
+	doc := pdf.New(settings.size(settings.Landscape))
+	plain := plainDashboard(dash)
+	doc.Info = pdf.Info{
+		Title:    plain.Title,
+		Author:   rep.opts.User,
+		Subject:  plain.VariableValues,
+		Keywords: strings.Join(plain.Tags, ", "),
+		Creator:  "grafana-reporter",
+	}
+	l := newNativeLayout(doc, settings)
+	l.locale = rep.locale()
+	l.branding = rep.opts.branding()
+	if l.branding.Logo != "" {
+		if l.logo, err = addImageFile(doc, l.branding.Logo); err != nil {
+			return nil, err
+		}
+	}
+
+	if l.branding.Cover {
+		l.cover(dash, rep.time)
+	} else {
+		l.title(dash, rep.time)
+	}
+	if rep.opts.GridLayout {
+		err = l.gridPages(gridPages(panelGroups(dash), settings, !l.branding.Cover, rep.opts.RowBreak), rep.imgFilePath)
+	} else {
+		err = l.sections(panelGroups(dash), rep.opts.RowBreak, rep.imgFilePath)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rep.failures) > 0 {
+		l.failures(rep.failures)
+	}
+	if l.branding.Disclaimer != "" && !l.branding.Cover {
+		l.disclaimer()
+	}
+	l.headersAndFooters(dash, rep.time)
+
+	file, err := os.Create(rep.pdfPath())
+	if err != nil {
+		return nil, fmt.Errorf("error creating pdf file at %v: %v", rep.pdfPath(), err)
+	}
+	err = doc.Write(file)
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error writing pdf file at %v: %v", rep.pdfPath(), err)
+	}
+	pdfFile, err := os.Open(rep.pdfPath())
+	if err != nil {
+		return nil, err
+	}
+	return pdfFile, nil
+}
+
+func addImageFile(doc *pdf.Document, path string) (*pdf.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening image file: %v", err)
+	}
+	defer f.Close()
+	img, err := doc.AddImage(f)
+	if err != nil {
+		return nil, fmt.Errorf("error adding image %v to pdf: %v", path, err)
+	}
+	return img, nil
+}
+
+// nativeLayout flows images down the pages of a document, similar to LaTeX's center environment
+type nativeLayout struct {
+	doc       *pdf.Document
+	page      *pdf.Page
+	settings  pageSettings
+	locale    locale
+	branding  Branding
+	logo      *pdf.Image
+	landscape bool
+	margin    float64
+	width     float64
+	bottom    float64
+	y         float64
+
+	line      []placedImage
+	lineWidth float64
+}
+
+type placedImage struct {
+	img   *pdf.Image
+	title string
+	w, h  float64
+}
+
+func newNativeLayout(doc *pdf.Document, settings pageSettings) *nativeLayout {
+	l := &nativeLayout{
+		doc:       doc,
+		settings:  settings,
+		landscape: settings.Landscape,
+		margin:    settings.margin,
+	}
+	l.newPage()
+	return l
+}
+
+func (l *nativeLayout) newPage() {
+	w, h := l.settings.size(l.landscape)
+	l.page = l.doc.AddPageSize(w, h)
+	l.width, l.bottom = w-2*l.margin, h-l.margin
+	l.y = l.margin
+}
+
+// turn starts a new page, sideways in a portrait document if landscape is set
+func (l *nativeLayout) turn(landscape bool) {
+	l.flushLine()
+	l.landscape = l.settings.Landscape || landscape
+	l.newPage()
+}
+
+// sections lays out the panels of each dashboard row like the default template:
+// singlestat panels side by side and all other panels one per line
+func (l *nativeLayout) sections(sections []grafana.Row, rowBreak bool, imgFilePath func(grafana.Panel) string) error {
+	for i, row := range sections {
+		if row.Title != "" {
+			l.heading(grafana.PlainText(row.Title), rowBreak && i > 0)
+		}
+		for _, p := range row.Panels {
+			img, err := addImageFile(l.doc, imgFilePath(p))
+			if err != nil {
+				return err
+			}
+			if p.IsSingleStat() {
+				l.inline(img, grafana.PlainText(p.Title), 0.3)
+			} else {
+				l.block(img, grafana.PlainText(p.Title))
+			}
+		}
+		l.flushLine()
+	}
+	return nil
+}
+
+// gridPages lays out the pages of the grid layout, starting each on a new page
+func (l *nativeLayout) gridPages(pages []gridPage, imgFilePath func(grafana.Panel) string) error {
+	for i, page := range pages {
+		if i > 0 || page.Landscape {
+			l.turn(page.Landscape)
+		}
+		for _, band := range page.Bands {
+			if band.Heading != "" {
+				l.heading(grafana.PlainText(band.Heading), false)
+			}
+			if err := l.band(band, imgFilePath); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// title sets the equivalent of the default templates' \maketitle block
+func (l *nativeLayout) title(dash grafana.Dashboard, t grafana.TimeRange) {
+	l.y += 0.5 * inch
+	if l.logo != nil {
+		l.centredLogo(l.width, 2*cm)
+		l.y += 0.5 * cm
+	}
+	l.branded(func() { l.centredText(pdf.HelveticaBold, 17, grafana.PlainText(dash.Title)) })
+	if dash.VariableValues != "" {
+		l.centredText(pdf.Helvetica, 12, grafana.PlainText(dash.VariableValues))
+	}
+	if dash.Description != "" {
+		l.centredText(pdf.Helvetica, 9, grafana.PlainText(dash.Description))
+	}
+	l.y += 0.5 * cm
+	l.centredText(pdf.Helvetica, 12, l.locale.formatTime(t.FromTime()))
+	l.centredText(pdf.Helvetica, 12, l.locale.message("to"))
+	l.centredText(pdf.Helvetica, 12, l.locale.formatTime(t.ToTime()))
+	if l.branding.Company != "" {
+		l.y += 0.5 * cm
+		l.centredText(pdf.Helvetica, 12, l.branding.Company)
+	}
+	l.y += 1 * cm
+}
+
+// cover sets the default templates' cover page: the logo, title and time range, with the company name
+// and disclaimer at the bottom of the page
+func (l *nativeLayout) cover(dash grafana.Dashboard, t grafana.TimeRange) {
+	if l.logo != nil {
+		l.centredLogo(0.4*l.width, 4*cm)
+	}
+	l.y += 3 * cm
+	l.branded(func() { l.centredText(pdf.HelveticaBold, 24, grafana.PlainText(dash.Title)) })
+	if dash.VariableValues != "" {
+		l.y += 0.5 * cm
+		l.centredText(pdf.Helvetica, 14, grafana.PlainText(dash.VariableValues))
+	}
+	if dash.Description != "" {
+		l.y += 0.5 * cm
+		l.centredText(pdf.Helvetica, 9, grafana.PlainText(dash.Description))
+	}
+	l.y += 1 * cm
+	l.centredText(pdf.Helvetica, 12, l.locale.formatTime(t.FromTime())+" -- "+l.locale.formatTime(t.ToTime()))
+
+	disclaimer := pdf.WrapText(l.branding.Disclaimer, 8, l.width)
+	bottom := l.bottom - float64(len(disclaimer))*1.2*8
+	if l.branding.Company != "" {
+		bottom -= 1.2*12 + 0.5*cm
+	}
+	if bottom > l.y {
+		l.y = bottom
+	}
+	if l.branding.Company != "" {
+		l.centredText(pdf.Helvetica, 12, l.branding.Company)
+		l.y += 0.5 * cm
+	}
+	l.centredText(pdf.Helvetica, 8, l.branding.Disclaimer)
+	l.newPage()
+}
+
+// centredLogo draws the logo centred at the top of the remaining space, scaled to fit w x h
+func (l *nativeLayout) centredLogo(w, h float64) {
+	lw, lh := w, w*float64(l.logo.Height)/float64(l.logo.Width)
+	if lh > h {
+		lw, lh = lw*h/lh, h
+	}
+	l.page.DrawImage(l.logo, l.margin+(l.width-lw)/2, l.y, lw, lh)
+	l.y += lh
+}
+
+// branded draws in the brand colour, if there is one
+func (l *nativeLayout) branded(draw func()) {
+	if l.branding.Color == "" {
+		draw()
+		return
+	}
+	l.page.SetColor(l.branding.rgb())
+	draw()
+	l.page.SetColor(color.Black)
+}
+
+// disclaimer prints the disclaimer in small print after the last panel, like the default templates
+func (l *nativeLayout) disclaimer() {
+	l.flushLine()
+	l.y += 1 * cm
+	for _, line := range pdf.WrapText(l.branding.Disclaimer, 8, l.width) {
+		if l.y+1.2*8 > l.bottom {
+			l.newPage()
+		}
+		l.y += 1.2 * 8
+		l.page.Text(l.margin, l.y, pdf.Helvetica, 8, line)
+	}
+}
+
+// heading starts a dashboard row like the default templates' \section*. It starts a new page if newPage is set,
+// or if there is no room left for the heading and the first panels of the row.
+func (l *nativeLayout) heading(title string, newPage bool) {
+	l.flushLine()
+	if (newPage && l.y > l.margin) || l.y+2*inch > l.bottom {
+		l.newPage()
+	}
+	l.y += 0.5 * cm
+	l.doc.AddBookmark(title, l.page, l.y, false)
+	l.branded(func() {
+		for _, line := range pdf.WrapText(title, 14, l.width) {
+			l.y += 1.2 * 14
+			l.page.Text(l.margin, l.y, pdf.HelveticaBold, 14, line)
+		}
+	})
+	l.y += 0.25 * cm
+}
+
+// failures lists the panels that failed to render on a new page, like the default templates' appendix
+func (l *nativeLayout) failures(failures []PanelFailure) {
+	l.turn(false)
+	l.y += 0.5 * inch
+	l.doc.AddBookmark(l.locale.message("failures"), l.page, l.y, false)
+	l.centredText(pdf.HelveticaBold, 14, l.locale.message("failures"))
+	l.y += 0.5 * cm
+	for _, f := range failures {
+		for _, line := range pdf.WrapText(l.locale.failureText(f), 10, l.width) {
+			if l.y+12 > l.bottom {
+				l.newPage()
+			}
+			l.y += 12
+			l.page.Text(l.margin, l.y, pdf.Helvetica, 10, line)
+		}
+		l.y += 6
+	}
+}
+
+func (l *nativeLayout) centredText(font pdf.Font, size float64, s string) {
+	for _, line := range pdf.WrapText(s, size, l.width) {
+		l.y += 1.2 * size
+		l.page.TextCentered(l.y, font, size, line)
+	}
+}
+
+// inline places img at a fraction of the text width next to the previous inline images,
+// starting a new line when it does not fit
+func (l *nativeLayout) inline(img *pdf.Image, title string, fraction float64) {
+	w, h := l.fit(img, fraction*l.width)
+	if l.lineWidth+w > l.width+0.5 {
+		l.flushLine()
+	}
+	l.line = append(l.line, placedImage{img, title, w, h})
+	l.lineWidth += w
+}
+
+// block places img at the full text width on a line of its own
+func (l *nativeLayout) block(img *pdf.Image, title string) {
+	l.flushLine()
+	w, h := l.fit(img, l.width)
+	l.y += panelSpace
+	if l.y+h > l.bottom {
+		l.newPage()
+	}
+	l.bookmark(title)
+	l.page.DrawImage(img, l.margin+(l.width-w)/2, l.y, w, h)
+	l.y += h + panelSpace
+}
+
+// band places the panels of a grid layout band at their gridPos, on a new page if the band does not fit
+func (l *nativeLayout) band(b gridBand, imgFilePath func(grafana.Panel) string) error {
+	l.flushLine()
+	unit := b.Unit * l.width
+	if l.y+b.Height*unit > l.bottom && l.y > l.margin {
+		l.newPage()
+	}
+	left := l.margin + (l.width-gridColumns*unit)/2
+	for _, p := range b.Panels {
+		img, err := addImageFile(l.doc, imgFilePath(p.Panel))
+		if err != nil {
+			return err
+		}
+		if p.Title != "" {
+			l.doc.AddBookmark(grafana.PlainText(p.Title), l.page, l.y+p.Y*unit, true)
+		}
+		l.page.DrawImage(img, left+p.X*unit, l.y+p.Y*unit, p.ImageWidth*l.width, p.ImageHeight*l.width)
+	}
+	l.y += b.Height * unit
+	return nil
+}
+
+func (l *nativeLayout) flushLine() {
+	if len(l.line) == 0 {
+		return
+	}
+	height := 0.0
+	for _, p := range l.line {
+		if p.h > height {
+			height = p.h
+		}
+	}
+	if l.y+height > l.bottom {
+		l.newPage()
+	}
+	x := l.margin + (l.width-l.lineWidth)/2
+	for _, p := range l.line {
+		l.bookmark(p.title)
+		l.page.DrawImage(p.img, x, l.y+(height-p.h)/2, p.w, p.h)
+		x += p.w
+	}
+	l.y += height
+	l.line = nil
+	l.lineWidth = 0
+}
+
+// fit scales img to width w, keeping its aspect ratio, and shrinks it further if it is taller than a page
+func (l *nativeLayout) fit(img *pdf.Image, w float64) (float64, float64) {
+	h := w * float64(img.Height) / float64(img.Width)
+	if maxH := l.bottom - l.margin; h > maxH {
+		w, h = w*maxH/h, maxH
+	}
+	return w, h
+}
+
+// bookmark adds a panel's bookmark at the current position, nested in the bookmark of its row
+func (l *nativeLayout) bookmark(title string) {
+	if title != "" {
+		l.doc.AddBookmark(title, l.page, l.y, true)
+	}
+}
+
+// headersAndFooters adds the default templates' running headers, with the dashboard title and time range,
+// and footers, with the company and "page X of Y". The title page has no header, and the cover page neither,
+// nor is it numbered.
+func (l *nativeLayout) headersAndFooters(dash grafana.Dashboard, t grafana.TimeRange) {
+	pages := l.doc.Pages()
+	if l.branding.Cover {
+		pages = pages[1:]
+	}
+	title := grafana.PlainText(dash.Title)
+	period := l.locale.formatTime(t.FromTime()) + " -- " + l.locale.formatTime(t.ToTime())
+	for i, p := range pages {
+		if i > 0 || l.branding.Cover {
+			p.Text(l.margin, l.margin/2, pdf.Helvetica, 10, title)
+			p.Text(p.Width-l.margin-pdf.TextWidth(period, 10), l.margin/2, pdf.Helvetica, 10, period)
+		}
+		number := fmt.Sprintf("%v %d %v %d", l.locale.message("page"), i+1, l.locale.message("of"), len(pages))
+		p.TextCentered(p.Height-l.margin/2, pdf.Helvetica, 10, number)
+		if l.branding.Company != "" {
+			p.Text(l.margin, p.Height-l.margin/2, pdf.Helvetica, 10, l.branding.Company)
+		}
+	}
+}
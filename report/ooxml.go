@@ -0,0 +1,77 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Helpers shared by the Office Open XML (DOCX and PPTX) renderers
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+const (
+	relTypeOfficeDocument = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument"
+	relTypeCoreProps      = "http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties"
+	relTypeStyles         = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles"
+	relTypeImage          = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/image"
+)
+
+// ooxmlRel is an entry of an Office Open XML relationships part
+type ooxmlRel struct {
+	id, relType, target string
+}
+
+func ooxmlRelationships(rels []ooxmlRel) string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for _, r := range rels {
+		fmt.Fprintf(&b, `<Relationship Id="%s" Type="%s" Target="%s"/>`, r.id, r.relType, r.target)
+	}
+	b.WriteString(`</Relationships>`)
+	return b.String()
+}
+
+const ooxmlCoreProps = xml.Header + `<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/">` +
+	`<dc:title>%s</dc:title><dc:creator>grafana-reporter</dc:creator></cp:coreProperties>`
+
+// ooxmlPart is a named XML part of an Office Open XML package
+type ooxmlPart struct {
+	name, content string
+}
+
+func writeOOXMLParts(zw *zip.Writer, parts []ooxmlPart) error {
+	for _, part := range parts {
+		w, err := zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err = io.WriteString(w, part.content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
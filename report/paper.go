@@ -0,0 +1,148 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// PaperLetter is US letter paper, the default of the LaTeX article class
+	PaperLetter = "letter"
+	// PaperA4 is ISO A4 paper, the default of the native backend
+	PaperA4 = "a4"
+	// PaperA3 is ISO A3 paper, twice the size of A4
+	PaperA3 = "a3"
+)
+
+var papers = []string{PaperA4, PaperLetter, PaperA3}
+
+const (
+	// OrientationPortrait prints every page upright, the default if empty
+	OrientationPortrait = "portrait"
+	// OrientationLandscape prints every page sideways
+	OrientationLandscape = "landscape"
+	// OrientationAuto prints upright, but turns the pages of very wide grid layout panels sideways
+	OrientationAuto = "auto"
+)
+
+var orientations = []string{OrientationPortrait, OrientationLandscape, OrientationAuto}
+
+// paperSizes are the portrait width and height of the papers in bp (PostScript points)
+var paperSizes = map[string][2]float64{
+	PaperLetter: {612, 792},
+	PaperA4:     {595.28, 841.89},
+	PaperA3:     {841.89, 1190.55},
+}
+
+// lengthUnits are the size of the units of a margin in bp, as understood by the LaTeX geometry package
+var lengthUnits = map[string]float64{
+	"bp": 1,
+	"pt": 72 / 72.27,
+	"in": 72,
+	"cm": 72 / 2.54,
+	"mm": 72 / 25.4,
+}
+
+// pageSettings describe the paper of PDF reports to templates, as .Page. Lengths are in bp.
+type pageSettings struct {
+	// Paper is PaperA4, PaperLetter or PaperA3
+	Paper string
+	// Landscape is true if every page is sideways
+	Landscape bool
+	// Auto is true if pages of very wide panels are sideways in grid layout
+	Auto bool
+	// Margin is the margin on all sides of the page as given, e.g. "1in"
+	Margin string
+	margin float64
+}
+
+// pageSettings validates and completes the paper options, with defaultPaper if none is set.
+// The default margin is 1in, or 0.5in in grid layout.
+func (o Options) pageSettings(defaultPaper string) (pageSettings, error) {
+	s := pageSettings{Paper: strings.ToLower(o.Paper), Margin: o.Margin}
+	if s.Paper == "" {
+		s.Paper = defaultPaper
+	}
+	if _, ok := paperSizes[s.Paper]; !ok {
+		return s, fmt.Errorf("unknown paper size %q, expected one of: %s", o.Paper, strings.Join(papers, ", "))
+	}
+	switch strings.ToLower(o.Orientation) {
+	case "", OrientationPortrait:
+	case OrientationLandscape:
+		s.Landscape = true
+	case OrientationAuto:
+		s.Auto = true
+	default:
+		return s, fmt.Errorf("unknown page orientation %q, expected one of: %s", o.Orientation, strings.Join(orientations, ", "))
+	}
+	if s.Margin == "" {
+		s.Margin = "1in"
+		if o.GridLayout {
+			s.Margin = "0.5in"
+		}
+	}
+	margin, err := parseLength(s.Margin)
+	if err != nil {
+		return s, err
+	}
+	size := paperSizes[s.Paper]
+	if margin <= 0 || 2*margin >= size[0]*0.8 {
+		return s, fmt.Errorf("invalid margin %q: it must leave room for the panels on %s paper", o.Margin, s.Paper)
+	}
+	s.margin = margin
+	return s, nil
+}
+
+// parseLength converts a length like 2.5cm to bp
+func parseLength(s string) (float64, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) > 2 {
+		if unit, ok := lengthUnits[s[len(s)-2:]]; ok {
+			if f, err := strconv.ParseFloat(s[:len(s)-2], 64); err == nil {
+				return f * unit, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("invalid length %q, expected a number followed by one of mm, cm, in, pt or bp", s)
+}
+
+// Geometry returns the options of the LaTeX geometry package for the paper, e.g. a4paper,landscape,margin=1in
+func (s pageSettings) Geometry() string {
+	g := s.Paper + "paper,"
+	if s.Landscape {
+		g += "landscape,"
+	}
+	return g + "margin=" + s.Margin
+}
+
+// size returns the width and height of a page, sideways if landscape is set
+func (s pageSettings) size(landscape bool) (float64, float64) {
+	size := paperSizes[s.Paper]
+	if landscape {
+		return size[1], size[0]
+	}
+	return size[0], size[1]
+}
+
+// textSize returns the width and height of the text block inside the margins of a page
+func (s pageSettings) textSize(landscape bool) (float64, float64) {
+	w, h := s.size(landscape)
+	return w - 2*s.margin, h - 2*s.margin
+}
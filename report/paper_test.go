@@ -0,0 +1,90 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/IzakMarais/reporter/grafana"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPageSettings(t *testing.T) {
+	Convey("When no paper options are set", t, func() {
+		s, err := Options{}.pageSettings(PaperLetter)
+
+		Convey("It should use the default paper in portrait with 1in margins", func() {
+			So(err, ShouldBeNil)
+			So(s.Geometry(), ShouldEqual, "letterpaper,margin=1in")
+			w, h := s.textSize(false)
+			So(w, ShouldEqual, 468)
+			So(h, ShouldEqual, 648)
+		})
+
+		Convey("The grid layout should have 0.5in margins", func() {
+			s, _ := Options{GridLayout: true}.pageSettings(PaperA4)
+			So(s.Geometry(), ShouldEqual, "a4paper,margin=0.5in")
+		})
+	})
+
+	Convey("When setting the paper, orientation and margin", t, func() {
+		s, err := Options{Paper: "A3", Orientation: OrientationLandscape, Margin: "2cm"}.pageSettings(PaperLetter)
+
+		Convey("It should be used for the page size and text block", func() {
+			So(err, ShouldBeNil)
+			So(s.Geometry(), ShouldEqual, "a3paper,landscape,margin=2cm")
+			w, h := s.size(true)
+			So(w, ShouldEqual, 1190.55)
+			So(h, ShouldEqual, 841.89)
+			tw, _ := s.textSize(true)
+			So(tw, ShouldAlmostEqual, 1190.55-4*72/2.54)
+		})
+	})
+
+	Convey("When the paper options are invalid", t, func() {
+		for _, o := range []Options{{Paper: "b5"}, {Orientation: "sideways"}, {Margin: "2"}, {Margin: "-1cm"}, {Margin: "5in"}} {
+			_, err := o.pageSettings(PaperLetter)
+			So(err, ShouldNotBeNil)
+			So(o.Validate(), ShouldHaveSameTypeAs, &OptionsError{})
+		}
+	})
+
+	Convey("When generating a report with an invalid paper size", t, func() {
+		gClient := &mockGrafanaClient{0, url.Values{}}
+		rep := new(gClient, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Paper: "b5"})
+		defer rep.Clean()
+		_, err := rep.Generate()
+
+		Convey("It should fail with an OptionsError before rendering any panel", func() {
+			So(err, ShouldHaveSameTypeAs, &OptionsError{})
+			So(gClient.getPanelCallCount, ShouldEqual, 0)
+			So(Options{Paper: "b5"}.Validate(), ShouldHaveSameTypeAs, &OptionsError{})
+			So(Options{}.Validate(), ShouldBeNil)
+		})
+	})
+}
+
+func TestParseLength(t *testing.T) {
+	Convey("Lengths should be converted to bp", t, func() {
+		for s, bp := range map[string]float64{"1in": 72, "2.54cm": 72, "25.4mm": 72, "72.27pt": 72, "10bp": 10, " 1IN ": 72} {
+			l, err := parseLength(s)
+			So(err, ShouldBeNil)
+			So(l, ShouldAlmostEqual, bp)
+		}
+	})
+}
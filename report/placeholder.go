@@ -0,0 +1,208 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"strings"
+
+	"github.com/IzakMarais/reporter/grafana"
+)
+
+// placeholderSize returns the pixel size Grafana renders a panel at
+func placeholderSize(p grafana.Panel, gridLayout bool) (int, int) {
+	switch {
+	case gridLayout && p.GridPos.W > 0 && p.GridPos.H > 0:
+		return int(p.GridPos.W * 40), int(p.GridPos.H * 40)
+	case p.Is(grafana.SingleStat):
+		return 300, 150
+	case p.Is(grafana.Text):
+		return 1000, 100
+	}
+	return 1000, 500
+}
+
+// placeholderImage draws a light grey box with a border and diagonals
+func placeholderImage(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	fill := color.RGBA{0xf0, 0xf0, 0xf0, 0xff}
+	line := color.RGBA{0x99, 0x99, 0x99, 0xff}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, fill)
+		}
+		img.Set(0, y, line)
+		img.Set(w-1, y, line)
+	}
+	for x := 0; x < w; x++ {
+		img.Set(x, 0, line)
+		img.Set(x, h-1, line)
+		y := x * (h - 1) / (w - 1)
+		img.Set(x, y, line)
+		img.Set(x, h-1-y, line)
+	}
+	return img
+}
+
+// writeFailedPanelImage writes a placeholder image with the render error to path, in place of the panel's image
+func (rep *report) writeFailedPanelImage(p grafana.Panel, path string, renderErr error) error {
+	if err := os.MkdirAll(rep.imgDirPath(), 0777); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w, h := placeholderSize(p, rep.opts.GridLayout)
+	text := "Panel " + grafana.PlainText(p.Title) + " failed to render:\n" + renderErr.Error()
+	return png.Encode(file, errorImage(w, h, text))
+}
+
+// failureText describes a failed panel in plain text, for the appendix of reports that are not generated from templates
+func (l locale) failureText(f PanelFailure) string {
+	text := fmt.Sprintf("%s %d", l.message("panel"), f.Panel.Id)
+	if f.Panel.Title != "" {
+		text += " " + grafana.PlainText(f.Panel.Title)
+	}
+	return text + ": " + f.Error
+}
+
+// errorImage draws a light red box with a border and text, wrapped to fit and cut off at the bottom
+func errorImage(w, h int, text string) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	fill := color.RGBA{0xfd, 0xec, 0xec, 0xff}
+	line := color.RGBA{0xc0, 0x39, 0x2b, 0xff}
+	ink := color.RGBA{0x33, 0x33, 0x33, 0xff}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if x < 2 || y < 2 || x >= w-2 || y >= h-2 {
+				img.Set(x, y, line)
+			} else {
+				img.Set(x, y, fill)
+			}
+		}
+	}
+
+	scale := 1
+	if w >= 600 {
+		scale = 2
+	}
+	pad := 6 * scale
+	charW, lineH := glyphAdvance*scale, glyphLineHeight*scale
+	y := pad
+	for _, l := range wrapChars(text, (w-2*pad)/charW) {
+		if y+glyphHeight*scale > h-pad {
+			break
+		}
+		drawText(img, pad, y, scale, l, ink)
+		y += lineH
+	}
+	return img
+}
+
+// wrapChars breaks text into lines of at most n characters, at spaces where possible
+func wrapChars(text string, n int) []string {
+	if n < 1 {
+		return nil
+	}
+	lines := []string{}
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := []rune{}
+		for _, word := range strings.Fields(paragraph) {
+			for r := []rune(word); len(r) > 0; {
+				if len(line) > 0 && len(line)+1+len(r) > n {
+					lines = append(lines, string(line))
+					line = nil
+				}
+				if len(line) > 0 {
+					line = append(line, ' ')
+				}
+				take := len(r)
+				if take > n-len(line) {
+					take = n - len(line)
+				}
+				line = append(line, r[:take]...)
+				r = r[take:]
+			}
+		}
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+const (
+	glyphHeight     = 7
+	glyphAdvance    = 6
+	glyphLineHeight = 10
+)
+
+// drawText draws s with its top left corner at x, y, using glyphs5x7 magnified by scale.
+// Characters outside of printable ASCII are drawn as '?'.
+func drawText(img *image.RGBA, x, y, scale int, s string, c color.Color) {
+	for _, r := range s {
+		if r < ' ' || r > '~' {
+			r = '?'
+		}
+		for col, bits := range glyphs5x7[r-' '] {
+			for row := 0; row < glyphHeight; row++ {
+				if bits&(1<<uint(row)) == 0 {
+					continue
+				}
+				for dy := 0; dy < scale; dy++ {
+					for dx := 0; dx < scale; dx++ {
+						img.Set(x+col*scale+dx, y+row*scale+dy, c)
+					}
+				}
+			}
+		}
+		x += glyphAdvance * scale
+	}
+}
+
+// glyphs5x7 is a 5x7 pixel bitmap font of the printable ASCII characters ' ' to '~'.
+// Each glyph is 5 columns from left to right, with the top row in the least significant bit.
+var glyphs5x7 = [...][5]byte{
+	{0x00, 0x00, 0x00, 0x00, 0x00}, {0x00, 0x00, 0x5f, 0x00, 0x00}, {0x00, 0x07, 0x00, 0x07, 0x00}, {0x14, 0x7f, 0x14, 0x7f, 0x14},
+	{0x24, 0x2a, 0x7f, 0x2a, 0x12}, {0x23, 0x13, 0x08, 0x64, 0x62}, {0x36, 0x49, 0x55, 0x22, 0x50}, {0x00, 0x05, 0x03, 0x00, 0x00},
+	{0x00, 0x1c, 0x22, 0x41, 0x00}, {0x00, 0x41, 0x22, 0x1c, 0x00}, {0x08, 0x2a, 0x1c, 0x2a, 0x08}, {0x08, 0x08, 0x3e, 0x08, 0x08},
+	{0x00, 0x50, 0x30, 0x00, 0x00}, {0x08, 0x08, 0x08, 0x08, 0x08}, {0x00, 0x60, 0x60, 0x00, 0x00}, {0x20, 0x10, 0x08, 0x04, 0x02},
+	{0x3e, 0x51, 0x49, 0x45, 0x3e}, {0x00, 0x42, 0x7f, 0x40, 0x00}, {0x42, 0x61, 0x51, 0x49, 0x46}, {0x21, 0x41, 0x45, 0x4b, 0x31},
+	{0x18, 0x14, 0x12, 0x7f, 0x10}, {0x27, 0x45, 0x45, 0x45, 0x39}, {0x3c, 0x4a, 0x49, 0x49, 0x30}, {0x01, 0x71, 0x09, 0x05, 0x03},
+	{0x36, 0x49, 0x49, 0x49, 0x36}, {0x06, 0x49, 0x49, 0x29, 0x1e}, {0x00, 0x36, 0x36, 0x00, 0x00}, {0x00, 0x56, 0x36, 0x00, 0x00},
+	{0x08, 0x14, 0x22, 0x41, 0x00}, {0x14, 0x14, 0x14, 0x14, 0x14}, {0x00, 0x41, 0x22, 0x14, 0x08}, {0x02, 0x01, 0x51, 0x09, 0x06},
+	{0x32, 0x49, 0x79, 0x41, 0x3e}, {0x7e, 0x11, 0x11, 0x11, 0x7e}, {0x7f, 0x49, 0x49, 0x49, 0x36}, {0x3e, 0x41, 0x41, 0x41, 0x22},
+	{0x7f, 0x41, 0x41, 0x22, 0x1c}, {0x7f, 0x49, 0x49, 0x49, 0x41}, {0x7f, 0x09, 0x09, 0x09, 0x01}, {0x3e, 0x41, 0x49, 0x49, 0x7a},
+	{0x7f, 0x08, 0x08, 0x08, 0x7f}, {0x00, 0x41, 0x7f, 0x41, 0x00}, {0x20, 0x40, 0x41, 0x3f, 0x01}, {0x7f, 0x08, 0x14, 0x22, 0x41},
+	{0x7f, 0x40, 0x40, 0x40, 0x40}, {0x7f, 0x02, 0x0c, 0x02, 0x7f}, {0x7f, 0x04, 0x08, 0x10, 0x7f}, {0x3e, 0x41, 0x41, 0x41, 0x3e},
+	{0x7f, 0x09, 0x09, 0x09, 0x06}, {0x3e, 0x41, 0x51, 0x21, 0x5e}, {0x7f, 0x09, 0x19, 0x29, 0x46}, {0x46, 0x49, 0x49, 0x49, 0x31},
+	{0x01, 0x01, 0x7f, 0x01, 0x01}, {0x3f, 0x40, 0x40, 0x40, 0x3f}, {0x1f, 0x20, 0x40, 0x20, 0x1f}, {0x3f, 0x40, 0x38, 0x40, 0x3f},
+	{0x63, 0x14, 0x08, 0x14, 0x63}, {0x07, 0x08, 0x70, 0x08, 0x07}, {0x61, 0x51, 0x49, 0x45, 0x43}, {0x00, 0x7f, 0x41, 0x41, 0x00},
+	{0x02, 0x04, 0x08, 0x10, 0x20}, {0x00, 0x41, 0x41, 0x7f, 0x00}, {0x04, 0x02, 0x01, 0x02, 0x04}, {0x40, 0x40, 0x40, 0x40, 0x40},
+	{0x00, 0x01, 0x02, 0x04, 0x00}, {0x20, 0x54, 0x54, 0x54, 0x78}, {0x7f, 0x48, 0x44, 0x44, 0x38}, {0x38, 0x44, 0x44, 0x44, 0x20},
+	{0x38, 0x44, 0x44, 0x48, 0x7f}, {0x38, 0x54, 0x54, 0x54, 0x18}, {0x08, 0x7e, 0x09, 0x01, 0x02}, {0x0c, 0x52, 0x52, 0x52, 0x3e},
+	{0x7f, 0x08, 0x04, 0x04, 0x78}, {0x00, 0x44, 0x7d, 0x40, 0x00}, {0x20, 0x40, 0x44, 0x3d, 0x00}, {0x7f, 0x10, 0x28, 0x44, 0x00},
+	{0x00, 0x41, 0x7f, 0x40, 0x00}, {0x7c, 0x04, 0x18, 0x04, 0x78}, {0x7c, 0x08, 0x04, 0x04, 0x78}, {0x38, 0x44, 0x44, 0x44, 0x38},
+	{0x7c, 0x14, 0x14, 0x14, 0x08}, {0x08, 0x14, 0x14, 0x18, 0x7c}, {0x7c, 0x08, 0x04, 0x04, 0x08}, {0x48, 0x54, 0x54, 0x54, 0x20},
+	{0x04, 0x3f, 0x44, 0x40, 0x20}, {0x3c, 0x40, 0x40, 0x20, 0x7c}, {0x1c, 0x20, 0x40, 0x20, 0x1c}, {0x3c, 0x40, 0x30, 0x40, 0x3c},
+	{0x44, 0x28, 0x10, 0x28, 0x44}, {0x0c, 0x50, 0x50, 0x50, 0x3c}, {0x44, 0x64, 0x54, 0x4c, 0x44}, {0x00, 0x08, 0x36, 0x41, 0x00},
+	{0x00, 0x00, 0x7f, 0x00, 0x00}, {0x00, 0x41, 0x36, 0x08, 0x00}, {0x08, 0x04, 0x08, 0x10, 0x08},
+}
@@ -0,0 +1,55 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"image/color"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestErrorImage(t *testing.T) {
+	Convey("When wrapping text for an error image", t, func() {
+		Convey("It should break lines at spaces", func() {
+			So(wrapChars("error getting panel 3", 10), ShouldResemble, []string{"error", "getting", "panel 3"})
+		})
+
+		Convey("It should break words longer than a line", func() {
+			So(wrapChars("http://grafana/render", 10), ShouldResemble, []string{"http://gra", "fana/rende", "r"})
+		})
+
+		Convey("It should keep line breaks", func() {
+			So(wrapChars("Panel 3 failed:\ntimeout", 40), ShouldResemble, []string{"Panel 3 failed:", "timeout"})
+		})
+	})
+
+	Convey("When drawing an error image", t, func() {
+		img := errorImage(300, 150, "Panel 3 failed to render")
+
+		Convey("It should have the requested size", func() {
+			So(img.Bounds().Dx(), ShouldEqual, 300)
+			So(img.Bounds().Dy(), ShouldEqual, 150)
+		})
+
+		Convey("It should draw the text", func() {
+			//the top of the 'P' starts at the padding
+			So(img.At(6, 6), ShouldResemble, color.RGBA{0x33, 0x33, 0x33, 0xff})
+			So(img.At(150, 140), ShouldResemble, color.RGBA{0xfd, 0xec, 0xec, 0xff})
+		})
+	})
+}
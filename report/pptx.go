@@ -0,0 +1,626 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/IzakMarais/reporter/grafana"
+)
+
+const (
+	// 16:9 slides, in EMUs, unless the template sets a different size
+	pptxDefaultWidth  int64 = 12192000
+	pptxDefaultHeight int64 = 6858000
+	pptxMargin        int64 = emuPerInch / 2
+	pptxHeadingHeight int64 = emuPerInch
+)
+
+const relNamespace = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+
+const (
+	relTypeSlide       = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/slide"
+	relTypeSlideMaster = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/slideMaster"
+	relTypeSlideLayout = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/slideLayout"
+	relTypeTheme       = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/theme"
+)
+
+// pptxRenderer writes an Office Open XML presentation: a title slide, then one slide per panel,
+// or per row with the row's panels arranged as on the dashboard grid.
+// A custom template is a .pptx file whose slide size, slide masters, layouts and theme are used for the generated deck.
+type pptxRenderer struct{}
+
+type pptxSlide struct {
+	heading  string
+	pictures []pptxPicture
+	texts    []pptxText
+}
+
+type pptxPicture struct {
+	panel      grafana.Panel
+	x, y, w, h int64
+}
+
+type pptxText struct {
+	text       string
+	size       int
+	bold       bool
+	x, y, w, h int64
+}
+
+func (pptxRenderer) render(rep *report, dash grafana.Dashboard) (io.ReadCloser, error) {
+	deck, err := newPptxDeck(rep.opts.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(rep.pptxPath())
+	if err != nil {
+		return nil, fmt.Errorf("error creating pptx file at %v: %v", rep.pptxPath(), err)
+	}
+	zw := zip.NewWriter(file)
+	err = rep.writePptx(zw, deck, plainDashboard(dash))
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error writing pptx file at %v: %v", rep.pptxPath(), err)
+	}
+
+	pptx, err := os.Open(rep.pptxPath())
+	if err != nil {
+		return nil, err
+	}
+	return pptx, nil
+}
+
+// pptxDeck holds the presentation wide settings, taken from the template if there is one
+type pptxDeck struct {
+	width, height int64
+	// parts are the slide masters, layouts and themes, and the parts they refer to
+	parts []ooxmlPart
+	// contentTypes are the [Content_Types].xml entries of the parts
+	contentTypes string
+	masters      []pptxMaster
+	// theme and layout are part names relative to ppt/: the presentation's theme, and the layout of every slide
+	theme, layout string
+}
+
+// pptxMaster is an entry of the presentation's slide master list
+type pptxMaster struct {
+	id, target string
+}
+
+// pptxMasterID is an entry of a template presentation's slide master list. Its id and r:id attributes
+// have the same local name, which encoding/xml cannot tell apart in struct tags.
+type pptxMasterID struct {
+	Attrs []xml.Attr `xml:",any,attr"`
+}
+
+func (m pptxMasterID) attr(space string) string {
+	for _, a := range m.Attrs {
+		if a.Name.Space == space && a.Name.Local == "id" {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// pptxTemplateMedia is where the media of a template's slide masters, layouts and themes are placed,
+// so that they do not collide with the panel images
+const pptxTemplateMedia = "ppt/media/template/"
+
+// builtinPptxDeck returns a deck with the built-in blank slide master and layout, and the given theme
+func builtinPptxDeck(theme string) pptxDeck {
+	return pptxDeck{
+		width:  pptxDefaultWidth,
+		height: pptxDefaultHeight,
+		parts: []ooxmlPart{
+			{"ppt/slideMasters/slideMaster1.xml", pptxSlideMaster},
+			{"ppt/slideMasters/_rels/slideMaster1.xml.rels", ooxmlRelationships([]ooxmlRel{
+				{"rId1", relTypeSlideLayout, "../slideLayouts/slideLayout1.xml"},
+				{"rId2", relTypeTheme, "../theme/theme1.xml"},
+			})},
+			{"ppt/slideLayouts/slideLayout1.xml", pptxSlideLayout},
+			{"ppt/slideLayouts/_rels/slideLayout1.xml.rels", ooxmlRelationships([]ooxmlRel{
+				{"rId1", relTypeSlideMaster, "../slideMasters/slideMaster1.xml"},
+			})},
+			{"ppt/theme/theme1.xml", theme},
+		},
+		contentTypes: pptxBuiltinContentTypes,
+		masters:      []pptxMaster{{"2147483648", "slideMasters/slideMaster1.xml"}},
+		theme:        "theme/theme1.xml",
+		layout:       "slideLayouts/slideLayout1.xml",
+	}
+}
+
+// newPptxDeck reads the slide size, slide masters, layouts and themes of a template.
+// The slides use the template's blank layout, or else the first layout of its first slide master.
+// A template without slide masters only provides its slide size and theme, for the built-in master.
+func newPptxDeck(template string) (pptxDeck, error) {
+	deck := builtinPptxDeck(pptxTheme)
+	if template == "" {
+		return deck, nil
+	}
+
+	zr, err := zip.NewReader(strings.NewReader(template), int64(len(template)))
+	if err != nil {
+		return deck, fmt.Errorf("error reading pptx template: %v", err)
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	var pres struct {
+		SldSz struct {
+			Cx int64 `xml:"cx,attr"`
+			Cy int64 `xml:"cy,attr"`
+		} `xml:"sldSz"`
+		Masters []pptxMasterID `xml:"sldMasterIdLst>sldMasterId"`
+	}
+	if err = unmarshalZipFile(files, "ppt/presentation.xml", &pres); err != nil {
+		return deck, err
+	}
+	if len(pres.Masters) == 0 {
+		if f, ok := files["ppt/theme/theme1.xml"]; ok {
+			b, err := readZipFile(f)
+			if err != nil {
+				return deck, err
+			}
+			deck = builtinPptxDeck(string(b))
+		}
+	} else if deck, err = templatePptxDeck(zr, files, pres.Masters); err != nil {
+		return deck, err
+	}
+	if pres.SldSz.Cx > 0 && pres.SldSz.Cy > 0 {
+		deck.width, deck.height = pres.SldSz.Cx, pres.SldSz.Cy
+	}
+	return deck, nil
+}
+
+// templatePptxDeck copies the parts of a template, except its presentation and slides, and uses its slide masters
+func templatePptxDeck(zr *zip.Reader, files map[string]*zip.File, masters []pptxMasterID) (pptxDeck, error) {
+	deck := pptxDeck{width: pptxDefaultWidth, height: pptxDefaultHeight}
+	presRels, err := readZipRels(files, "ppt/_rels/presentation.xml.rels")
+	if err != nil {
+		return deck, err
+	}
+	for _, r := range presRels {
+		if r.relType == relTypeTheme && deck.theme == "" {
+			deck.theme = r.target
+		}
+	}
+	for _, m := range masters {
+		for _, r := range presRels {
+			if r.id == m.attr(relNamespace) {
+				deck.masters = append(deck.masters, pptxMaster{m.attr(""), r.target})
+			}
+		}
+	}
+	if len(deck.masters) == 0 {
+		return deck, fmt.Errorf("error reading pptx template: the slide masters are missing")
+	}
+
+	master := deck.masters[0].target
+	masterRels, err := readZipRels(files, "ppt/"+path.Dir(master)+"/_rels/"+path.Base(master)+".rels")
+	if err != nil {
+		return deck, err
+	}
+	for _, r := range masterRels {
+		if r.relType != relTypeSlideLayout {
+			continue
+		}
+		layout := path.Join(path.Dir(master), r.target)
+		var l struct {
+			Type string `xml:"type,attr"`
+		}
+		if err = unmarshalZipFile(files, "ppt/"+layout, &l); err != nil {
+			return deck, err
+		}
+		if deck.layout == "" || l.Type == "blank" {
+			deck.layout = layout
+		}
+		if l.Type == "blank" {
+			break
+		}
+	}
+	if deck.layout == "" {
+		return deck, fmt.Errorf("error reading pptx template: the slide master %v has no layouts", master)
+	}
+
+	var types struct {
+		Defaults []struct {
+			Extension   string `xml:",attr"`
+			ContentType string `xml:",attr"`
+		} `xml:"Default"`
+		Overrides []struct {
+			PartName    string `xml:",attr"`
+			ContentType string `xml:",attr"`
+		} `xml:"Override"`
+	}
+	if err = unmarshalZipFile(files, "[Content_Types].xml", &types); err != nil {
+		return deck, err
+	}
+	var contentTypes strings.Builder
+	for _, d := range types.Defaults {
+		switch strings.ToLower(d.Extension) {
+		case "rels", "xml", "png":
+		default:
+			fmt.Fprintf(&contentTypes, `<Default Extension="%s" ContentType="%s"/>`, xmlEscape(d.Extension), xmlEscape(d.ContentType))
+		}
+	}
+	copied := map[string]bool{}
+	for _, f := range zr.File {
+		name := f.Name
+		if !strings.HasPrefix(name, "ppt/") || strings.HasSuffix(name, "/") || !pptxTemplatePart(name) {
+			continue
+		}
+		b, err := readZipFile(f)
+		if err != nil {
+			return deck, err
+		}
+		content := string(b)
+		if strings.HasSuffix(name, ".rels") {
+			content = strings.Replace(content, `Target="../media/`, `Target="../`+strings.TrimPrefix(pptxTemplateMedia, "ppt/"), -1)
+		}
+		name = pptxTemplatePartName(name)
+		copied[name] = true
+		deck.parts = append(deck.parts, ooxmlPart{name, content})
+	}
+	for _, o := range types.Overrides {
+		if name := pptxTemplatePartName(strings.TrimPrefix(o.PartName, "/")); copied[name] {
+			fmt.Fprintf(&contentTypes, `<Override PartName="/%s" ContentType="%s"/>`, xmlEscape(name), xmlEscape(o.ContentType))
+		}
+	}
+	deck.contentTypes = contentTypes.String()
+	return deck, nil
+}
+
+// pptxTemplatePart reports whether a part of a template is copied to the generated deck.
+// The presentation and slides are generated instead.
+func pptxTemplatePart(name string) bool {
+	switch {
+	case name == "ppt/presentation.xml", name == "ppt/_rels/presentation.xml.rels":
+		return false
+	case strings.HasPrefix(name, "ppt/slides/"), strings.HasPrefix(name, "ppt/notesSlides/"):
+		return false
+	}
+	return true
+}
+
+// pptxTemplatePartName returns the name of a template part in the generated deck
+func pptxTemplatePartName(name string) string {
+	if strings.HasPrefix(name, "ppt/media/") {
+		return pptxTemplateMedia + strings.TrimPrefix(name, "ppt/media/")
+	}
+	return name
+}
+
+func unmarshalZipFile(files map[string]*zip.File, name string, v interface{}) error {
+	f, ok := files[name]
+	if !ok {
+		return nil
+	}
+	b, err := readZipFile(f)
+	if err != nil {
+		return err
+	}
+	if err = xml.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("error parsing pptx template %v: %v", name, err)
+	}
+	return nil
+}
+
+// readZipRels returns the relationships of a relationships part, if present
+func readZipRels(files map[string]*zip.File, name string) ([]ooxmlRel, error) {
+	var rels struct {
+		Rels []struct {
+			ID     string `xml:"Id,attr"`
+			Type   string `xml:"Type,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := unmarshalZipFile(files, name, &rels); err != nil {
+		return nil, err
+	}
+	result := []ooxmlRel{}
+	for _, r := range rels.Rels {
+		result = append(result, ooxmlRel{r.ID, r.Type, r.Target})
+	}
+	return result, nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	r, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("error opening %v: %v", f.Name, err)
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func (rep *report) pptxSlides(deck pptxDeck, dash grafana.Dashboard) []pptxSlide {
+	contentW := deck.width - 2*pptxMargin
+	contentY := pptxMargin + pptxHeadingHeight
+	contentH := deck.height - contentY - pptxMargin
+
+	title := pptxSlide{}
+	y := deck.height / 3
+	add := func(text string, size int, bold bool, h int64) {
+		title.texts = append(title.texts, pptxText{text, size, bold, pptxMargin, y, contentW, h})
+		y += h
+	}
+	add(dash.Title, 4000, true, emuPerInch)
+	if dash.VariableValues != "" {
+		add(dash.VariableValues, 2400, false, emuPerInch/2)
+	}
+	if dash.Description != "" {
+		add(dash.Description, 1600, false, emuPerInch/2)
+	}
+	loc := rep.locale()
+	from, to := loc.formatTime(rep.time.FromTime()), loc.formatTime(rep.time.ToTime())
+	add(from+" "+loc.message("to")+" "+to, 2000, false, emuPerInch/2)
+	slides := []pptxSlide{title}
+
+	if rep.opts.Slides == SlidesPerRow {
+		for _, row := range panelGroups(dash) {
+			if len(row.Panels) == 0 {
+				continue
+			}
+			slides = append(slides, pptxSlide{
+				heading:  row.Title,
+				pictures: rep.arrangeGrid(row.Panels, pptxMargin, contentY, contentW, contentH),
+			})
+		}
+		return append(slides, rep.failureSlides(deck)...)
+	}
+
+	for _, p := range dash.Panels {
+		w, h := fitAspect(contentW, contentH, rep.panelAspect(p))
+		slides = append(slides, pptxSlide{
+			heading:  p.Title,
+			pictures: []pptxPicture{{p, pptxMargin + (contentW-w)/2, contentY + (contentH-h)/2, w, h}},
+		})
+	}
+	return append(slides, rep.failureSlides(deck)...)
+}
+
+// failureSlides list the panels that failed to render, like the default templates' appendix
+func (rep *report) failureSlides(deck pptxDeck) []pptxSlide {
+	contentW := deck.width - 2*pptxMargin
+	contentY := pptxMargin + pptxHeadingHeight
+	lineH := int64(emuPerInch / 2)
+	perSlide := int((deck.height - contentY - pptxMargin) / lineH)
+	if perSlide < 1 {
+		perSlide = 1
+	}
+	loc := rep.locale()
+	slides := []pptxSlide{}
+	for i, f := range rep.failures {
+		if i%perSlide == 0 {
+			slides = append(slides, pptxSlide{heading: loc.message("failures")})
+		}
+		s := &slides[len(slides)-1]
+		y := contentY + int64(i%perSlide)*lineH
+		s.texts = append(s.texts, pptxText{loc.failureText(f), 1400, false, pptxMargin, y, contentW, lineH})
+	}
+	return slides
+}
+
+// arrangeGrid places panels in the given area as on the dashboard's 24 column grid, keeping the aspect ratio of their images.
+// Panels without a gridPos are arranged in a grid of equally sized cells instead.
+func (rep *report) arrangeGrid(panels []grafana.Panel, x, y, w, h int64) []pptxPicture {
+	pics := []pptxPicture{}
+	minY, maxY := math.Inf(1), math.Inf(-1)
+	for _, p := range panels {
+		if p.GridPos.W == 0 || p.GridPos.H == 0 {
+			return rep.arrangeCells(panels, x, y, w, h)
+		}
+		minY = math.Min(minY, p.GridPos.Y)
+		maxY = math.Max(maxY, p.GridPos.Y+p.GridPos.H)
+	}
+	unit := math.Min(float64(w)/24, float64(h)/(maxY-minY))
+	left := x + (w-int64(24*unit))/2
+	for _, p := range panels {
+		cx, cy := left+int64(p.GridPos.X*unit), y+int64((p.GridPos.Y-minY)*unit)
+		cellW, cellH := int64(p.GridPos.W*unit), int64(p.GridPos.H*unit)
+		//without grid layout, images are not rendered at their gridPos size, so they are fitted into it
+		pw, ph := fitAspect(cellW, cellH, rep.panelAspect(p))
+		pics = append(pics, pptxPicture{p, cx + (cellW-pw)/2, cy + (cellH-ph)/2, pw, ph})
+	}
+	return pics
+}
+
+func (rep *report) arrangeCells(panels []grafana.Panel, x, y, w, h int64) []pptxPicture {
+	cols := int64(math.Ceil(math.Sqrt(float64(len(panels)))))
+	rows := (int64(len(panels)) + cols - 1) / cols
+	cellW, cellH := w/cols, h/rows
+	pics := []pptxPicture{}
+	for i, p := range panels {
+		pw, ph := fitAspect(cellW, cellH, rep.panelAspect(p))
+		cx, cy := x+int64(i)%cols*cellW, y+int64(i)/cols*cellH
+		pics = append(pics, pptxPicture{p, cx + (cellW-pw)/2, cy + (cellH-ph)/2, pw, ph})
+	}
+	return pics
+}
+
+// fitAspect returns the largest size with the given height to width ratio that fits in w x h
+func fitAspect(w, h int64, aspect float64) (int64, int64) {
+	if float64(w)*aspect > float64(h) {
+		return int64(float64(h) / aspect), h
+	}
+	return w, int64(float64(w) * aspect)
+}
+
+func (rep *report) writePptx(zw *zip.Writer, deck pptxDeck, dash grafana.Dashboard) error {
+	slides := rep.pptxSlides(deck, dash)
+
+	presRels := []ooxmlRel{}
+	var masterIDs, slideIDs, slideTypes bytes.Buffer
+	for i, m := range deck.masters {
+		relID := fmt.Sprintf("rIdMaster%d", i+1)
+		presRels = append(presRels, ooxmlRel{relID, relTypeSlideMaster, m.target})
+		fmt.Fprintf(&masterIDs, `<p:sldMasterId id="%s" r:id="%s"/>`, m.id, relID)
+	}
+	if deck.theme != "" {
+		presRels = append(presRels, ooxmlRel{"rIdTheme1", relTypeTheme, deck.theme})
+	}
+	slideParts := []ooxmlPart{}
+	media := []grafana.Panel{}
+	for i, s := range slides {
+		n := i + 1
+		relID := fmt.Sprintf("rIdSlide%d", n)
+		presRels = append(presRels, ooxmlRel{relID, relTypeSlide, fmt.Sprintf("slides/slide%d.xml", n)})
+		fmt.Fprintf(&slideIDs, `<p:sldId id="%d" r:id="%s"/>`, 255+n, relID)
+		fmt.Fprintf(&slideTypes, `<Override PartName="/ppt/slides/slide%d.xml" ContentType="application/vnd.openxmlformats-officedocument.presentationml.slide+xml"/>`, n)
+
+		slideRels := []ooxmlRel{{"rIdLayout", relTypeSlideLayout, "../" + deck.layout}}
+		for _, pic := range s.pictures {
+			slideRels = append(slideRels, ooxmlRel{fmt.Sprintf("rIdImage%d", pic.panel.Id), relTypeImage, "../media/" + imgFileName(pic.panel)})
+			media = append(media, pic.panel)
+		}
+		slideParts = append(slideParts,
+			ooxmlPart{fmt.Sprintf("ppt/slides/slide%d.xml", n), pptxSlideXML(s, deck, rep.locale().languageTag())},
+			ooxmlPart{fmt.Sprintf("ppt/slides/_rels/slide%d.xml.rels", n), ooxmlRelationships(slideRels)})
+	}
+
+	parts := []ooxmlPart{
+		{"[Content_Types].xml", fmt.Sprintf(pptxContentTypes, deck.contentTypes+slideTypes.String())},
+		{"_rels/.rels", pptxPackageRels},
+		{"docProps/core.xml", fmt.Sprintf(ooxmlCoreProps, xmlEscape(dash.Title))},
+		{"ppt/presentation.xml", fmt.Sprintf(pptxPresentation, masterIDs.String(), slideIDs.String(), deck.width, deck.height)},
+		{"ppt/_rels/presentation.xml.rels", ooxmlRelationships(presRels)},
+	}
+	parts = append(parts, deck.parts...)
+	if err := writeOOXMLParts(zw, append(parts, slideParts...)); err != nil {
+		return err
+	}
+	added := map[int]bool{}
+	for _, p := range media {
+		if added[p.Id] {
+			continue
+		}
+		added[p.Id] = true
+		if err := addFileToZip(zw, "ppt/media/"+imgFileName(p), rep.imgFilePath(p)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pptxSlideXML returns the XML of a slide, with its text in the language lang, e.g. fr-FR
+func pptxSlideXML(s pptxSlide, deck pptxDeck, lang string) string {
+	var tree bytes.Buffer
+	id := 2
+	if s.heading != "" {
+		pptxTextShape(&tree, id, pptxText{s.heading, 2800, true, pptxMargin, pptxMargin / 2, deck.width - 2*pptxMargin, pptxHeadingHeight}, lang)
+		id++
+	}
+	for _, t := range s.texts {
+		pptxTextShape(&tree, id, t, lang)
+		id++
+	}
+	for _, pic := range s.pictures {
+		fmt.Fprintf(&tree, `<p:pic><p:nvPicPr><p:cNvPr id="%d" name="%s" descr="%s"/><p:cNvPicPr><a:picLocks noChangeAspect="1"/></p:cNvPicPr><p:nvPr/></p:nvPicPr>`+
+			`<p:blipFill><a:blip r:embed="rIdImage%d"/><a:stretch><a:fillRect/></a:stretch></p:blipFill>`+
+			`<p:spPr><a:xfrm><a:off x="%d" y="%d"/><a:ext cx="%d" cy="%d"/></a:xfrm><a:prstGeom prst="rect"><a:avLst/></a:prstGeom></p:spPr></p:pic>`,
+			id, imgFileName(pic.panel), xmlEscape(pic.panel.Title), pic.panel.Id, pic.x, pic.y, pic.w, pic.h)
+		id++
+	}
+	return fmt.Sprintf(pptxSlideTemplate, tree.String())
+}
+
+func pptxTextShape(w *bytes.Buffer, id int, t pptxText, lang string) {
+	bold := ""
+	if t.bold {
+		bold = ` b="1"`
+	}
+	fmt.Fprintf(w, `<p:sp><p:nvSpPr><p:cNvPr id="%d" name="Text %d"/><p:cNvSpPr txBox="1"/><p:nvPr/></p:nvSpPr>`+
+		`<p:spPr><a:xfrm><a:off x="%d" y="%d"/><a:ext cx="%d" cy="%d"/></a:xfrm><a:prstGeom prst="rect"><a:avLst/></a:prstGeom></p:spPr>`+
+		`<p:txBody><a:bodyPr wrap="square" anchor="ctr"><a:normAutofit/></a:bodyPr><a:lstStyle/><a:p><a:pPr algn="ctr"/>`+
+		`<a:r><a:rPr lang="%s" sz="%d"%s/><a:t>%s</a:t></a:r></a:p></p:txBody></p:sp>`,
+		id, id, t.x, t.y, t.w, t.h, xmlEscape(lang), t.size, bold, xmlEscape(t.text))
+}
+
+const pptxNamespaces = ` xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main"` +
+	` xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"` +
+	` xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main"`
+
+const pptxEmptyTree = `<p:nvGrpSpPr><p:cNvPr id="1" name=""/><p:cNvGrpSpPr/><p:nvPr/></p:nvGrpSpPr><p:grpSpPr/>`
+
+const pptxContentTypes = xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Default Extension="png" ContentType="image/png"/>` +
+	`<Override PartName="/ppt/presentation.xml" ContentType="application/vnd.openxmlformats-officedocument.presentationml.presentation.main+xml"/>` +
+	`<Override PartName="/docProps/core.xml" ContentType="application/vnd.openxmlformats-package.core-properties+xml"/>` +
+	`%s</Types>`
+
+const pptxBuiltinContentTypes = `<Override PartName="/ppt/slideMasters/slideMaster1.xml" ContentType="application/vnd.openxmlformats-officedocument.presentationml.slideMaster+xml"/>` +
+	`<Override PartName="/ppt/slideLayouts/slideLayout1.xml" ContentType="application/vnd.openxmlformats-officedocument.presentationml.slideLayout+xml"/>` +
+	`<Override PartName="/ppt/theme/theme1.xml" ContentType="application/vnd.openxmlformats-officedocument.theme+xml"/>`
+
+const pptxPackageRels = xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="` + relTypeOfficeDocument + `" Target="ppt/presentation.xml"/>` +
+	`<Relationship Id="rId2" Type="` + relTypeCoreProps + `" Target="docProps/core.xml"/>` +
+	`</Relationships>`
+
+const pptxPresentation = xml.Header + `<p:presentation` + pptxNamespaces + `>` +
+	`<p:sldMasterIdLst>%s</p:sldMasterIdLst>` +
+	`<p:sldIdLst>%s</p:sldIdLst><p:sldSz cx="%d" cy="%d"/><p:notesSz cx="6858000" cy="9144000"/></p:presentation>`
+
+const pptxSlideMaster = xml.Header + `<p:sldMaster` + pptxNamespaces + `>` +
+	`<p:cSld><p:bg><p:bgRef idx="1001"><a:schemeClr val="bg1"/></p:bgRef></p:bg><p:spTree>` + pptxEmptyTree + `</p:spTree></p:cSld>` +
+	`<p:clrMap bg1="lt1" tx1="dk1" bg2="lt2" tx2="dk2" accent1="accent1" accent2="accent2" accent3="accent3" accent4="accent4"` +
+	` accent5="accent5" accent6="accent6" hlink="hlink" folHlink="folHlink"/>` +
+	`<p:sldLayoutIdLst><p:sldLayoutId id="2147483649" r:id="rId1"/></p:sldLayoutIdLst></p:sldMaster>`
+
+const pptxSlideLayout = xml.Header + `<p:sldLayout` + pptxNamespaces + ` type="blank" preserve="1">` +
+	`<p:cSld name="Blank"><p:spTree>` + pptxEmptyTree + `</p:spTree></p:cSld><p:clrMapOvr><a:masterClrMapping/></p:clrMapOvr></p:sldLayout>`
+
+const pptxSlideTemplate = xml.Header + `<p:sld` + pptxNamespaces + `>` +
+	`<p:cSld><p:spTree>` + pptxEmptyTree + `%s</p:spTree></p:cSld><p:clrMapOvr><a:masterClrMapping/></p:clrMapOvr></p:sld>`
+
+const pptxSolidFill = `<a:solidFill><a:schemeClr val="phClr"/></a:solidFill>`
+
+const pptxTheme = xml.Header + `<a:theme xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" name="Reporter">` +
+	`<a:themeElements><a:clrScheme name="Reporter">` +
+	`<a:dk1><a:srgbClr val="000000"/></a:dk1><a:lt1><a:srgbClr val="FFFFFF"/></a:lt1>` +
+	`<a:dk2><a:srgbClr val="1F2D3D"/></a:dk2><a:lt2><a:srgbClr val="EEF0F2"/></a:lt2>` +
+	`<a:accent1><a:srgbClr val="1F78C1"/></a:accent1><a:accent2><a:srgbClr val="EB7B18"/></a:accent2>` +
+	`<a:accent3><a:srgbClr val="7EB26D"/></a:accent3><a:accent4><a:srgbClr val="E24D42"/></a:accent4>` +
+	`<a:accent5><a:srgbClr val="BA43A9"/></a:accent5><a:accent6><a:srgbClr val="EAB839"/></a:accent6>` +
+	`<a:hlink><a:srgbClr val="1F78C1"/></a:hlink><a:folHlink><a:srgbClr val="BA43A9"/></a:folHlink></a:clrScheme>` +
+	`<a:fontScheme name="Reporter"><a:majorFont><a:latin typeface="Calibri"/><a:ea typeface=""/><a:cs typeface=""/></a:majorFont>` +
+	`<a:minorFont><a:latin typeface="Calibri"/><a:ea typeface=""/><a:cs typeface=""/></a:minorFont></a:fontScheme>` +
+	`<a:fmtScheme name="Reporter">` +
+	`<a:fillStyleLst>` + pptxSolidFill + pptxSolidFill + pptxSolidFill + `</a:fillStyleLst>` +
+	`<a:lnStyleLst><a:ln w="6350">` + pptxSolidFill + `</a:ln><a:ln w="12700">` + pptxSolidFill + `</a:ln><a:ln w="19050">` + pptxSolidFill + `</a:ln></a:lnStyleLst>` +
+	`<a:effectStyleLst><a:effectStyle><a:effectLst/></a:effectStyle><a:effectStyle><a:effectLst/></a:effectStyle><a:effectStyle><a:effectLst/></a:effectStyle></a:effectStyleLst>` +
+	`<a:bgFillStyleLst>` + pptxSolidFill + pptxSolidFill + pptxSolidFill + `</a:bgFillStyleLst>` +
+	`</a:fmtScheme></a:themeElements></a:theme>`
@@ -0,0 +1,229 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/IzakMarais/reporter/grafana"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPptxReport(t *testing.T) {
+	Convey("When generating a PPTX report", t, func() {
+		gClient := &v5Client{mockGrafanaClient{0, url.Values{}}}
+		rep := new(gClient, "abc123", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Format: FormatPPTX})
+		defer rep.Clean()
+
+		file, err := rep.Generate()
+		So(err, ShouldBeNil)
+		file.Close()
+		files := readZipFiles(rep.pptxPath())
+
+		Convey("Every XML part should be well formed", func() {
+			for name, content := range files {
+				if strings.HasSuffix(name, ".xml") || strings.HasSuffix(name, ".rels") {
+					d := xml.NewDecoder(strings.NewReader(content))
+					var err error
+					for err == nil {
+						_, err = d.Token()
+					}
+					So(err.Error(), ShouldEqual, "EOF")
+				}
+			}
+		})
+
+		Convey("It should have a title slide and one slide per panel", func() {
+			So(files, ShouldContainKey, "ppt/slides/slide4.xml")
+			So(files, ShouldNotContainKey, "ppt/slides/slide5.xml")
+			So(files["ppt/slides/slide1.xml"], ShouldContainSubstring, "<a:t>Rows &amp; panels</a:t>")
+			So(files["ppt/slides/slide1.xml"], ShouldContainSubstring, " to ")
+			So(files["ppt/presentation.xml"], ShouldContainSubstring, `<p:sldId id="259" r:id="rIdSlide4"/>`)
+		})
+
+		Convey("Panel slides should use the panel title as heading", func() {
+			So(files["ppt/slides/slide4.xml"], ShouldContainSubstring, "<a:t>Queries &lt;per second&gt;</a:t>")
+			So(files["ppt/slides/_rels/slide4.xml.rels"], ShouldContainSubstring, `Target="../media/image4.png"`)
+			So(files, ShouldContainKey, "ppt/media/image4.png")
+		})
+
+		Convey("It should use 16:9 slides by default", func() {
+			So(files["ppt/presentation.xml"], ShouldContainSubstring, `<p:sldSz cx="12192000" cy="6858000"/>`)
+		})
+
+		Convey("Text should be tagged as English by default", func() {
+			So(files["ppt/slides/slide1.xml"], ShouldContainSubstring, `lang="en-US"`)
+		})
+	})
+
+	Convey("When generating a French PPTX report", t, func() {
+		gClient := &v5Client{mockGrafanaClient{0, url.Values{}}}
+		rep := new(gClient, "abc123", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Format: FormatPPTX, Locale: LocaleFrench})
+		defer rep.Clean()
+
+		file, err := rep.Generate()
+		So(err, ShouldBeNil)
+		file.Close()
+		files := readZipFiles(rep.pptxPath())
+
+		Convey("Text should be tagged with the language of the locale", func() {
+			So(files["ppt/slides/slide1.xml"], ShouldContainSubstring, `lang="fr-FR"`)
+			So(files["ppt/slides/slide4.xml"], ShouldContainSubstring, `lang="fr-FR"`)
+			So(files["ppt/slides/slide1.xml"], ShouldNotContainSubstring, `lang="en-US"`)
+		})
+	})
+
+	Convey("When generating a PPTX report with a slide per row in grid layout", t, func() {
+		gClient := &v5Client{mockGrafanaClient{0, url.Values{}}}
+		rep := new(gClient, "abc123", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Format: FormatPPTX, Slides: SlidesPerRow, GridLayout: true})
+		defer rep.Clean()
+
+		file, err := rep.Generate()
+		So(err, ShouldBeNil)
+		file.Close()
+		files := readZipFiles(rep.pptxPath())
+
+		Convey("It should have a title slide and one slide per row", func() {
+			So(files, ShouldContainKey, "ppt/slides/slide3.xml")
+			So(files, ShouldNotContainKey, "ppt/slides/slide4.xml")
+			So(files["ppt/slides/slide3.xml"], ShouldContainSubstring, "<a:t>Database</a:t>")
+		})
+
+		Convey("It should arrange the row's panels as on the dashboard grid", func() {
+			slide := files["ppt/slides/slide2.xml"]
+			So(strings.Count(slide, "<p:pic>"), ShouldEqual, 2)
+			So(slide, ShouldContainSubstring, `<a:off x="457200" y="1371600"/><a:ext cx="5638800" cy="1879600"/>`)
+			So(slide, ShouldContainSubstring, `<a:off x="6096000" y="1371600"/><a:ext cx="5638800" cy="1879600"/>`)
+		})
+	})
+
+	Convey("When generating a PPTX report without grid layout", t, func() {
+		gClient := &v5PngClient{v5Client{mockGrafanaClient{0, url.Values{}}}}
+		perPanel := new(gClient, "abc123", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Format: FormatPPTX})
+		defer perPanel.Clean()
+		perRow := new(gClient, "abc123", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Format: FormatPPTX, Slides: SlidesPerRow})
+		defer perRow.Clean()
+		for _, rep := range []*report{perPanel, perRow} {
+			file, err := rep.Generate()
+			So(err, ShouldBeNil)
+			file.Close()
+		}
+
+		Convey("Pictures should keep the aspect ratio of the rendered image", func() {
+			So(readZipFiles(perPanel.pptxPath())["ppt/slides/slide4.xml"], ShouldContainSubstring, `<a:off x="457200" y="2476500"/><a:ext cx="11277600" cy="2819400"/>`)
+			slide := readZipFiles(perRow.pptxPath())["ppt/slides/slide2.xml"]
+			So(slide, ShouldContainSubstring, `<a:off x="457200" y="1606550"/><a:ext cx="5638800" cy="1409700"/>`)
+			So(slide, ShouldContainSubstring, `<a:off x="6096000" y="1606550"/><a:ext cx="5638800" cy="1409700"/>`)
+		})
+	})
+
+	Convey("When generating a PPTX report from a template", t, func() {
+		var tmpl bytes.Buffer
+		zw := zip.NewWriter(&tmpl)
+		w, _ := zw.Create("ppt/presentation.xml")
+		w.Write([]byte(`<p:presentation xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main"><p:sldSz cx="9144000" cy="6858000"/></p:presentation>`))
+		w, _ = zw.Create("ppt/theme/theme1.xml")
+		w.Write([]byte(`<a:theme name="Corporate"/>`))
+		zw.Close()
+
+		gClient := &v5Client{mockGrafanaClient{0, url.Values{}}}
+		rep := new(gClient, "abc123", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Format: FormatPPTX, Template: tmpl.String()})
+		defer rep.Clean()
+
+		file, err := rep.Generate()
+		So(err, ShouldBeNil)
+		file.Close()
+		files := readZipFiles(rep.pptxPath())
+
+		Convey("It should use the template's slide size and theme", func() {
+			So(files["ppt/presentation.xml"], ShouldContainSubstring, `<p:sldSz cx="9144000" cy="6858000"/>`)
+			So(files["ppt/theme/theme1.xml"], ShouldEqual, `<a:theme name="Corporate"/>`)
+		})
+	})
+
+	Convey("When generating a PPTX report from a template with a slide master", t, func() {
+		var tmpl bytes.Buffer
+		zw := zip.NewWriter(&tmpl)
+		for name, content := range map[string]string{
+			"[Content_Types].xml": `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+				`<Default Extension="xml" ContentType="application/xml"/><Default Extension="jpeg" ContentType="image/jpeg"/>` +
+				`<Override PartName="/ppt/slideMasters/slideMaster1.xml" ContentType="application/vnd.openxmlformats-officedocument.presentationml.slideMaster+xml"/>` +
+				`<Override PartName="/ppt/slideLayouts/slideLayout2.xml" ContentType="application/vnd.openxmlformats-officedocument.presentationml.slideLayout+xml"/>` +
+				`<Override PartName="/ppt/slides/slide1.xml" ContentType="application/vnd.openxmlformats-officedocument.presentationml.slide+xml"/></Types>`,
+			"ppt/presentation.xml": `<p:presentation xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+				`<p:sldMasterIdLst><p:sldMasterId id="2147483700" r:id="rId1"/></p:sldMasterIdLst><p:sldSz cx="9144000" cy="6858000"/></p:presentation>`,
+			"ppt/_rels/presentation.xml.rels": ooxmlRelationships([]ooxmlRel{
+				{"rId1", relTypeSlideMaster, "slideMasters/slideMaster1.xml"},
+				{"rId2", relTypeTheme, "theme/theme1.xml"},
+				{"rId3", relTypeSlide, "slides/slide1.xml"},
+			}),
+			"ppt/slideMasters/slideMaster1.xml": `<p:sldMaster name="Corporate"/>`,
+			"ppt/slideMasters/_rels/slideMaster1.xml.rels": ooxmlRelationships([]ooxmlRel{
+				{"rId1", relTypeSlideLayout, "../slideLayouts/slideLayout1.xml"},
+				{"rId2", relTypeSlideLayout, "../slideLayouts/slideLayout2.xml"},
+				{"rId3", relTypeImage, "../media/image1.jpeg"},
+			}),
+			"ppt/slideLayouts/slideLayout1.xml": `<p:sldLayout type="title"/>`,
+			"ppt/slideLayouts/slideLayout2.xml": `<p:sldLayout type="blank"/>`,
+			"ppt/theme/theme1.xml":              `<a:theme name="Corporate"/>`,
+			"ppt/media/image1.jpeg":             "logo",
+			"ppt/slides/slide1.xml":             `<p:sld name="Example"/>`,
+		} {
+			w, _ := zw.Create(name)
+			w.Write([]byte(content))
+		}
+		zw.Close()
+
+		gClient := &v5Client{mockGrafanaClient{0, url.Values{}}}
+		rep := new(gClient, "abc123", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Format: FormatPPTX, Template: tmpl.String()})
+		defer rep.Clean()
+
+		file, err := rep.Generate()
+		So(err, ShouldBeNil)
+		file.Close()
+		files := readZipFiles(rep.pptxPath())
+
+		Convey("It should use the template's slide master and theme", func() {
+			So(files["ppt/presentation.xml"], ShouldContainSubstring, `<p:sldMasterId id="2147483700" r:id="rIdMaster1"/>`)
+			So(files["ppt/slideMasters/slideMaster1.xml"], ShouldEqual, `<p:sldMaster name="Corporate"/>`)
+			So(files["ppt/theme/theme1.xml"], ShouldEqual, `<a:theme name="Corporate"/>`)
+			So(files["[Content_Types].xml"], ShouldContainSubstring, `<Default Extension="jpeg" ContentType="image/jpeg"/>`)
+			So(files["[Content_Types].xml"], ShouldContainSubstring, `<Override PartName="/ppt/slideLayouts/slideLayout2.xml"`)
+		})
+
+		Convey("The slides should use the template's blank layout", func() {
+			So(files["ppt/slides/_rels/slide1.xml.rels"], ShouldContainSubstring, `Target="../slideLayouts/slideLayout2.xml"`)
+			So(files["ppt/slides/_rels/slide4.xml.rels"], ShouldContainSubstring, `Target="../slideLayouts/slideLayout2.xml"`)
+		})
+
+		Convey("The template's media should not collide with the panel images", func() {
+			So(files["ppt/media/template/image1.jpeg"], ShouldEqual, "logo")
+			So(files["ppt/slideMasters/_rels/slideMaster1.xml.rels"], ShouldContainSubstring, `Target="../media/template/image1.jpeg"`)
+			So(files, ShouldContainKey, "ppt/media/image4.png")
+		})
+
+		Convey("The template's slides should be replaced by the report's", func() {
+			So(files["ppt/slides/slide1.xml"], ShouldContainSubstring, "<a:t>Rows &amp; panels</a:t>")
+			So(strings.Count(files["[Content_Types].xml"], `PartName="/ppt/slides/slide1.xml"`), ShouldEqual, 1)
+		})
+	})
+}
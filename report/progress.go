@@ -0,0 +1,64 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import "context"
+
+const (
+	// PhaseDashboard is fetching the dashboard definition
+	PhaseDashboard = "dashboard"
+	// PhaseRendering is rendering the panel images
+	PhaseRendering = "rendering"
+	// PhaseGenerating is generating the report document from the images, e.g. running the TeX engine
+	PhaseGenerating = "generating"
+)
+
+// Progress describes how far a report has been generated
+type Progress struct {
+	Phase          string `json:"phase"`
+	PanelsRendered int    `json:"panelsRendered"`
+	PanelsTotal    int    `json:"panelsTotal"`
+}
+
+func (rep *report) context() context.Context {
+	if rep.opts.Context != nil {
+		return rep.opts.Context
+	}
+	return context.Background()
+}
+
+func (rep *report) setPhase(phase string, panels int) {
+	rep.progressMu.Lock()
+	defer rep.progressMu.Unlock()
+	rep.progress.Phase = phase
+	rep.progress.PanelsTotal = panels
+	rep.reportProgress()
+}
+
+func (rep *report) panelRendered() {
+	rep.progressMu.Lock()
+	defer rep.progressMu.Unlock()
+	rep.progress.PanelsRendered++
+	rep.reportProgress()
+}
+
+// reportProgress calls Options.Progress, with progressMu held so that the calls are in order
+func (rep *report) reportProgress() {
+	if rep.opts.Progress != nil {
+		rep.opts.Progress(rep.progress)
+	}
+}
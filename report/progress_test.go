@@ -0,0 +1,64 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/IzakMarais/reporter/grafana"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestProgress(t *testing.T) {
+	Convey("When generating a report with a progress callback", t, func() {
+		var updates []Progress
+		opts := Options{Format: FormatHTML, Progress: func(p Progress) { updates = append(updates, p) }}
+		rep := new(&mockGrafanaClient{0, url.Values{}}, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, opts)
+		defer rep.Clean()
+		file, err := rep.Generate()
+		So(err, ShouldBeNil)
+		file.Close()
+
+		Convey("It should report each phase in order", func() {
+			So(updates[0], ShouldResemble, Progress{Phase: PhaseDashboard})
+			So(updates[1], ShouldResemble, Progress{Phase: PhaseRendering, PanelsTotal: 9})
+			So(updates[len(updates)-1], ShouldResemble, Progress{Phase: PhaseGenerating, PanelsRendered: 9, PanelsTotal: 9})
+		})
+
+		Convey("It should report each rendered panel", func() {
+			So(updates, ShouldHaveLength, 12)
+			So(updates[2].PanelsRendered, ShouldEqual, 1)
+		})
+	})
+
+	Convey("When generating a report that is cancelled", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		gClient := &mockGrafanaClient{0, url.Values{}}
+		rep := new(gClient, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Format: FormatHTML, Context: ctx})
+		defer rep.Clean()
+		_, err := rep.Generate()
+
+		Convey("It should stop rendering and return an error", func() {
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, context.Canceled.Error())
+			So(gClient.getPanelCallCount, ShouldEqual, 0)
+		})
+	})
+}
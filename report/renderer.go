@@ -0,0 +1,190 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"fmt"
+	"image"
+	_ "image/png" //register png decoder for image.DecodeConfig
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/IzakMarais/reporter/grafana"
+)
+
+// renderer produces the final document once all panel images have been rendered into the report's image directory
+type renderer interface {
+	render(rep *report, dash grafana.Dashboard) (io.ReadCloser, error)
+}
+
+// OptionsError is returned when the options of a report are invalid, before any panel is rendered
+type OptionsError struct {
+	Reason string
+}
+
+func (e *OptionsError) Error() string {
+	return "invalid report options: " + e.Reason
+}
+
+// Validate checks the options of a report, such as its format, backend, paper size, comparison, locale and branding.
+// Reports check them before rendering; Validate lets callers reject invalid options without creating a report.
+// The error is an *OptionsError.
+func (o Options) Validate() error {
+	if _, err := newRenderer(o); err != nil {
+		return &OptionsError{err.Error()}
+	}
+	if _, err := o.pageSettings(PaperLetter); err != nil {
+		return &OptionsError{err.Error()}
+	}
+	if err := o.validateComparison(); err != nil {
+		return &OptionsError{err.Error()}
+	}
+	if _, err := loadLocale(o.Locale, o.TemplateDir); err != nil {
+		return &OptionsError{err.Error()}
+	}
+	if err := o.Branding.validate(); err != nil {
+		return &OptionsError{err.Error()}
+	}
+	return nil
+}
+
+func newRenderer(opts Options) (renderer, error) {
+	switch opts.Format {
+	case "", FormatPDF:
+	case FormatHTML:
+		return htmlRenderer{}, nil
+	case FormatZIP:
+		return zipRenderer{}, nil
+	case FormatDOCX:
+		return docxRenderer{}, nil
+	case FormatPPTX:
+		return pptxRenderer{}, nil
+	default:
+		return nil, fmt.Errorf("unknown report format %q, expected one of: %s", opts.Format, strings.Join(formats, ", "))
+	}
+
+	switch opts.Backend {
+	case "", BackendLaTeX:
+		switch opts.Engine {
+		case "", EnginePdfLaTeX, EngineXeLaTeX, EngineLuaLaTeX:
+			return latexRenderer{}, nil
+		}
+		return nil, fmt.Errorf("unknown TeX engine %q, expected one of: %s", opts.Engine, strings.Join(engines, ", "))
+	case BackendNative:
+		if opts.TOC {
+			return nil, fmt.Errorf("a table of contents is only available from the %v backend, the %v backend adds bookmarks instead", BackendLaTeX, BackendNative)
+		}
+		return nativeRenderer{}, nil
+	}
+	return nil, fmt.Errorf("unknown report backend %q, expected %q or %q", opts.Backend, BackendLaTeX, BackendNative)
+}
+
+// latexRenderer fills in the TeX template and compiles it with the selected TeX engine
+type latexRenderer struct{}
+
+func (latexRenderer) render(rep *report, dash grafana.Dashboard) (io.ReadCloser, error) {
+	err := rep.generateTeXFile(dash)
+	if err != nil {
+		return nil, fmt.Errorf("error generating TeX file for dash %+v: %v", dash, err)
+	}
+	pdf, err := rep.runLaTeX(dash)
+	if err != nil {
+		return nil, err
+	}
+	return pdf, nil
+}
+
+// plainDashboard returns a copy of dash with the TeX escaping removed from all titles,
+// for renderers that do not typeset with LaTeX
+func plainDashboard(dash grafana.Dashboard) grafana.Dashboard {
+	dash.Title = grafana.PlainText(dash.Title)
+	dash.Description = grafana.PlainText(dash.Description)
+	dash.VariableValues = grafana.PlainText(dash.VariableValues)
+	tags := make([]string, len(dash.Tags))
+	for i, tag := range dash.Tags {
+		tags[i] = grafana.PlainText(tag)
+	}
+	dash.Tags = tags
+	dash.Panels = plainPanels(dash.Panels)
+	rows := make([]grafana.Row, len(dash.Rows))
+	for i, r := range dash.Rows {
+		r.Title = grafana.PlainText(r.Title)
+		r.Panels = plainPanels(r.Panels)
+		rows[i] = r
+	}
+	dash.Rows = rows
+	return dash
+}
+
+func plainPanels(panels []grafana.Panel) []grafana.Panel {
+	plain := make([]grafana.Panel, len(panels))
+	for i, p := range panels {
+		p.Title = grafana.PlainText(p.Title)
+		plain[i] = p
+	}
+	return plain
+}
+
+// panelAspect returns the height to width ratio of a panel's image. In grid layout it is taken from the panel's
+// gridPos if present, as the image is rendered at that size. Otherwise it is taken from the rendered image,
+// falling back to the 2:1 ratio used to render graphs without a grid layout.
+func (rep *report) panelAspect(p grafana.Panel) float64 {
+	if rep.opts.GridLayout && p.GridPos.W > 0 && p.GridPos.H > 0 {
+		return p.GridPos.H / p.GridPos.W
+	}
+	if f, err := os.Open(rep.imgFilePath(p)); err == nil {
+		defer f.Close()
+		if cfg, _, err := image.DecodeConfig(f); err == nil && cfg.Width > 0 {
+			return float64(cfg.Height) / float64(cfg.Width)
+		}
+	}
+	return 0.5
+}
+
+// panelWidth returns the fraction of the page width a panel occupies in the grid layout.
+// Panels without a gridPos take the width of their span in v4 dashboards, or else the full width,
+// except for singlestat panels.
+func panelWidth(p grafana.Panel) float64 {
+	if p.GridPos.W > 0 {
+		return p.GridPos.W / 24
+	}
+	if p.Span > 0 {
+		return math.Min(p.Span/12, 1)
+	}
+	if p.IsSingleStat() {
+		return 0.3
+	}
+	return 1
+}
+
+// panelGroups returns the dashboard's panels grouped by row. Rows that do not show a title
+// are returned with an empty title. Dashboards without rows are returned as a single untitled group.
+func panelGroups(dash grafana.Dashboard) []grafana.Row {
+	if len(dash.Rows) == 0 {
+		return []grafana.Row{{Panels: dash.Panels}}
+	}
+	groups := make([]grafana.Row, len(dash.Rows))
+	for i, r := range dash.Rows {
+		if !r.IsVisible() {
+			r.Title = ""
+		}
+		groups[i] = r
+	}
+	return groups
+}
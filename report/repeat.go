@@ -0,0 +1,117 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/IzakMarais/reporter/grafana"
+)
+
+// repeated is a composite report of one dashboard with a chapter per value of a template variable
+type repeated struct {
+	*composite
+	newClient func(variables url.Values) grafana.Client
+	variables url.Values
+	dashName  string
+	repeatBy  string
+}
+
+// NewRepeated creates a report of a dashboard with a chapter per value of the template variable repeatBy, e.g. var-host,
+// each showing the whole dashboard with the variable set to that value. The values are those of repeatBy in variables or,
+// if there are none or they select All, the options of the variable saved in the dashboard.
+// newClient returns a client for the dashboard with the given variables.
+// Like composite reports, repeated reports are only available as PDF from the LaTeX backend.
+func NewRepeated(newClient func(variables url.Values) grafana.Client, dashName, repeatBy string, variables url.Values, t grafana.TimeRange, opts Options) Report {
+	return newRepeated(newClient, dashName, repeatBy, variables, t, opts)
+}
+
+func newRepeated(newClient func(variables url.Values) grafana.Client, dashName, repeatBy string, variables url.Values, t grafana.TimeRange, opts Options) *repeated {
+	if !strings.HasPrefix(repeatBy, "var-") {
+		repeatBy = "var-" + repeatBy
+	}
+	return &repeated{newComposite("", t, nil, opts), newClient, variables, dashName, repeatBy}
+}
+
+// ValidateRepeated checks the options of a report repeated by a template variable like Validate, and that they select
+// a PDF from the LaTeX backend, the only one that repeated reports are available from. The error is an *OptionsError.
+func (o Options) ValidateRepeated() error {
+	return o.validateLaTeXOnly("reports repeated by a variable")
+}
+
+// expand fetches the dashboard and adds a part per value of the repeated variable, titled with the value.
+// It does nothing if the parts were already added.
+func (r *repeated) expand() error {
+	if len(r.parts) > 0 {
+		return nil
+	}
+	dash, err := r.newClient(r.variables).GetDashboard(r.dashName)
+	if err != nil {
+		return fmt.Errorf("error fetching dashboard %v: %v", r.dashName, err)
+	}
+	name := strings.TrimPrefix(r.repeatBy, "var-")
+	values := r.variables[r.repeatBy]
+	if len(values) == 0 || grafana.IsAll(values) {
+		values = dash.VariableOptions(name)
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("no values to repeat the dashboard by: variable %q is not set and has no saved options", name)
+	}
+
+	r.dash = &grafana.Dashboard{Title: dash.Title, Description: dash.Description, Tags: dash.Tags}
+	r.dashTitle = grafana.PlainText(dash.Title)
+	for _, v := range values {
+		variables := url.Values{}
+		for k, vs := range r.variables {
+			variables[k] = vs
+		}
+		variables[r.repeatBy] = []string{v}
+		r.addPart(Part{r.newClient(variables), r.dashName, r.time, v})
+	}
+	return nil
+}
+
+// Generate fetches the dashboard, and then generates the report like a composite report of a dashboard per value
+func (r *repeated) Generate() (io.ReadCloser, error) {
+	if err := r.opts.ValidateRepeated(); err != nil {
+		return nil, err
+	}
+	r.setPhase(PhaseDashboard, 0)
+	if err := r.expand(); err != nil {
+		return nil, err
+	}
+	return r.composite.Generate()
+}
+
+// CacheKey identifies the report by the cache keys of the dashboard for each value
+func (r *repeated) CacheKey() (string, error) {
+	if err := r.expand(); err != nil {
+		return "", err
+	}
+	return r.composite.CacheKey()
+}
+
+// Title is the title of the dashboard
+func (r *repeated) Title() string {
+	if err := r.expand(); err != nil {
+		return ""
+	}
+	return r.dashTitle
+}
@@ -0,0 +1,155 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/IzakMarais/reporter/grafana"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const hostsDashJSON = `
+{"dashboard": {
+	"title": "Hosts",
+	"panels": [{"type": "graph", "id": 1, "title": "CPU", "gridPos": {"h": 8, "w": 24, "x": 0, "y": 0}}],
+	"templating": {"list": [{"name": "host", "options": [
+		{"text": "All", "value": "$__all"},
+		{"text": "db1", "value": "db1"},
+		{"text": "db_2", "value": "db_2"}
+	]}]}
+}}`
+
+// hostsClient serves a dashboard with a host variable, whose options are db1 and db_2
+type hostsClient struct {
+	mockGrafanaClient
+}
+
+func (m *hostsClient) GetDashboard(dashName string) (grafana.Dashboard, error) {
+	return grafana.NewDashboard([]byte(hostsDashJSON), m.variables), nil
+}
+
+func TestRepeatedReport(t *testing.T) {
+	tr := grafana.TimeRange{From: "1453206447000", To: "1453213647000"}
+	var mu sync.Mutex
+	var clients []*hostsClient
+	newClient := func(variables url.Values) grafana.Client {
+		mu.Lock()
+		defer mu.Unlock()
+		c := &hostsClient{mockGrafanaClient{0, variables}}
+		clients = append(clients, c)
+		return c
+	}
+
+	Convey("When generating a report repeated by a variable without values", t, func() {
+		clients = nil
+		r := newRepeated(newClient, "hosts", "host", url.Values{"var-region": {"eu"}}, tr, Options{})
+		defer r.Clean()
+		r.Generate() //fails without a TeX installation, after writing the TeX file
+		b, err := ioutil.ReadFile(r.texPath())
+		So(err, ShouldBeNil)
+		s := string(b)
+
+		Convey("It should have a chapter per option of the variable, headed by the value", func() {
+			So(s, ShouldContainSubstring, `\title{Hosts`)
+			So(s, ShouldContainSubstring, `\chapter{db1}`)
+			So(s, ShouldContainSubstring, `\chapter{db\_2}`)
+			So(s, ShouldNotContainSubstring, `\chapter{\$\_\_all}`)
+		})
+
+		Convey("It should render the panels once per value, with the variable pinned", func() {
+			So(clients, ShouldHaveLength, 3)
+			So(clients[1].variables, ShouldResemble, url.Values{"var-region": {"eu"}, "var-host": {"db1"}})
+			So(clients[2].variables, ShouldResemble, url.Values{"var-region": {"eu"}, "var-host": {"db_2"}})
+			So(clients[1].getPanelCallCount, ShouldEqual, 1)
+			So(clients[2].getPanelCallCount, ShouldEqual, 1)
+		})
+
+		Convey("Its title should be the dashboard title", func() {
+			So(r.Title(), ShouldEqual, "Hosts")
+		})
+	})
+
+	Convey("When generating a repeated report with a public URL", t, func() {
+		clients = nil
+		r := newRepeated(newClient, "hosts", "host", url.Values{}, tr, Options{PublicURL: "http://localhost:3000"})
+		defer r.Clean()
+
+		Convey("It should write the TeX file with links in the chapters", func() {
+			So(func() { r.Generate() }, ShouldNotPanic)
+			_, err := os.Stat(r.texPath())
+			So(err, ShouldBeNil)
+			So(r.chapters()[0].DashboardURL, ShouldStartWith, "http://localhost:3000/d/hosts")
+		})
+	})
+
+	Convey("When generating a report repeated by a variable with selected values", t, func() {
+		clients = nil
+		r := newRepeated(newClient, "hosts", "var-host", url.Values{"var-host": {"web1", "web2", "web3"}}, tr, Options{})
+		defer r.Clean()
+		So(r.expand(), ShouldBeNil)
+
+		Convey("It should have a chapter per selected value", func() {
+			So(r.parts, ShouldHaveLength, 3)
+			So(r.parts[2].heading, ShouldEqual, "web3")
+		})
+	})
+
+	Convey("When the variable has no values", t, func() {
+		r := newRepeated(newClient, "hosts", "port", url.Values{}, tr, Options{})
+		defer r.Clean()
+		_, err := r.Generate()
+
+		Convey("It should be an error", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("When generating a repeated report in another format or backend", t, func() {
+		for _, opts := range []Options{{Format: FormatDOCX}, {Backend: BackendNative}} {
+			clients = nil
+			r := newRepeated(newClient, "hosts", "var-host", url.Values{}, tr, opts)
+			_, err := r.Generate()
+			r.Clean()
+
+			Convey("It should be an options error, found before fetching the dashboard: "+opts.brandingKey(), func() {
+				So(err, ShouldHaveSameTypeAs, &OptionsError{})
+				So(err.Error(), ShouldContainSubstring, "repeated by a variable")
+				So(clients, ShouldBeEmpty)
+			})
+		}
+	})
+
+	Convey("When computing the cache key of a repeated report", t, func() {
+		key := func(variables url.Values) string {
+			r := newRepeated(newClient, "hosts", "host", variables, tr, Options{})
+			defer r.Clean()
+			k, err := r.CacheKey()
+			So(err, ShouldBeNil)
+			return k
+		}
+
+		Convey("It should depend on the values", func() {
+			So(key(url.Values{}), ShouldEqual, key(url.Values{"var-host": {"All"}}))
+			So(key(url.Values{}), ShouldNotEqual, key(url.Values{"var-host": {"db1"}}))
+		})
+	})
+}
@@ -17,82 +17,266 @@
 package report
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"text/template"
+	"time"
 
 	"github.com/IzakMarais/reporter/grafana"
-	"github.com/pborman/uuid"
 )
 
 // Report groups functions related to genrating the report.
 // After reading and closing the pdf returned by Generate(), call Clean() to delete the pdf file as well the temporary build files
+// CacheKey() identifies the content Generate() produces, so that reports can be cached
 type Report interface {
 	Generate() (pdf io.ReadCloser, err error)
 	Title() string
 	Clean()
+	CacheKey() (string, error)
+}
+
+// Options configures the content and output format of a report
+type Options struct {
+	// Template is the content of a custom template file. If empty, a default template is used.
+	// A custom template that only defines blocks overrides those blocks of the default template.
+	Template string
+	// TemplateDir is the directory of custom templates. Its partials, files named _name.tex (or _name.html for HTML reports),
+	// are parsed into every template set.
+	TemplateDir string
+	// GridLayout sizes panels from their Grafana gridPos width and height
+	GridLayout bool
+	// Backend selects how the PDF is produced: BackendLaTeX (the default if empty) or BackendNative
+	Backend string
+	// Format selects the output document format: FormatPDF (the default if empty), FormatHTML, FormatZIP, FormatDOCX or FormatPPTX
+	Format string
+	// Slides selects whether PPTX reports have a slide per panel (SlidesPerPanel, the default if empty) or per row (SlidesPerRow)
+	Slides string
+	// Engine selects the TeX engine used by the LaTeX backend: EnginePdfLaTeX (the default if empty), EngineXeLaTeX or EngineLuaLaTeX
+	Engine string
+	// FontsDir is a directory of font files that the default templates load MainFont from when using a Unicode engine
+	FontsDir string
+	// MainFont is the main document font for Unicode engines: a font name, or a file name in FontsDir
+	MainFont string
+	// PublicURL is the root URL of Grafana used for links back to the dashboard and panels. There are no links if it is empty.
+	PublicURL string
+	// User is the name of the user or API key that requested the report
+	User string
+	// Meta holds arbitrary values for templates, such as the meta-* request parameters without their prefix
+	Meta map[string]string
+	// Version is the reporter version, for templates
+	Version string
+	// Context cancels the report: rendering stops before the next panel and the TeX engine is killed. Optional.
+	Context context.Context
+	// Progress is called when the report enters a new phase and after each panel is rendered. Optional.
+	Progress func(Progress)
+	// RowBreak starts every dashboard row that shows its title on a new page
+	RowBreak bool
+	// TOC adds a table of contents of the dashboard rows and panels after the title of PDF reports
+	TOC bool
+	// Paper is the paper size of PDF reports: PaperA4, PaperLetter or PaperA3. If empty, the LaTeX backend uses
+	// letter and the native backend A4.
+	Paper string
+	// Orientation is OrientationPortrait (the default if empty), OrientationLandscape or OrientationAuto
+	Orientation string
+	// Margin is the margin on all sides of PDF pages, a length like 2cm. If empty, it is 1in, or 0.5in in grid layout.
+	Margin string
+	// Locale selects the language of the text of the default templates and the formatting of dates and numbers:
+	// LocaleEnglish (the default if empty), LocaleFrench, LocaleGerman, or a locale with a message catalogue in TemplateDir
+	Locale string
+	// Branding is the logo, colour, company name, cover page and disclaimer of the default templates
+	Branding BrandingConfig
+	// Filter selects the panels to include. Panels that it leaves out are not rendered.
+	Filter PanelFilter
+	// Compare renders every panel for these time ranges as well, next to the panel for the report's time range
+	Compare []grafana.TimeRange
+	// CompareShift renders every panel for the report's time range shifted back by each of these, e.g. 1w, see grafana.TimeRange.Shift
+	CompareShift []string
+	// CompareLayout places the images of compared periods side by side (CompareSideBySide, the default if empty) or stacked (CompareStacked)
+	CompareLayout string
+	// Strict fails the report if any panel fails to render. Otherwise failed panels are replaced by a placeholder image
+	// with the error, and listed in an appendix.
+	Strict bool
+	// Scheduler limits the number of panels rendered at the same time, shared with other reports.
+	// A default Scheduler with DefaultRenderConcurrency is used if it is nil.
+	Scheduler *Scheduler
+	// Workspace is where the report is generated, and refuses it once its quota is used. A Workspace in DefaultWorkDir
+	// without a quota is used if it is nil.
+	Workspace *Workspace
+	// DiagnosticsRetention is how long the TeX source and log of a failed LaTeX run are kept for download, see Workspace.DiagnosticsFile.
+	// They are not kept if it is zero.
+	DiagnosticsRetention time.Duration
+}
+
+const (
+	// BackendLaTeX typesets the report from a TeX template using the selected TeX engine
+	BackendLaTeX = "latex"
+	// BackendNative lays out the report in Go and does not require a TeX installation
+	BackendNative = "native"
+)
+
+const (
+	// EnginePdfLaTeX supports Latin scripts only, but is the most widely installed TeX engine
+	EnginePdfLaTeX = "pdflatex"
+	// EngineXeLaTeX reads Unicode input and supports system and OpenType fonts through fontspec
+	EngineXeLaTeX = "xelatex"
+	// EngineLuaLaTeX reads Unicode input and supports system and OpenType fonts through fontspec
+	EngineLuaLaTeX = "lualatex"
+)
+
+var engines = []string{EnginePdfLaTeX, EngineXeLaTeX, EngineLuaLaTeX}
+
+const (
+	// FormatPDF produces a PDF document using the selected Backend
+	FormatPDF = "pdf"
+	// FormatHTML produces a single HTML file with the panel images embedded
+	FormatHTML = "html"
+	// FormatZIP produces a ZIP archive of the panel images, the TeX source and a JSON manifest
+	FormatZIP = "zip"
+	// FormatDOCX produces an editable Microsoft Word document
+	FormatDOCX = "docx"
+	// FormatPPTX produces a Microsoft PowerPoint slide deck
+	FormatPPTX = "pptx"
+)
+
+var formats = []string{FormatPDF, FormatHTML, FormatZIP, FormatDOCX, FormatPPTX}
+
+const (
+	// SlidesPerPanel puts every panel on a slide of its own
+	SlidesPerPanel = "panel"
+	// SlidesPerRow puts the panels of each dashboard row on one slide, arranged as on the dashboard
+	SlidesPerRow = "row"
+)
+
+// FileExtension returns the file name extension, including the dot, of reports in the given format
+func FileExtension(format string) string {
+	if format == "" {
+		format = FormatPDF
+	}
+	return "." + format
+}
+
+// ContentType returns the MIME type of reports in the given format
+func ContentType(format string) string {
+	switch format {
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	case FormatZIP:
+		return "application/zip"
+	case FormatDOCX:
+		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	case FormatPPTX:
+		return "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+	}
+	return "application/pdf"
+}
+
+// TemplateExtension returns the file name extension of custom templates for the given format.
+// HTML and PPTX reports use templates of their own format, the other formats are generated from, or include, TeX templates.
+func TemplateExtension(format string) string {
+	switch format {
+	case FormatHTML, FormatPPTX:
+		return FileExtension(format)
+	}
+	return ".tex"
 }
 
 type report struct {
 	gClient     grafana.Client
 	time        grafana.TimeRange
+	opts        Options
 	texTemplate string
 	dashName    string
 	tmpDir      string
 	dashTitle   string
+	generatedAt time.Time
+	dash        *grafana.Dashboard
+	failures    []PanelFailure
+	// parts are the dashboards of a composite report, typeset as chapters
+	parts []*report
+	// heading is the title of a part's chapter, if it is not the dashboard title
+	heading string
+	// periods are the time ranges of a comparison report, resolved when rendering
+	periods    []period
+	progressMu sync.Mutex
+	progress   Progress
+}
+
+// PanelFailure is a panel that failed to render, in a report that is not Strict
+type PanelFailure struct {
+	Panel grafana.Panel
+	Error string
 }
 
 const (
 	imgDir        = "images"
 	reportTexFile = "report.tex"
 	reportPdf     = "report.pdf"
+	reportHTML    = "report.html"
+	reportZip     = "report.zip"
+	reportDocx    = "report.docx"
+	reportPptx    = "report.pptx"
+	manifestFile  = "manifest.json"
 )
 
 // New creates a new Report.
-// texTemplate is the content of a LaTex template file. If empty, a default tex template is used.
-func New(g grafana.Client, dashName string, time grafana.TimeRange, texTemplate string, gridLayout bool) Report {
-	return new(g, dashName, time, texTemplate, gridLayout)
+// opts.Template is the content of a LaTex template file. If empty, a default tex template is used.
+func New(g grafana.Client, dashName string, time grafana.TimeRange, opts Options) Report {
+	return new(g, dashName, time, opts)
 }
 
-func new(g grafana.Client, dashName string, time grafana.TimeRange, texTemplate string, gridLayout bool) *report {
-	if texTemplate == "" {
-		if gridLayout {
-			texTemplate = defaultGridTemplate
-		} else {
-			texTemplate = defaultTemplate
-		}
-
+func new(g grafana.Client, dashName string, t grafana.TimeRange, opts Options) *report {
+	texTemplate := defaultTemplate
+	if opts.GridLayout {
+		texTemplate = defaultGridTemplate
+	}
+	rep := &report{
+		gClient:     g,
+		time:        t,
+		opts:        opts,
+		texTemplate: texTemplate,
+		dashName:    dashName,
+		generatedAt: time.Now(),
 	}
-	tmpDir := filepath.Join("tmp", uuid.New())
-	return &report{g, time, texTemplate, dashName, tmpDir, ""}
+	rep.tmpDir = rep.workspace().newDir()
+	return rep
 }
 
-// Generate returns the report.pdf file.  After reading this file it should be Closed()
+// Generate returns the report file, e.g. report.pdf or report.html depending on the format.  After reading this file it should be Closed()
 // After closing the file, call report.Clean() to delete the file as well the temporary build files
 func (rep *report) Generate() (pdf io.ReadCloser, err error) {
-	dash, err := rep.gClient.GetDashboard(rep.dashName)
+	if err = rep.workspace().CheckQuota(); err != nil {
+		return
+	}
+	if err = rep.opts.Validate(); err != nil {
+		return
+	}
+	r, err := newRenderer(rep.opts)
 	if err != nil {
-		err = fmt.Errorf("error fetching dashboard %v: %v", rep.dashName, err)
 		return
 	}
-	rep.dashTitle = dash.Title
-
-	err = rep.renderPNGsParallel(dash)
+	rep.setPhase(PhaseDashboard, 0)
+	dash, err := rep.dashboard()
 	if err != nil {
-		err = fmt.Errorf("error rendering PNGs in parralel for dash %+v: %v", dash, err)
 		return
 	}
-	err = rep.generateTeXFile(dash)
+
+	rep.setPhase(PhaseRendering, len(dash.Panels)*rep.opts.periodCount())
+	err = rep.renderPNGsParallel(dash)
 	if err != nil {
-		err = fmt.Errorf("error generating TeX file for dash %+v: %v", dash, err)
+		err = fmt.Errorf("error rendering PNGs in parralel for dash %+v: %v", dash, err)
 		return
 	}
-	pdf, err = rep.runLaTeX()
+	rep.setPhase(PhaseGenerating, len(dash.Panels))
+	pdf, err = r.render(rep, dash)
 	return
 }
 
@@ -100,13 +284,28 @@ func (rep *report) Generate() (pdf io.ReadCloser, err error) {
 func (rep *report) Title() string {
 	//lazy fetch if Title() is called before Generate()
 	if rep.dashTitle == "" {
+		if _, err := rep.dashboard(); err != nil {
+			return ""
+		}
+	}
+	return rep.dashTitle
+}
+
+// dashboard fetches the dashboard once, for use by both CacheKey() and Generate()
+func (rep *report) dashboard() (grafana.Dashboard, error) {
+	if rep.dash == nil {
 		dash, err := rep.gClient.GetDashboard(rep.dashName)
 		if err != nil {
-			return ""
+			return dash, fmt.Errorf("error fetching dashboard %v: %v", rep.dashName, err)
+		}
+		dash, err = rep.opts.Filter.apply(dash)
+		if err != nil {
+			return dash, err
 		}
+		rep.dash = &dash
 		rep.dashTitle = dash.Title
 	}
-	return rep.dashTitle
+	return *rep.dash, nil
 }
 
 // Clean deletes the temporary directory used during report generation
@@ -115,6 +314,13 @@ func (rep *report) Clean() {
 	if err != nil {
 		log.Println("Error cleaning up tmp dir:", err)
 	}
+	rep.workspace().release(rep.tmpDir)
+}
+
+// locale returns the locale of the report, validated by newRenderer
+func (rep *report) locale() locale {
+	l, _ := loadLocale(rep.opts.Locale, rep.opts.TemplateDir)
+	return l
 }
 
 func (rep *report) imgDirPath() string {
@@ -125,52 +331,123 @@ func (rep *report) pdfPath() string {
 	return filepath.Join(rep.tmpDir, reportPdf)
 }
 
+func imgFileName(p grafana.Panel) string {
+	return fmt.Sprintf("image%d.png", p.Id)
+}
+
+func (rep *report) imgFilePath(p grafana.Panel) string {
+	return filepath.Join(rep.imgDirPath(), imgFileName(p))
+}
+
+func (rep *report) htmlPath() string {
+	return filepath.Join(rep.tmpDir, reportHTML)
+}
+
+func (rep *report) zipPath() string {
+	return filepath.Join(rep.tmpDir, reportZip)
+}
+
+func (rep *report) docxPath() string {
+	return filepath.Join(rep.tmpDir, reportDocx)
+}
+
+func (rep *report) pptxPath() string {
+	return filepath.Join(rep.tmpDir, reportPptx)
+}
+
 func (rep *report) texPath() string {
 	return filepath.Join(rep.tmpDir, reportTexFile)
 }
 
+// renderPNGsParallel fetches the panel images from Grafana in parallel.
+// The scheduler limits the concurrency across all reports, to avoid overwhelming Grafana.
+// Unless the report is Strict, panels that fail to render get a placeholder image with the error and are listed in rep.failures.
+// Comparison reports fetch the images of every period at the same time.
 func (rep *report) renderPNGsParallel(dash grafana.Dashboard) error {
-	//buffer all panels on a channel
-	panels := make(chan grafana.Panel, len(dash.Panels))
-	for _, p := range dash.Panels {
-		panels <- p
+	periods, err := rep.comparePeriods()
+	if err != nil {
+		return err
+	}
+	rep.periods = periods
+	if len(periods) == 0 {
+		periods = []period{{TimeRange: rep.time}}
 	}
-	close(panels)
 
-	//fetch images in parrallel form Grafana sever.
-	//limit concurrency using a worker pool to avoid overwhelming grafana
-	//for dashboards with many panels.
+	var mu sync.Mutex
+	var placeholderErr error
+	failed := map[int]string{}
+	errs := make([]error, len(periods))
 	var wg sync.WaitGroup
-	workers := 5
-	wg.Add(workers)
-	errs := make(chan error, len(dash.Panels)) //routines can return errors on a channel
-	for i := 0; i < workers; i++ {
-		go func(panels <-chan grafana.Panel, errs chan<- error) {
+	for k, t := range periods {
+		wg.Add(1)
+		go func(k int, t period) {
 			defer wg.Done()
-			for p := range panels {
-				err := rep.renderPNG(p)
-				if err != nil {
-					log.Printf("Error creating image for panel: %v", err)
-					errs <- err
+			errs[k] = rep.scheduler().Render(rep.renderServer(dash), dash.Panels, func(p grafana.Panel) error {
+				if err := rep.context().Err(); err != nil {
+					return err
 				}
-			}
-		}(panels, errs)
+				err := rep.renderPNG(p, t.TimeRange, rep.periodImgFilePath(p, k))
+				rep.panelRendered()
+				if err == nil {
+					return nil
+				}
+				log.Printf("Error creating image for panel: %v", err)
+				if !rep.opts.Strict {
+					perr := rep.writeFailedPanelImage(p, rep.periodImgFilePath(p, k), err)
+					msg := err.Error()
+					if k > 0 {
+						start, end := t.locale.formatPeriod(t.FromTime(), t.ToTime())
+						msg = t.locale.messageWith("compared", "{from}", start, "{to}", end) + ": " + msg
+					}
+					mu.Lock()
+					if perr != nil && placeholderErr == nil {
+						placeholderErr = fmt.Errorf("error creating placeholder image for panel %v: %v", p.Id, perr)
+					}
+					if _, ok := failed[p.Id]; !ok || k == 0 {
+						failed[p.Id] = msg
+					}
+					mu.Unlock()
+				}
+				//the scheduler slows down on some errors, so it sees them even if the report tolerates them
+				return err
+			})
+		}(k, t)
 	}
 	wg.Wait()
-	close(errs)
-
-	for err := range errs {
+	if ctxErr := rep.context().Err(); ctxErr != nil {
+		return ctxErr
+	}
+	for _, err = range errs {
 		if err != nil {
-			return err
+			break
+		}
+	}
+	if rep.opts.Strict || err == nil {
+		return err
+	}
+	if placeholderErr != nil {
+		return placeholderErr
+	}
+	rep.failures = nil
+	for _, p := range dash.Panels {
+		if msg, ok := failed[p.Id]; ok {
+			rep.failures = append(rep.failures, PanelFailure{p, msg})
 		}
 	}
 	return nil
 }
 
-func (rep *report) renderPNG(p grafana.Panel) error {
-	body, err := rep.gClient.GetPanelPng(p, rep.dashName, rep.time)
+// Complete reports whether all panels were rendered, so that the report can be cached
+func (rep *report) Complete() bool {
+	return len(rep.failures) == 0
+}
+
+// renderPNG fetches the image of panel p for time range t from Grafana and writes it to path
+func (rep *report) renderPNG(p grafana.Panel, t grafana.TimeRange, path string) error {
+	body, err := rep.gClient.GetPanelPng(p, rep.dashName, t)
 	if err != nil {
-		return fmt.Errorf("error getting panel %+v: %v", p, err)
+		//wrapped with %w, for the scheduler to see whether Grafana is overloaded
+		return fmt.Errorf("error getting panel %+v: %w", p, err)
 	}
 	defer body.Close()
 
@@ -178,8 +455,7 @@ func (rep *report) renderPNG(p grafana.Panel) error {
 	if err != nil {
 		return fmt.Errorf("error creating img directory:%v", err)
 	}
-	imgFileName := fmt.Sprintf("image%d.png", p.Id)
-	file, err := os.Create(filepath.Join(rep.imgDirPath(), imgFileName))
+	file, err := os.Create(path)
 	if err != nil {
 		return fmt.Errorf("error creating image file:%v", err)
 	}
@@ -192,50 +468,351 @@ func (rep *report) renderPNG(p grafana.Panel) error {
 	return nil
 }
 
+// templData is the data available to report templates
+type templData struct {
+	grafana.Dashboard
+	grafana.TimeRange
+	grafana.Client
+	TeX texSettings
+	// GeneratedAt is when the report was requested
+	GeneratedAt time.Time
+	// Version is the reporter version
+	Version string
+	// User is the name of the user or API key that requested the report
+	User string
+	// Meta holds the values of the meta-* request parameters, by name without the prefix
+	Meta map[string]string
+	// DashboardURL links to the dashboard in Grafana, with the report's time range and variables. It is empty if there is no public URL.
+	DashboardURL string
+	// Failures lists the panels that failed to render and their errors, for an appendix
+	Failures []PanelFailure
+	// TOC is true if the report should have a table of contents
+	TOC bool
+	// RowBreak is true if every titled section should start on a new page
+	RowBreak bool
+	// Page is the paper size, orientation and margins of TeX reports
+	Page pageSettings
+	// ImageDir is the directory of the panel images, relative to the TeX file
+	ImageDir string
+	// Chapters are the dashboards of a composite report, each with the data of a report of its own
+	Chapters []templData
+	// Locale is the name of the report's locale, e.g. fr
+	Locale string
+	locale locale
+	// Branding is the logo, colour, company name, cover page and disclaimer. In TeX templates, Logo is the name
+	// of the logo file next to the TeX file.
+	Branding Branding
+	// Periods are the time ranges of a comparison report, the report's own first. It is empty if the report is not a comparison.
+	Periods        []period
+	compareStacked bool
+	panelURL       func(grafana.Panel) string
+	panelAspect    func(grafana.Panel) float64
+}
+
+// PanelURL links to the panel in Grafana, with the report's time range and variables. It is empty if there is no public URL.
+func (d templData) PanelURL(p grafana.Panel) string {
+	if d.panelURL == nil {
+		return ""
+	}
+	return d.panelURL(p)
+}
+
+// Message returns the text of a message key in the report's locale, from the built-in messages or a message catalogue
+func (d templData) Message(key string) string {
+	return d.locale.message(key)
+}
+
+// FromFormatted is the start of the report's time range, formatted for the report's locale
+func (d templData) FromFormatted() string {
+	return d.locale.formatTime(d.FromTime())
+}
+
+// ToFormatted is the end of the report's time range, formatted for the report's locale
+func (d templData) ToFormatted() string {
+	return d.locale.formatTime(d.ToTime())
+}
+
+// FormatDate formats t with a Go time layout, e.g. "2 January 2006", with the month and day names of the report's locale
+func (d templData) FormatDate(layout string, t time.Time) string {
+	return d.locale.formatDate(layout, t)
+}
+
+// FormatNumber formats f with the given number of decimals and the decimal and thousands separators of the report's locale
+func (d templData) FormatNumber(f float64, decimals int) string {
+	return d.locale.formatNumber(f, decimals)
+}
+
+// DocumentClass is the LaTeX document class of the report: report, with chapters, for composite reports, otherwise article
+func (d templData) DocumentClass() string {
+	if len(d.Chapters) > 0 {
+		return "report"
+	}
+	return "article"
+}
+
+// Sections returns the panels grouped by dashboard row, for bookmarks and the table of contents.
+// Rows that do not show a title have an empty Title.
+func (d templData) Sections() []grafana.Row {
+	return panelGroups(d.Dashboard)
+}
+
+// RowSpace is the height of a section of the default template, its heading and panels, for \needspace to keep
+// them on one page. Sections taller than a page only keep their heading with the first line of panels.
+func (d templData) RowSpace(row grafana.Row) string {
+	tw, th := d.Page.textSize(d.Page.Landscape)
+	aspect := d.panelAspect
+	if aspect == nil {
+		aspect = func(grafana.Panel) float64 { return 0.5 }
+	}
+	var lines []float64
+	line, inLine := 0.0, 0
+	endLine := func() {
+		if inLine > 0 {
+			lines = append(lines, line)
+			line, inLine = 0, 0
+		}
+	}
+	for _, p := range row.Panels {
+		if p.IsSingleStat() {
+			if inLine == 3 {
+				endLine()
+			}
+			line, inLine = math.Max(line, 0.3*tw*aspect(p)), inLine+1
+			continue
+		}
+		endLine()
+		lines = append(lines, 2*panelSpace+math.Min(tw*aspect(p), 0.9*th))
+	}
+	endLine()
+
+	heading := 0.0
+	if row.Title != "" {
+		heading = 0.75 * inch
+	}
+	height := heading
+	for _, h := range lines {
+		height += h
+	}
+	if height > th && len(lines) > 0 {
+		height = heading + lines[0]
+	}
+	return strconv.FormatFloat(math.Min(height, th), 'f', 1, 64) + "bp"
+}
+
+// texURLEscaper escapes the characters of URLs that are special in the arguments of \url and \href
+var texURLEscaper = strings.NewReplacer("%", "\\%", "#", "\\#")
+
+// templData returns the data for templates. For TeX templates, the request metadata and links are escaped for TeX.
+func (rep *report) templData(dash grafana.Dashboard, tex bool) templData {
+	text, link := func(s string) string { return s }, func(s string) string { return s }
+	if tex {
+		text, link = grafana.EscapeLaTeX, texURLEscaper.Replace
+	}
+	data := templData{
+		Dashboard:   dash,
+		TimeRange:   rep.time,
+		Client:      rep.gClient,
+		GeneratedAt: rep.generatedAt,
+		Version:     text(rep.opts.Version),
+		User:        text(rep.opts.User),
+		Meta:        map[string]string{},
+		TOC:         rep.opts.TOC,
+		RowBreak:    rep.opts.RowBreak,
+		ImageDir:    imgDir,
+		locale:      rep.locale(),
+	}
+	data.Locale = data.locale.name
+	for k, v := range data.locale.messages {
+		data.locale.messages[k] = text(v)
+	}
+	for k, v := range rep.opts.Meta {
+		data.Meta[k] = text(v)
+	}
+	data.Branding = rep.opts.branding()
+	data.Branding.Company, data.Branding.Disclaimer = text(data.Branding.Company), text(data.Branding.Disclaimer)
+	if tex && data.Branding.Logo != "" {
+		data.Branding.Logo = data.Branding.logoFile()
+	}
+	for _, f := range rep.failures {
+		if !tex {
+			f.Panel.Title = grafana.PlainText(f.Panel.Title)
+		}
+		data.Failures = append(data.Failures, PanelFailure{f.Panel, text(f.Error)})
+	}
+	if rep.opts.PublicURL != "" && rep.gClient != nil { //the cover of a composite report has no dashboard to link to
+		base := strings.TrimRight(rep.opts.PublicURL, "/")
+		data.DashboardURL = link(base + rep.gClient.GetDashboardPath(rep.dashName, rep.time))
+		data.panelURL = func(p grafana.Panel) string {
+			return link(base + rep.gClient.GetPanelPath(p, rep.dashName, rep.time))
+		}
+	}
+	return data
+}
+
+// texSettings describe the TeX engine and fonts to TeX templates, as .TeX
+type texSettings struct {
+	Engine   string
+	FontsDir string
+	MainFont string
+	// RTL is true if the dashboard contains right-to-left text, marked with \RL{...}
+	RTL bool
+}
+
+// Unicode is true for the engines that support fontspec and Unicode input
+func (t texSettings) Unicode() bool {
+	return t.Engine == EngineXeLaTeX || t.Engine == EngineLuaLaTeX
+}
+
+// BidiPackage returns the package that defines \RL and typesets right-to-left text with the engine
+func (t texSettings) BidiPackage() string {
+	if t.Engine == EngineLuaLaTeX {
+		return "luabidi"
+	}
+	return "bidi"
+}
+
+func (rep *report) texData(dash grafana.Dashboard) templData {
+	data := rep.templData(dash, true)
+	data.TeX = rep.texSettings(dash)
+	data.Page, _ = rep.opts.pageSettings(PaperLetter) //validated by newRenderer
+	data.panelAspect = rep.panelAspect
+	data.Chapters = rep.chapters()
+	data.compareStacked = rep.opts.CompareLayout == CompareStacked
+	for _, p := range rep.periods {
+		p.tex = true
+		data.Periods = append(data.Periods, p)
+	}
+	if len(data.Chapters) > 0 {
+		data.TOC = true //composite reports always have a table of contents
+	}
+	for _, ch := range data.Chapters {
+		data.TeX.RTL = data.TeX.RTL || ch.TeX.RTL
+	}
+	return data
+}
+
+func (rep *report) texSettings(dash grafana.Dashboard) texSettings {
+	t := texSettings{Engine: rep.engine(), MainFont: rep.opts.MainFont}
+	if rep.opts.FontsDir != "" {
+		//TeX runs in the temporary directory and expects forward slashes, even on Windows
+		dir, err := filepath.Abs(rep.opts.FontsDir)
+		if err != nil {
+			dir = rep.opts.FontsDir
+		}
+		t.FontsDir = filepath.ToSlash(dir)
+	}
+	texts := []string{dash.Title, dash.Description, dash.VariableValues}
+	for _, p := range dash.Panels {
+		texts = append(texts, p.Title)
+	}
+	for _, text := range texts {
+		if strings.Contains(text, `\RL{`) {
+			t.RTL = true
+		}
+	}
+	return t
+}
+
+func (rep *report) engine() string {
+	if rep.opts.Engine == "" {
+		return EnginePdfLaTeX
+	}
+	return rep.opts.Engine
+}
+
 func (rep *report) generateTeXFile(dash grafana.Dashboard) error {
-	type templData struct {
-		grafana.Dashboard
-		grafana.TimeRange
-		grafana.Client
+	tmpl, err := rep.parseTeXTemplate()
+	if err != nil {
+		return err
 	}
+	return rep.executeTeXTemplate(tmpl, dash)
+}
 
+func (rep *report) parseTeXTemplate() (*template.Template, error) {
+	sources, err := rep.templateSources(rep.texTemplate, ".tex")
+	if err != nil {
+		return nil, err
+	}
+	if len(rep.parts) > 0 {
+		//the composite body replaces the document body, but uses the blocks of the default and custom templates
+		sources = append(sources, templateSource{"report", compositeTemplate})
+	}
+	tmpl := template.New("report").Delims("[[", "]]").Funcs(texFuncs())
+	for _, src := range sources {
+		t := tmpl
+		if src.name != tmpl.Name() {
+			t = tmpl.New(src.name)
+		}
+		if _, err = t.Parse(src.text); err != nil {
+			return nil, fmt.Errorf("error parsing template %s: %v", src.name, err)
+		}
+	}
+	return tmpl, nil
+}
+
+func (rep *report) executeTeXTemplate(tmpl *template.Template, dash grafana.Dashboard) error {
 	err := os.MkdirAll(rep.tmpDir, 0777)
 	if err != nil {
 		return fmt.Errorf("error creating temporary directory at %v: %v", rep.tmpDir, err)
 	}
+	if b := rep.opts.branding(); b.Logo != "" {
+		if err = rep.copyLogo(b); err != nil {
+			return err
+		}
+	}
 	file, err := os.Create(rep.texPath())
 	if err != nil {
 		return fmt.Errorf("error creating tex file at %v : %v", rep.texPath(), err)
 	}
 	defer file.Close()
 
-	tmpl, err := template.New("report").Delims("[[", "]]").Parse(rep.texTemplate)
-	if err != nil {
-		return fmt.Errorf("error parsing template '%s': %v", rep.texTemplate, err)
-	}
-	data := templData{dash, rep.time, rep.gClient}
-	err = tmpl.Execute(file, data)
+	err = tmpl.Execute(file, rep.texData(dash))
 	if err != nil {
 		return fmt.Errorf("error executing tex template:%v", err)
 	}
 	return nil
 }
 
-func (rep *report) runLaTeX() (pdf *os.File, err error) {
-	cmdPre := exec.Command("pdflatex", "-halt-on-error", "-draftmode", reportTexFile)
+// draftFlag returns the engine's option to skip writing the output file, used for the first of the two LaTeX runs
+func draftFlag(engine string) string {
+	if engine == EngineXeLaTeX {
+		return "-no-pdf"
+	}
+	return "-draftmode"
+}
+
+// runLaTeX compiles the TeX file. If the TeX engine reports an error, the returned error is a *LaTeXError.
+func (rep *report) runLaTeX(dash grafana.Dashboard) (pdf *os.File, err error) {
+	engine := rep.engine()
+	cmdPre := exec.CommandContext(rep.context(), engine, "-halt-on-error", draftFlag(engine), reportTexFile)
 	cmdPre.Dir = rep.tmpDir
 	outBytesPre, errPre := cmdPre.CombinedOutput()
 	log.Println("Calling LaTeX - preprocessing")
 	if errPre != nil {
+		if err = rep.context().Err(); err != nil {
+			return
+		}
 		err = fmt.Errorf("error calling LaTeX preprocessing: %q. Latex preprocessing failed with output: %s ", errPre, string(outBytesPre))
+		if _, ok := errPre.(*exec.ExitError); ok {
+			log.Println(err)
+			err = rep.latexError(string(outBytesPre), rep.texData(dash))
+		}
 		return
 	}
-	cmd := exec.Command("pdflatex", "-halt-on-error", reportTexFile)
+	cmd := exec.CommandContext(rep.context(), engine, "-halt-on-error", reportTexFile)
 	cmd.Dir = rep.tmpDir
 	outBytes, err := cmd.CombinedOutput()
 	log.Println("Calling LaTeX and building PDF")
 	if err != nil {
+		exitErr := err
+		if err = rep.context().Err(); err != nil {
+			return
+		}
 		err = fmt.Errorf("error calling LaTeX: %q. Latex failed with output: %s ", err, string(outBytes))
+		if _, ok := exitErr.(*exec.ExitError); ok {
+			log.Println(err)
+			err = rep.latexError(string(outBytes), rep.texData(dash))
+		}
 		return
 	}
 	pdf, err = os.Open(rep.pdfPath())
@@ -19,10 +19,15 @@ package report
 import (
 	"bytes"
 	"errors"
+	"fmt"
+	"image"
+	"image/png"
 	"io"
 	"io/ioutil"
 	"net/url"
 	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/IzakMarais/reporter/grafana"
@@ -55,7 +60,8 @@ const dashJSON = `
 }`
 
 type mockGrafanaClient struct {
-	getPanelCallCount int
+	// getPanelCallCount is incremented atomically, as the scheduler renders panels concurrently
+	getPanelCallCount int64
 	variables         url.Values
 }
 
@@ -64,16 +70,28 @@ func (m *mockGrafanaClient) GetDashboard(dashName string) (grafana.Dashboard, er
 }
 
 func (m *mockGrafanaClient) GetPanelPng(p grafana.Panel, dashName string, t grafana.TimeRange) (io.ReadCloser, error) {
-	m.getPanelCallCount++
+	atomic.AddInt64(&m.getPanelCallCount, 1)
 	return ioutil.NopCloser(bytes.NewBuffer([]byte("Not actually a png"))), nil
 }
 
+func (m *mockGrafanaClient) GetPanelPngURL(p grafana.Panel, dashName string, t grafana.TimeRange) string {
+	return fmt.Sprintf("http://grafana/render/d-solo/%s/_?panelId=%d", dashName, p.Id)
+}
+
+func (m *mockGrafanaClient) GetDashboardPath(dashName string, t grafana.TimeRange) string {
+	return fmt.Sprintf("/d/%s?from=%s&to=%s", dashName, t.From, t.To)
+}
+
+func (m *mockGrafanaClient) GetPanelPath(p grafana.Panel, dashName string, t grafana.TimeRange) string {
+	return fmt.Sprintf("/d/%s?from=%s&to=%s&panelId=%d&fullscreen", dashName, t.From, t.To, p.Id)
+}
+
 func TestReport(t *testing.T) {
 	Convey("When generating a report", t, func() {
 		variables := url.Values{}
 		variables.Add("var-test", "testvarvalue")
 		gClient := &mockGrafanaClient{0, variables}
-		rep := new(gClient, "testDash", grafana.TimeRange{"1453206447000", "1453213647000"}, "", false)
+		rep := new(gClient, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{})
 		defer rep.Clean()
 
 		Convey("When rendering images", func() {
@@ -144,6 +162,12 @@ func TestReport(t *testing.T) {
 					So(s, ShouldContainSubstring, "Tue Jan 19")
 					So(s, ShouldContainSubstring, "2016")
 				})
+				Convey("and the pdflatex preamble", func() {
+					So(s, ShouldContainSubstring, `\usepackage[utf8]{inputenc}`)
+					So(s, ShouldContainSubstring, `\usepackage[letterpaper,margin=1in]{geometry}`)
+					So(s, ShouldContainSubstring, `\providecommand{\RL}[1]{#1}`)
+					So(s, ShouldNotContainSubstring, "fontspec")
+				})
 			})
 		})
 
@@ -158,7 +182,7 @@ func TestReport(t *testing.T) {
 }
 
 type errClient struct {
-	getPanelCallCount int
+	getPanelCallCount int64
 	variables         url.Values
 }
 
@@ -168,18 +192,29 @@ func (e *errClient) GetDashboard(dashName string) (grafana.Dashboard, error) {
 
 //Produce an error on the 2nd panel fetched
 func (e *errClient) GetPanelPng(p grafana.Panel, dashName string, t grafana.TimeRange) (io.ReadCloser, error) {
-	e.getPanelCallCount++
-	if e.getPanelCallCount == 2 {
+	if atomic.AddInt64(&e.getPanelCallCount, 1) == 2 {
 		return nil, errors.New("The second panel has some problem")
 	}
 	return ioutil.NopCloser(bytes.NewBuffer([]byte("Not actually a png"))), nil
 }
 
+func (e *errClient) GetPanelPngURL(p grafana.Panel, dashName string, t grafana.TimeRange) string {
+	return ""
+}
+
+func (e *errClient) GetDashboardPath(dashName string, t grafana.TimeRange) string {
+	return fmt.Sprintf("/d/%s?from=%s&to=%s", dashName, t.From, t.To)
+}
+
+func (e *errClient) GetPanelPath(p grafana.Panel, dashName string, t grafana.TimeRange) string {
+	return fmt.Sprintf("/d/%s?from=%s&to=%s&panelId=%d&fullscreen", dashName, t.From, t.To, p.Id)
+}
+
 func TestReportErrorHandling(t *testing.T) {
 	Convey("When generating a report where one panels gives an error", t, func() {
 		variables := url.Values{}
 		gClient := &errClient{0, variables}
-		rep := new(gClient, "testDash", grafana.TimeRange{"1453206447000", "1453213647000"}, "", false)
+		rep := new(gClient, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Strict: true})
 		defer rep.Clean()
 
 		Convey("When rendering images", func() {
@@ -212,4 +247,200 @@ func TestReportErrorHandling(t *testing.T) {
 		})
 	})
 
+	Convey("When generating a report that is not strict where one panel gives an error", t, func() {
+		gClient := &errClient{0, url.Values{}}
+		rep := new(gClient, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{})
+		defer rep.Clean()
+		dashboard, _ := gClient.GetDashboard("")
+		err := rep.renderPNGsParallel(dashboard)
+
+		Convey("It should not return an error", func() {
+			So(err, ShouldBeNil)
+			So(rep.Complete(), ShouldBeFalse)
+		})
+
+		Convey("It should list the failed panel", func() {
+			So(rep.failures, ShouldHaveLength, 1)
+			So(rep.failures[0].Error, ShouldContainSubstring, "The second panel has some problem")
+		})
+
+		Convey("It should create a placeholder image for the failed panel", func() {
+			f, err := os.Open(rep.imgFilePath(rep.failures[0].Panel))
+			So(err, ShouldBeNil)
+			defer f.Close()
+			img, err := png.Decode(f)
+			So(err, ShouldBeNil)
+			w, h := placeholderSize(rep.failures[0].Panel, false)
+			So(img.Bounds().Dx(), ShouldEqual, w)
+			So(img.Bounds().Dy(), ShouldEqual, h)
+		})
+
+		Convey("The default template should list the failed panel in an appendix", func() {
+			So(rep.generateTeXFile(dashboard), ShouldBeNil)
+			tex, _ := ioutil.ReadFile(rep.texPath())
+			So(string(tex), ShouldContainSubstring, `\section*{Panels that failed to render}`)
+			So(string(tex), ShouldContainSubstring, fmt.Sprintf(`\item Panel %d`, rep.failures[0].Panel.Id))
+		})
+	})
+}
+
+type pngClient struct {
+	mockGrafanaClient
+}
+
+func (m *pngClient) GetPanelPng(p grafana.Panel, dashName string, t grafana.TimeRange) (io.ReadCloser, error) {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 100, 50)))
+	return ioutil.NopCloser(&buf), nil
+}
+
+func TestNativeBackend(t *testing.T) {
+	Convey("When generating a report with the native backend", t, func() {
+		gClient := &pngClient{mockGrafanaClient{0, url.Values{}}}
+		rep := new(gClient, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Backend: BackendNative})
+		defer rep.Clean()
+
+		pdf, err := rep.Generate()
+		So(err, ShouldBeNil)
+		defer pdf.Close()
+		var buf bytes.Buffer
+		io.Copy(&buf, pdf)
+		s := buf.String()
+
+		Convey("It should produce a PDF without calling LaTeX", func() {
+			So(s, ShouldStartWith, "%PDF")
+			_, err := os.Stat(rep.texPath())
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+
+		Convey("It should include the title and every panel image", func() {
+			So(s, ShouldContainSubstring, "(My first dashboard)")
+			So(strings.Count(s, "/Subtype /Image"), ShouldEqual, 9)
+		})
+
+		Convey("It should number the pages as page X of Y", func() {
+			So(s, ShouldContainSubstring, "(Page 1 of ")
+			So(s, ShouldNotContainSubstring, "(1) Tj")
+		})
+
+		Convey("It should show the time range in the running headers", func() {
+			So(s, ShouldContainSubstring, " -- ")
+		})
+	})
+
+	Convey("When generating a report with the native backend in another locale", t, func() {
+		gClient := &pngClient{mockGrafanaClient{0, url.Values{}}}
+		rep := new(gClient, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Backend: BackendNative, Locale: "de"})
+		defer rep.Clean()
+
+		pdf, err := rep.Generate()
+		So(err, ShouldBeNil)
+		defer pdf.Close()
+		var buf bytes.Buffer
+		io.Copy(&buf, pdf)
+
+		Convey("It should number the pages in that locale", func() {
+			So(buf.String(), ShouldContainSubstring, "(Seite 1 von ")
+		})
+	})
+
+	Convey("When generating a report with rows and titled panels with the native backend", t, func() {
+		gClient := &v5PngClient{v5Client{mockGrafanaClient{0, url.Values{}}}}
+		rep := new(gClient, "abc123", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Backend: BackendNative})
+		defer rep.Clean()
+
+		pdf, err := rep.Generate()
+		So(err, ShouldBeNil)
+		defer pdf.Close()
+		var buf bytes.Buffer
+		io.Copy(&buf, pdf)
+		s := buf.String()
+
+		Convey("It should add a bookmark per row with its panels nested in it", func() {
+			So(s, ShouldContainSubstring, "/PageMode /UseOutlines")
+			So(s, ShouldContainSubstring, "/Title (Database)")
+			So(s, ShouldContainSubstring, "/Title (Queries <per second>)")
+		})
+	})
+
+	Convey("When generating a report with a table of contents with the native backend", t, func() {
+		gClient := &pngClient{mockGrafanaClient{0, url.Values{}}}
+		rep := new(gClient, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Backend: BackendNative, TOC: true})
+		defer rep.Clean()
+
+		_, err := rep.Generate()
+
+		Convey("It should reject the options", func() {
+			_, ok := err.(*OptionsError)
+			So(ok, ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "table of contents")
+		})
+	})
+
+	Convey("When generating a report with an unknown backend", t, func() {
+		gClient := &pngClient{mockGrafanaClient{0, url.Values{}}}
+		rep := new(gClient, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Backend: "word"})
+		defer rep.Clean()
+
+		_, err := rep.Generate()
+
+		Convey("It should fail with an OptionsError before rendering any panel", func() {
+			So(err, ShouldHaveSameTypeAs, &OptionsError{})
+			So(err.Error(), ShouldContainSubstring, "unknown report backend")
+			So(gClient.getPanelCallCount, ShouldEqual, 0)
+			So(Options{Backend: "word"}.Validate(), ShouldHaveSameTypeAs, &OptionsError{})
+			So(Options{}.Validate(), ShouldBeNil)
+		})
+	})
+}
+
+func TestTeXEngine(t *testing.T) {
+	Convey("When generating the TeX file for a Unicode engine", t, func() {
+		gClient := &mockGrafanaClient{0, url.Values{}}
+		opts := Options{Engine: EngineXeLaTeX, FontsDir: "/fonts", MainFont: "NotoSans-Regular.ttf"}
+		rep := new(gClient, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, opts)
+		defer rep.Clean()
+
+		readTeX := func(dash grafana.Dashboard) string {
+			err := rep.generateTeXFile(dash)
+			So(err, ShouldBeNil)
+			b, err := ioutil.ReadFile(rep.texPath())
+			So(err, ShouldBeNil)
+			return string(b)
+		}
+
+		Convey("It should load the main font from the fonts directory with fontspec", func() {
+			s := readTeX(grafana.Dashboard{Title: "日本語 🚀"})
+			So(s, ShouldContainSubstring, `\usepackage{fontspec}`)
+			So(s, ShouldContainSubstring, `\setmainfont{NotoSans-Regular.ttf}[Path=/fonts/]`)
+			So(s, ShouldContainSubstring, "日本語 🚀")
+			So(s, ShouldNotContainSubstring, "inputenc")
+			So(s, ShouldNotContainSubstring, `\usepackage{bidi}`)
+		})
+
+		Convey("It should load the bidi package for right-to-left titles", func() {
+			s := readTeX(grafana.Dashboard{Title: `\RL{שלום}`})
+			So(s, ShouldContainSubstring, `\usepackage{bidi}`)
+			So(s, ShouldNotContainSubstring, `\providecommand{\RL}`)
+		})
+
+		Convey("With lualatex it should use luabidi instead", func() {
+			rep.opts.Engine = EngineLuaLaTeX
+			s := readTeX(grafana.Dashboard{Panels: []grafana.Panel{{Id: 1, Title: `\RL{مرحبا}`}}})
+			So(s, ShouldContainSubstring, `\usepackage{luabidi}`)
+		})
+	})
+
+	Convey("When generating a report with an unknown TeX engine", t, func() {
+		gClient := &pngClient{mockGrafanaClient{0, url.Values{}}}
+		rep := new(gClient, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Engine: "tex"})
+		defer rep.Clean()
+
+		_, err := rep.Generate()
+
+		Convey("It should return an error", func() {
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "unknown TeX engine")
+		})
+	})
 }
@@ -0,0 +1,104 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"net/url"
+
+	"github.com/IzakMarais/reporter/grafana"
+)
+
+// SampleDashboardJSON is a Grafana v5 dashboard with panels of the common types, with and without rows,
+// used to check templates without a Grafana server
+const SampleDashboardJSON = `{
+  "dashboard": {
+    "uid": "sample",
+    "title": "Sample dashboard",
+    "description": "Sample dashboard for checking report templates",
+    "panels": [
+      {"id": 1, "type": "singlestat", "title": "Uptime", "gridPos": {"x": 0, "y": 0, "w": 6, "h": 4}},
+      {"id": 2, "type": "singlestat", "title": "Requests/s", "gridPos": {"x": 6, "y": 0, "w": 6, "h": 4}},
+      {"id": 3, "type": "text", "title": "Notes", "gridPos": {"x": 12, "y": 0, "w": 12, "h": 4}},
+      {"id": 4, "type": "row", "title": "Resources", "gridPos": {"x": 0, "y": 4, "w": 24, "h": 1}},
+      {"id": 5, "type": "graph", "title": "CPU load (%)", "gridPos": {"x": 0, "y": 5, "w": 12, "h": 8}},
+      {"id": 6, "type": "graph", "title": "Memory & swap", "gridPos": {"x": 12, "y": 5, "w": 12, "h": 8}},
+      {"id": 7, "type": "row", "title": "Details", "gridPos": {"x": 0, "y": 13, "w": 24, "h": 1}},
+      {"id": 8, "type": "table", "title": "Top processes", "gridPos": {"x": 0, "y": 14, "w": 24, "h": 9}}
+    ]
+  }
+}`
+
+// sampleClient serves one dashboard and placeholder panel images in place of a Grafana server
+type sampleClient struct {
+	dash       grafana.Dashboard
+	gridLayout bool
+}
+
+// NewSampleClient returns a grafana.Client for checking templates without a Grafana server.
+// It serves the dashboard in dashJSON, either as exported from Grafana or as returned by its API,
+// or SampleDashboardJSON if dashJSON is empty, and placeholder images sized like Grafana's renders.
+func NewSampleClient(dashJSON []byte, variables url.Values, gridLayout bool) (client grafana.Client, err error) {
+	//grafana.NewDashboard panics on JSON that does not match the dashboard structure
+	defer func() {
+		if r := recover(); r != nil {
+			client, err = nil, fmt.Errorf("error parsing dashboard JSON: %v", r)
+		}
+	}()
+	if len(bytes.TrimSpace(dashJSON)) == 0 {
+		dashJSON = []byte(SampleDashboardJSON)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(dashJSON, &fields); err != nil {
+		return nil, fmt.Errorf("error parsing dashboard JSON: %v", err)
+	}
+	if _, ok := fields["dashboard"]; !ok {
+		//exported dashboards are not wrapped like the API's response
+		dashJSON = []byte(`{"dashboard":` + string(dashJSON) + `}`)
+	}
+	return sampleClient{grafana.NewDashboard(dashJSON, variables), gridLayout}, nil
+}
+
+func (s sampleClient) GetDashboard(dashName string) (grafana.Dashboard, error) {
+	return s.dash, nil
+}
+
+func (s sampleClient) GetPanelPng(p grafana.Panel, dashName string, t grafana.TimeRange) (io.ReadCloser, error) {
+	w, h := placeholderSize(p, s.gridLayout)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, placeholderImage(w, h)); err != nil {
+		return nil, fmt.Errorf("error encoding placeholder image: %v", err)
+	}
+	return ioutil.NopCloser(&buf), nil
+}
+
+func (s sampleClient) GetPanelPngURL(p grafana.Panel, dashName string, t grafana.TimeRange) string {
+	return ""
+}
+
+func (s sampleClient) GetDashboardPath(dashName string, t grafana.TimeRange) string {
+	return "/d/" + dashName
+}
+
+func (s sampleClient) GetPanelPath(p grafana.Panel, dashName string, t grafana.TimeRange) string {
+	return fmt.Sprintf("/d/%s?panelId=%d&fullscreen", dashName, p.Id)
+}
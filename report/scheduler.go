@@ -0,0 +1,176 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"log"
+	"net/url"
+	"sync"
+
+	"github.com/IzakMarais/reporter/grafana"
+)
+
+// DefaultRenderConcurrency is the number of panels rendered at the same time per Grafana server by default
+const DefaultRenderConcurrency = 5
+
+// defaultScheduler renders the panels of reports that have no Options.Scheduler
+var defaultScheduler = NewScheduler(DefaultRenderConcurrency)
+
+// Scheduler limits the number of panels rendered at the same time per Grafana server, across all reports.
+// Reports waiting for a render take turns, so a large report does not hold up the others.
+// When Grafana responds with 429 Too Many Requests or 503 Service Unavailable, the limit for that server is halved,
+// then raised again by one for every limit's worth of successful renders.
+type Scheduler struct {
+	max      int
+	mu       sync.Mutex
+	backends map[string]*renderBackend
+}
+
+// renderBackend is the render queue of one Grafana server
+type renderBackend struct {
+	limit  float64
+	active int
+	jobs   []*renderJob //reports with panels waiting to be rendered, served round robin
+	next   int
+}
+
+// renderJob is the panels of one report
+type renderJob struct {
+	panels  []grafana.Panel
+	render  func(grafana.Panel) error
+	pending int
+	err     error
+	done    chan struct{}
+}
+
+// RenderStats describes the render queue of a Grafana server
+type RenderStats struct {
+	// Queued is the number of panels waiting to be rendered
+	Queued int `json:"queued"`
+	// Active is the number of panels being rendered
+	Active int `json:"active"`
+	// Limit is the current number of panels that may be rendered at the same time
+	Limit int `json:"limit"`
+}
+
+// NewScheduler returns a Scheduler that renders up to max panels at the same time per Grafana server
+func NewScheduler(max int) *Scheduler {
+	if max < 1 {
+		max = 1
+	}
+	return &Scheduler{max: max, backends: map[string]*renderBackend{}}
+}
+
+// Render calls render for every panel, sharing the render slots of the Grafana server with other reports.
+// It returns once all panels are rendered, with the first error.
+func (s *Scheduler) Render(server string, panels []grafana.Panel, render func(grafana.Panel) error) error {
+	if len(panels) == 0 {
+		return nil
+	}
+	j := &renderJob{panels: panels, render: render, pending: len(panels), done: make(chan struct{})}
+	s.mu.Lock()
+	b, ok := s.backends[server]
+	if !ok {
+		b = &renderBackend{limit: float64(s.max)}
+		s.backends[server] = b
+	}
+	b.jobs = append(b.jobs, j)
+	s.dispatch(b)
+	s.mu.Unlock()
+	<-j.done
+	return j.err
+}
+
+// dispatch starts renders while the server has free slots, taking one panel from each waiting report in turn
+func (s *Scheduler) dispatch(b *renderBackend) {
+	for b.active < int(b.limit) && len(b.jobs) > 0 {
+		if b.next >= len(b.jobs) {
+			b.next = 0
+		}
+		j := b.jobs[b.next]
+		p := j.panels[0]
+		j.panels = j.panels[1:]
+		if len(j.panels) == 0 {
+			b.jobs = append(b.jobs[:b.next], b.jobs[b.next+1:]...)
+		} else {
+			b.next++
+		}
+		b.active++
+		go s.run(b, j, p)
+	}
+}
+
+func (s *Scheduler) run(b *renderBackend, j *renderJob, p grafana.Panel) {
+	err := j.render(p)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b.active--
+	if grafana.IsOverloaded(err) {
+		b.limit /= 2
+		if b.limit < 1 {
+			b.limit = 1
+		}
+		log.Printf("Grafana is overloaded, reducing concurrent renders to %d", int(b.limit))
+	} else if err == nil && b.limit < float64(s.max) {
+		b.limit += 1 / b.limit
+		if b.limit > float64(s.max) {
+			b.limit = float64(s.max)
+		}
+	}
+	if err != nil && j.err == nil {
+		j.err = err
+	}
+	j.pending--
+	if j.pending == 0 {
+		close(j.done)
+	}
+	s.dispatch(b)
+}
+
+// Stats returns the render queue of every Grafana server the scheduler has rendered for, by server URL
+func (s *Scheduler) Stats() map[string]RenderStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := map[string]RenderStats{}
+	for server, b := range s.backends {
+		queued := 0
+		for _, j := range b.jobs {
+			queued += len(j.panels)
+		}
+		stats[server] = RenderStats{Queued: queued, Active: b.active, Limit: int(b.limit)}
+	}
+	return stats
+}
+
+// renderServer returns the scheme and host of the Grafana server that renders the report's panels
+func (rep *report) renderServer(dash grafana.Dashboard) string {
+	if len(dash.Panels) == 0 {
+		return ""
+	}
+	u, err := url.Parse(rep.gClient.GetPanelPngURL(dash.Panels[0], rep.dashName, rep.time))
+	if err != nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+func (rep *report) scheduler() *Scheduler {
+	if rep.opts.Scheduler != nil {
+		return rep.opts.Scheduler
+	}
+	return defaultScheduler
+}
@@ -0,0 +1,162 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IzakMarais/reporter/grafana"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func panelsWithIds(ids ...int) []grafana.Panel {
+	panels := []grafana.Panel{}
+	for _, id := range ids {
+		panels = append(panels, grafana.Panel{Id: id})
+	}
+	return panels
+}
+
+// waitForQueue waits until the scheduler has n panels queued for server
+func waitForQueue(s *Scheduler, server string, n int) {
+	for i := 0; i < 1000 && s.Stats()[server].Queued != n; i++ {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// overloadedClient answers every panel render with 429 Too Many Requests
+type overloadedClient struct {
+	mockGrafanaClient
+}
+
+func (m *overloadedClient) GetPanelPng(p grafana.Panel, dashName string, t grafana.TimeRange) (io.ReadCloser, error) {
+	return nil, &grafana.StatusError{StatusCode: http.StatusTooManyRequests, Message: "Error obtaining render: 429 Too Many Requests"}
+}
+
+func TestOverloadedReport(t *testing.T) {
+	Convey("When Grafana is overloaded while generating a report", t, func() {
+		s := NewScheduler(4)
+		gClient := &overloadedClient{mockGrafanaClient{0, url.Values{}}}
+		rep := new(gClient, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Scheduler: s, Format: FormatHTML})
+		defer rep.Clean()
+		html, err := rep.Generate()
+		So(err, ShouldBeNil)
+		html.Close()
+
+		Convey("The scheduler should slow down renders to that Grafana server", func() {
+			So(s.Stats()["http://grafana"].Limit, ShouldEqual, 1)
+		})
+	})
+}
+
+func TestScheduler(t *testing.T) {
+	Convey("When rendering panels with a scheduler", t, func() {
+		Convey("It should not exceed the concurrency limit across reports", func() {
+			s := NewScheduler(3)
+			var mu sync.Mutex
+			active, maxActive := 0, 0
+			render := func(p grafana.Panel) error {
+				mu.Lock()
+				active++
+				if active > maxActive {
+					maxActive = active
+				}
+				mu.Unlock()
+				time.Sleep(5 * time.Millisecond)
+				mu.Lock()
+				active--
+				mu.Unlock()
+				return nil
+			}
+			var wg sync.WaitGroup
+			for i := 0; i < 4; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					s.Render("http://grafana", panelsWithIds(1, 2, 3, 4, 5), render)
+				}()
+			}
+			wg.Wait()
+			So(maxActive, ShouldEqual, 3)
+			So(s.Stats()["http://grafana"], ShouldResemble, RenderStats{Queued: 0, Active: 0, Limit: 3})
+		})
+
+		Convey("Reports should take turns", func() {
+			s := NewScheduler(1)
+			var mu sync.Mutex
+			order := []int{}
+			gate := make(chan struct{})
+			render := func(p grafana.Panel) error {
+				if p.Id == 1 {
+					<-gate
+				}
+				mu.Lock()
+				order = append(order, p.Id)
+				mu.Unlock()
+				return nil
+			}
+			var wg sync.WaitGroup
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				s.Render("", panelsWithIds(1, 2, 3, 4), render)
+			}()
+			waitForQueue(s, "", 3)
+			go func() {
+				defer wg.Done()
+				s.Render("", panelsWithIds(11, 12), render)
+			}()
+			waitForQueue(s, "", 5)
+			close(gate)
+			wg.Wait()
+			So(order, ShouldResemble, []int{1, 11, 2, 12, 3, 4})
+		})
+
+		Convey("It should slow down when Grafana is overloaded", func() {
+			s := NewScheduler(4)
+			overloaded := &grafana.StatusError{StatusCode: 429, Message: "Error obtaining render: 429 Too Many Requests"}
+			err := s.Render("", panelsWithIds(1), func(p grafana.Panel) error { return overloaded })
+			So(err, ShouldEqual, overloaded)
+			So(s.Stats()[""].Limit, ShouldEqual, 2)
+
+			Convey("And speed up again after successful renders", func() {
+				s.Render("", panelsWithIds(1, 2, 3, 4, 5, 6), func(p grafana.Panel) error { return nil })
+				So(s.Stats()[""].Limit, ShouldEqual, 4)
+			})
+		})
+
+		Convey("It should render all panels and return the first error", func() {
+			s := NewScheduler(1)
+			rendered := 0
+			err := s.Render("", panelsWithIds(1, 2, 3), func(p grafana.Panel) error {
+				rendered++
+				if p.Id == 2 {
+					return errors.New("panel 2 failed")
+				}
+				return nil
+			})
+			So(err, ShouldNotBeNil)
+			So(rendered, ShouldEqual, 3)
+		})
+	})
+}
@@ -0,0 +1,194 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/IzakMarais/reporter/grafana"
+)
+
+// templateSource is the name and text of one template in a template set
+type templateSource struct {
+	name string
+	text string
+}
+
+// templateSources returns the templates a report's template set is parsed from, in order: the default template,
+// the partials in the templates directory, then the custom template. The partials are the files named _name.ext,
+// available as [[template "name" .]]. Partials and the custom template can override the blocks of the default
+// template by defining templates of the same name. A custom template consisting only of definitions keeps the
+// default template's body.
+func (rep *report) templateSources(defaultTemplate, ext string) ([]templateSource, error) {
+	sources := []templateSource{{"report", defaultTemplate}}
+	if rep.opts.TemplateDir != "" {
+		files, err := filepath.Glob(filepath.Join(rep.opts.TemplateDir, "_*"+ext))
+		if err != nil {
+			return nil, fmt.Errorf("error listing partials in %v: %v", rep.opts.TemplateDir, err)
+		}
+		sort.Strings(files)
+		for _, f := range files {
+			text, err := ioutil.ReadFile(f)
+			if err != nil {
+				return nil, fmt.Errorf("error reading partial %v: %v", f, err)
+			}
+			name := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(f), "_"), ext)
+			sources = append(sources, templateSource{name, string(text)})
+		}
+	}
+	if rep.opts.Template != "" {
+		sources = append(sources, templateSource{"report", rep.opts.Template})
+	}
+	return sources, nil
+}
+
+// templateFuncs are the functions available to all report templates, in addition to the text/template builtins
+func templateFuncs() map[string]interface{} {
+	return map[string]interface{}{
+		"formatDate":   formatDate,
+		"now":          time.Now,
+		"panelsOfType": panelsOfType,
+		"panelsTitled": panelsTitled,
+		"panelsTagged": panelsTagged,
+		"first":        first,
+		"last":         last,
+		"skip":         skip,
+		"join":         func(sep string, a []string) string { return strings.Join(a, sep) },
+		"add":          func(a, b float64) float64 { return a + b },
+		"sub":          func(a, b float64) float64 { return a - b },
+		"mul":          func(a, b float64) float64 { return a * b },
+		"div":          div,
+		"columns":      func(w float64) float64 { return w / 24 },
+	}
+}
+
+// texFuncs are templateFuncs plus the functions specific to TeX templates
+func texFuncs() map[string]interface{} {
+	funcs := templateFuncs()
+	funcs["escape"] = grafana.EscapeLaTeX
+	funcs["plain"] = grafana.PlainText
+	funcs["options"] = texOptions
+	return funcs
+}
+
+// texOptions returns a LaTeX option list, e.g. [a4paper,margin=1in]. Templates cannot write
+// the brackets of an option list right next to their own [[ ]] delimiters.
+func texOptions(options string) string {
+	return "[" + options + "]"
+}
+
+// formatDate formats t with a Go time layout, e.g. "2 Jan 2006 15:04"
+func formatDate(layout string, t time.Time) string {
+	return t.Format(layout)
+}
+
+func panelsOfType(panelType string, panels []grafana.Panel) []grafana.Panel {
+	filtered := []grafana.Panel{}
+	for _, p := range panels {
+		if p.Type == panelType {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+// panelsTitled returns the panels whose plain text title matches the regular expression pattern
+func panelsTitled(pattern string, panels []grafana.Panel) ([]grafana.Panel, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing title pattern %q: %v", pattern, err)
+	}
+	filtered := []grafana.Panel{}
+	for _, p := range panels {
+		if re.MatchString(grafana.PlainText(p.Title)) {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered, nil
+}
+
+func panelsTagged(tag string, panels []grafana.Panel) []grafana.Panel {
+	filtered := []grafana.Panel{}
+	for _, p := range panels {
+		for _, t := range p.Tags {
+			if t == tag {
+				filtered = append(filtered, p)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// first returns the first n elements of a slice, or all of them if there are fewer
+func first(n int, list interface{}) (interface{}, error) {
+	v, err := sliceValue(list)
+	if err != nil {
+		return nil, err
+	}
+	return v.Slice(0, clamp(n, v.Len())).Interface(), nil
+}
+
+// last returns the last n elements of a slice, or all of them if there are fewer
+func last(n int, list interface{}) (interface{}, error) {
+	v, err := sliceValue(list)
+	if err != nil {
+		return nil, err
+	}
+	return v.Slice(v.Len()-clamp(n, v.Len()), v.Len()).Interface(), nil
+}
+
+// skip returns the elements of a slice after the first n
+func skip(n int, list interface{}) (interface{}, error) {
+	v, err := sliceValue(list)
+	if err != nil {
+		return nil, err
+	}
+	return v.Slice(clamp(n, v.Len()), v.Len()).Interface(), nil
+}
+
+func sliceValue(list interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice {
+		return v, fmt.Errorf("expected a list, got %T", list)
+	}
+	return v, nil
+}
+
+func clamp(n, max int) int {
+	if n < 0 {
+		return 0
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
+
+func div(a, b float64) (float64, error) {
+	if b == 0 {
+		return 0, fmt.Errorf("division of %v by zero", a)
+	}
+	return a / b, nil
+}
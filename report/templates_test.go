@@ -0,0 +1,245 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IzakMarais/reporter/grafana"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTemplateSet(t *testing.T) {
+	Convey("When generating the TeX file with a templates directory", t, func() {
+		dir, err := ioutil.TempDir("", "templates")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		ioutil.WriteFile(filepath.Join(dir, "_packages.tex"), []byte(`\usepackage{xcolor}`), 0666)
+		ioutil.WriteFile(filepath.Join(dir, "_footer.tex"), []byte(`Generated [[formatDate "2006" .FromTime]]`), 0666)
+		ioutil.WriteFile(filepath.Join(dir, "_ignored.html"), []byte(`<p>html</p>`), 0666)
+		ioutil.WriteFile(filepath.Join(dir, "other.tex"), []byte(`[[define "title"]]other[[end]]`), 0666)
+
+		gClient := &mockGrafanaClient{0, url.Values{}}
+		dash, _ := gClient.GetDashboard("")
+		readTeX := func(opts Options) string {
+			opts.TemplateDir = dir
+			rep := new(gClient, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, opts)
+			defer rep.Clean()
+			So(rep.generateTeXFile(dash), ShouldBeNil)
+			b, _ := ioutil.ReadFile(rep.texPath())
+			return string(b)
+		}
+
+		Convey("Partials should override blocks of the default template", func() {
+			s := readTeX(Options{})
+			So(s, ShouldContainSubstring, `\usepackage{xcolor}`)
+			So(s, ShouldContainSubstring, "My first dashboard")
+			So(s, ShouldNotContainSubstring, "other")
+			So(s, ShouldNotContainSubstring, "html")
+		})
+
+		Convey("A custom template of definitions only should keep the default body", func() {
+			s := readTeX(Options{Template: `[[define "closing"]][[template "footer" .]][[end]][[define "panel"]]\panel{[[.Id]]}[[end]]`})
+			So(s, ShouldContainSubstring, `\begin{document}`)
+			So(s, ShouldContainSubstring, `\panel{1}`)
+			So(s, ShouldNotContainSubstring, `\includegraphics`)
+			So(s, ShouldContainSubstring, "Generated 2016")
+		})
+
+		Convey("A custom template with a body should replace the default template", func() {
+			s := readTeX(Options{Template: `[[range .Panels | panelsOfType "singlestat" | first 2]][[.Id]],[[end]]`})
+			So(s, ShouldEqual, "1,33,")
+		})
+	})
+}
+
+func TestTeXNavigation(t *testing.T) {
+	Convey("When generating the TeX file with the default templates", t, func() {
+		gClient := &mockGrafanaClient{0, url.Values{}}
+		dash, _ := gClient.GetDashboard("")
+		dash.Tags = []string{"prod", `db\_1`}
+		dash.Rows[1].Title, dash.Rows[1].Showtitle = "Details", true
+		dash.Rows[1].Panels[1].Title = "CPU"
+		readTeX := func(opts Options) string {
+			opts.User = "alice"
+			rep := new(gClient, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, opts)
+			defer rep.Clean()
+			So(rep.generateTeXFile(dash), ShouldBeNil)
+			b, _ := ioutil.ReadFile(rep.texPath())
+			return string(b)
+		}
+
+		for _, grid := range []bool{false, true} {
+			s := readTeX(Options{GridLayout: grid})
+
+			Convey(fmt.Sprintf("It should set the PDF metadata (grid layout %v)", grid), func() {
+				So(s, ShouldContainSubstring, `pdftitle={My first dashboard}`)
+				So(s, ShouldContainSubstring, `pdfkeywords={prod, db\_1}`)
+				So(s, ShouldContainSubstring, `pdfauthor={alice}`)
+			})
+
+			Convey(fmt.Sprintf("It should bookmark the visible rows and titled panels (grid layout %v)", grid), func() {
+				So(s, ShouldContainSubstring, `\addcontentsline{toc}{section}{Details}`)
+				So(s, ShouldContainSubstring, `\addcontentsline{toc}{subsection}{CPU}`)
+				So(strings.Count(s, `\addcontentsline`), ShouldEqual, 2)
+			})
+
+			Convey(fmt.Sprintf("It should number the pages in the footer (grid layout %v)", grid), func() {
+				So(s, ShouldContainSubstring, `Page \thepage\ of \pageref*{LastPage}`)
+				So(s, ShouldContainSubstring, `\fancyhead[L]{\small My first dashboard}`)
+			})
+
+			Convey(fmt.Sprintf("It should add a section heading per visible row (grid layout %v)", grid), func() {
+				So(s, ShouldContainSubstring, "\\phantomsection\\addcontentsline{toc}{section}{Details}\n\\section*{Details}")
+				So(strings.Count(s, `\section*`), ShouldEqual, 1)
+				So(s, ShouldNotContainSubstring, "\\clearpage\n\\phantomsection\\addcontentsline{toc}{section}{Details}")
+				So(readTeX(Options{GridLayout: grid, RowBreak: true}), ShouldContainSubstring, "\\clearpage\n\\phantomsection\\addcontentsline{toc}{section}{Details}")
+			})
+
+			if !grid {
+				Convey("It should keep each row on one page if it fits", func() {
+					So(s, ShouldContainSubstring, "\\needspace{")
+					So(s, ShouldContainSubstring, "bp}\n\\phantomsection\\addcontentsline{toc}{section}{Details}")
+				})
+			}
+
+			Convey(fmt.Sprintf("It should add a table of contents only if requested (grid layout %v)", grid), func() {
+				So(s, ShouldNotContainSubstring, `\tableofcontents`)
+				So(readTeX(Options{GridLayout: grid, TOC: true}), ShouldContainSubstring, `\tableofcontents`)
+			})
+		}
+	})
+}
+
+func TestRowSpace(t *testing.T) {
+	Convey("When measuring the rows of the default template", t, func() {
+		page, _ := Options{Paper: PaperA4}.pageSettings(PaperLetter)
+		tw, th := page.textSize(false)
+		data := templData{Page: page, panelAspect: func(grafana.Panel) float64 { return 0.5 }}
+		stat := grafana.Panel{Type: "singlestat"}
+		graph := grafana.Panel{Type: "graph"}
+		space := func(row grafana.Row) float64 {
+			bp, err := strconv.ParseFloat(strings.TrimSuffix(data.RowSpace(row), "bp"), 64)
+			So(err, ShouldBeNil)
+			return bp
+		}
+
+		Convey("Singlestat panels should share lines of three", func() {
+			So(space(grafana.Row{Panels: []grafana.Panel{stat, stat, stat, stat}}), ShouldAlmostEqual, 2*0.15*tw, 0.1)
+		})
+
+		Convey("Other panels should take a line each, with a heading for titled rows", func() {
+			So(space(grafana.Row{Title: "DB", Panels: []grafana.Panel{graph}}), ShouldAlmostEqual, 0.75*inch+2*panelSpace+0.5*tw, 0.1)
+		})
+
+		Convey("Rows taller than a page should only keep the heading with the first line", func() {
+			tall := grafana.Row{Title: "DB", Panels: []grafana.Panel{graph, graph, graph, graph, graph}}
+			So(space(tall), ShouldAlmostEqual, 0.75*inch+2*panelSpace+0.5*tw, 0.1)
+			So(space(tall), ShouldBeLessThan, th)
+		})
+	})
+}
+
+func TestTemplateData(t *testing.T) {
+	Convey("When generating the TeX file with request metadata and a public URL", t, func() {
+		gClient := &mockGrafanaClient{0, url.Values{}}
+		opts := Options{
+			Template:  `[[.GeneratedAt.Year]];[[.Version]];[[.User]];[[.Meta.customer]];[[.UID]];[[.DashboardURL]];[[range .Panels]][[$.PanelURL .]] [[end]]`,
+			PublicURL: "https://grafana.example.com/",
+			User:      "ops_team",
+			Meta:      map[string]string{"customer": "A&B"},
+			Version:   "2.3-0",
+		}
+		rep := new(gClient, "testDash", grafana.TimeRange{From: "now-1h", To: "now"}, opts)
+		defer rep.Clean()
+		dash, _ := gClient.GetDashboard("")
+		So(rep.generateTeXFile(dash), ShouldBeNil)
+		b, _ := ioutil.ReadFile(rep.texPath())
+		fields := strings.Split(string(b), ";")
+
+		Convey("It should include the generation time, version, user and metadata, escaped for TeX", func() {
+			So(fields[0], ShouldEqual, strconv.Itoa(time.Now().Year()))
+			So(fields[1:4], ShouldResemble, []string{"2.3-0", `ops\_team`, `A\&B`})
+		})
+
+		Convey("It should link to the dashboard and its panels", func() {
+			So(fields[5], ShouldEqual, "https://grafana.example.com/d/testDash?from=now-1h&to=now")
+			So(fields[6], ShouldStartWith, "https://grafana.example.com/d/testDash?from=now-1h&to=now&panelId=1&fullscreen ")
+		})
+
+		Convey("Without a public URL there should be no links", func() {
+			rep.opts.PublicURL = ""
+			data := rep.templData(dash, true)
+			So(data.DashboardURL, ShouldEqual, "")
+			So(data.PanelURL(dash.Panels[0]), ShouldEqual, "")
+		})
+	})
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	Convey("When using the template functions", t, func() {
+		panels := []grafana.Panel{
+			{Id: 1, Type: "graph", Title: `CPU \% load`, Tags: []string{"summary"}},
+			{Id: 2, Type: "singlestat", Title: "Memory"},
+			{Id: 3, Type: "graph", Title: "CPU temperature", Tags: []string{"detail", "summary"}},
+		}
+		ids := func(panels []grafana.Panel) []int {
+			ids := []int{}
+			for _, p := range panels {
+				ids = append(ids, p.Id)
+			}
+			return ids
+		}
+
+		Convey("It should filter panels by type, title and tag", func() {
+			So(ids(panelsOfType("graph", panels)), ShouldResemble, []int{1, 3})
+			titled, err := panelsTitled("^CPU % ", panels)
+			So(err, ShouldBeNil)
+			So(ids(titled), ShouldResemble, []int{1})
+			_, err = panelsTitled("(", panels)
+			So(err, ShouldNotBeNil)
+			So(ids(panelsTagged("summary", panels)), ShouldResemble, []int{1, 3})
+		})
+
+		Convey("It should slice lists", func() {
+			l, _ := first(2, panels)
+			So(ids(l.([]grafana.Panel)), ShouldResemble, []int{1, 2})
+			l, _ = last(5, panels)
+			So(ids(l.([]grafana.Panel)), ShouldResemble, []int{1, 2, 3})
+			l, _ = skip(1, panels)
+			So(ids(l.([]grafana.Panel)), ShouldResemble, []int{2, 3})
+			_, err := first(1, "abc")
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("It should do maths on gridPos values and format dates", func() {
+			funcs := templateFuncs()
+			So(funcs["columns"].(func(float64) float64)(12), ShouldEqual, 0.5)
+			_, err := div(1, 0)
+			So(err, ShouldNotBeNil)
+			So(formatDate("2006-01-02", time.Date(2018, 3, 4, 0, 0, 0, 0, time.UTC)), ShouldEqual, "2018-03-04")
+		})
+	})
+}
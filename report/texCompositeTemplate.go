@@ -0,0 +1,37 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+// compositeTemplate is the document body of composite reports. It uses the blocks of the default template,
+// as overridden by custom templates, with the data of each dashboard for its chapter.
+const compositeTemplate = `
+[[template "preamble" .]]
+\begin{document}
+[[template "title" .]]
+[[template "toc" .]]
+[[range .Chapters]]\chapter{[[.Title]]}
+[[block "chapter" .]][[if .VariableValues]]\noindent [[.VariableValues]]\par
+[[end]]\noindent [[.FromFormatted]] -- [[.ToFormatted]]\par
+[[if .Description]]\noindent{\small [[.Description]]}\par
+[[end]][[end]]\graphicspath{ {[[.ImageDir]]/} }
+[[template "panels" .]]
+[[template "failures" .]]
+[[end]]
+[[template "disclaimer" .]]
+[[template "closing" .]]
+\end{document}
+`
@@ -20,26 +20,84 @@ package report
 
 const defaultGridTemplate = `
 %use square brackets as golang text templating delimiters
-\documentclass{article}
-\usepackage{graphicx}
-\usepackage[margin=0.5in]{geometry}
-
+%custom templates can override the blocks below by defining templates of the same name, e.g. "title"
+[[block "preamble" .]]\documentclass{[[.DocumentClass]]}
+[[if .TeX.Unicode]]\usepackage{fontspec}
+[[if .TeX.MainFont]]\setmainfont{[[.TeX.MainFont]]}[[if .TeX.FontsDir]][Path=[[.TeX.FontsDir]]/][[end]]
+[[end]][[else]]\usepackage[utf8]{inputenc}
+\usepackage[T1]{fontenc}
+[[end]]\usepackage{graphicx}
+\usepackage{fancyhdr}
+\usepackage{lastpage}
+[[block "branding" .]][[if .Branding.Color]]\usepackage{xcolor}
+\definecolor{brand}{HTML}{[[.Branding.HexColor]]}
+[[end]][[end]]\usepackage{tikz}
+\usepackage{pdflscape}
+\usepackage[[options .Page.Geometry]]{geometry}
+[[block "packages" .]][[end]]
+\usepackage{hyperref}
+[[block "metadata" .]]\hypersetup{hidelinks, pdftitle={[[.Title]]}, pdfsubject={[[.VariableValues]]}, pdfkeywords={[[join ", " .Tags]]}, pdfauthor={[[.User]]}, pdfcreator={grafana-reporter [[.Version]]}}
+[[end]][[if and .TeX.Unicode .TeX.RTL]]\usepackage{[[.TeX.BidiPackage]]}
+[[else]]\providecommand{\RL}[1]{#1}
+[[end]]
+\renewcommand{\contentsname}{[[.Message "contents"]]}
 \graphicspath{ {images/} }
+[[block "pagestyle" .]]\setlength{\headheight}{14pt}
+\fancypagestyle{plain}{\fancyhf{}\renewcommand{\headrulewidth}{0pt}[[if .Branding.Company]]\fancyfoot[L]{\small [[.Branding.Company]]}[[end]]\fancyfoot[C]{\small [[.Message "page"]] \thepage\ [[.Message "of"]] \pageref*{LastPage}}}
+\pagestyle{fancy}
+\fancyhf{}
+\fancyhead[L]{\small [[if .Branding.Logo]]\includegraphics[height=10pt]{[[.Branding.Logo]]}\ [[end]][[.Title]]}
+\fancyhead[R]{\small [[.FromFormatted]] -- [[.ToFormatted]]}
+[[if .Branding.Company]]\fancyfoot[L]{\small [[.Branding.Company]]}
+[[end]]\fancyfoot[C]{\small [[.Message "page"]] \thepage\ [[.Message "of"]] \pageref*{LastPage}}
+[[end]][[end]]
 \begin{document}
-\title{[[.Title]] [[if .VariableValues]] \\ \large [[.VariableValues]] [[end]] [[if .Description]] \\ \small [[.Description]] [[end]]}
-\date{[[.FromFormatted]]\\to\\[[.ToFormatted]]}
+[[block "title" .]][[if .Branding.Cover]][[block "cover" .]]\begin{titlepage}
+\centering
+[[if .Branding.Logo]]\includegraphics[width=0.4\textwidth,height=4cm,keepaspectratio]{[[.Branding.Logo]]}\par
+[[end]]\vspace*{3cm}
+{\Huge\bfseries [[if .Branding.Color]]\color{brand}[[end]][[.Title]]\par}
+[[if .VariableValues]]\vspace{0.5cm}
+{\Large [[.VariableValues]]\par}
+[[end]][[if .Description]]\vspace{0.5cm}
+{\small [[.Description]]\par}
+[[end]]\vspace{1cm}
+{\large [[.FromFormatted]] -- [[.ToFormatted]]\par}
+\vfill
+[[if .Branding.Company]]{\large [[.Branding.Company]]\par}
+[[end]][[if .Branding.Disclaimer]]\vspace{0.5cm}
+{\footnotesize [[.Branding.Disclaimer]]\par}
+[[end]]\end{titlepage}
+[[end]][[else]]\title{[[if .Branding.Logo]]\includegraphics[height=2cm,keepaspectratio]{[[.Branding.Logo]]} \\ [[end]][[if .Branding.Color]]\color{brand}[[end]][[.Title]] [[if .VariableValues]] \\ \large [[.VariableValues]] [[end]] [[if .Description]] \\ \small [[.Description]] [[end]]}
+[[if .Branding.Company]]\author{[[.Branding.Company]]}
+[[end]]\date{[[.FromFormatted]]\\[[.Message "to"]]\\[[.ToFormatted]]}
 \maketitle
-\begin{center}
-[[range .Panels]][[if .IsPartialWidth]]\begin{minipage}{[[.Width]]\textwidth}
-\includegraphics[width=\textwidth]{image[[.Id]]}
-\end{minipage}
-[[else]]\par
-\vspace{0.5cm}
-\includegraphics[width=\textwidth]{image[[.Id]]}
-\par
-\vspace{0.5cm}
 [[end]][[end]]
-
-\end{center}
+[[block "toc" .]][[if .TOC]]\tableofcontents
+\clearpage
+[[end]][[end]]
+[[block "panels" .]][[range $i, $page := .GridPages]][[if $i]]\clearpage
+[[end]][[if .Landscape]]\begin{landscape}
+[[end]][[range .Bands]][[if .Heading]]\phantomsection\addcontentsline{toc}{section}{[[.Heading]]}
+\section*{[[if $.Branding.Color]]\color{brand}[[end]][[.Heading]]}
+[[end]][[if .Panels]][[range .Panels]][[if .Title]]\phantomsection\addcontentsline{toc}{subsection}{[[.Title]]}%
+[[end]][[end]]{\centering\noindent\begin{tikzpicture}[x=[[.Unit]]\textwidth,y=-[[.Unit]]\textwidth]
+\useasboundingbox (0,0) rectangle (24,[[.Height]]);
+[[range .Panels]]\node[anchor=north west,inner sep=0pt] at ([[.X]],[[.Y]]) {[[if $.Periods]][[block "comparison" ($.GridComparison .)]][[if .Stacked]]\begin{minipage}{[[.Width]]\textwidth}[[end]][[range .Images]]\begin{minipage}[t]{[[$.Width]]\textwidth}\centering\includegraphics[width=\textwidth,height=[[$.Height]]\textwidth]{[[.Name]]}\\\tiny [[.Caption]]\end{minipage}[[if $.Stacked]]\par[[end]][[end]][[if .Stacked]]\end{minipage}[[end]][[end]][[else]][[block "panel" .]]\includegraphics[width=[[.ImageWidth]]\textwidth,height=[[.ImageHeight]]\textwidth]{image[[.Id]]}[[end]][[end]]};
+[[end]]\end{tikzpicture}\par}
+[[end]][[end]][[if .Landscape]]\end{landscape}
+[[end]][[end]][[end]]
+[[block "failures" .]][[if .Failures]]\clearpage
+\phantomsection\addcontentsline{toc}{section}{[[.Message "failures"]]}
+\section*{[[if .Branding.Color]]\color{brand}[[end]][[.Message "failures"]]}
+\begin{itemize}
+[[range .Failures]]\item [[$.Message "panel"]] [[.Panel.Id]][[if .Panel.Title]] \textit{[[.Panel.Title]]}[[end]]: [[.Error]]
+[[end]]\end{itemize}
+[[end]][[end]]
+[[block "disclaimer" .]][[if and .Branding.Disclaimer (not .Branding.Cover)]]\par
+\vspace{1cm}
+\noindent{\footnotesize [[.Branding.Disclaimer]]\par}
+[[end]][[end]]
+[[block "closing" .]][[end]]
 \end{document}
 `
@@ -0,0 +1,201 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pborman/uuid"
+)
+
+// DefaultWorkDir is the work directory of reports that have no Options.Workspace, relative to the working directory
+const DefaultWorkDir = "tmp"
+
+// defaultWorkspace holds the temporary files of reports that have no Options.Workspace
+var defaultWorkspace = NewWorkspace(DefaultWorkDir, 0)
+
+const diagnosticsDir = "diagnostics"
+
+// Workspace is the directory that reports are generated in, one sub directory per report.
+// It also holds the TeX source and log of failed LaTeX runs, see DiagnosticsFile.
+type Workspace struct {
+	root   string
+	quota  int64
+	mu     sync.Mutex
+	active map[string]time.Time //report directories that have not been cleaned, by the time they were created
+}
+
+// QuotaError is returned when a report is refused because the work directory uses its quota
+type QuotaError struct {
+	Root  string
+	Used  int64
+	Quota int64
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("work directory %v is full: %.1f MB used of a %.1f MB quota, try again later",
+		e.Root, float64(e.Used)/(1<<20), float64(e.Quota)/(1<<20))
+}
+
+// NewWorkspace returns a Workspace in the root directory. New reports are refused once the files in root
+// use quota bytes or more. There is no quota if it is 0.
+func NewWorkspace(root string, quota int64) *Workspace {
+	return &Workspace{root: root, quota: quota, active: map[string]time.Time{}}
+}
+
+// Root returns the root directory of the workspace
+func (w *Workspace) Root() string {
+	return w.root
+}
+
+// Usage returns the total size of the report directories and diagnostics in the work directory, in bytes.
+// Other directories, such as a disk cache or job results that have size limits of their own, are not counted.
+func (w *Workspace) Usage() (int64, error) {
+	entries, err := ioutil.ReadDir(w.root)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, e := range entries {
+		if !e.IsDir() || (uuid.Parse(e.Name()) == nil && e.Name() != diagnosticsDir) {
+			continue
+		}
+		err = filepath.Walk(filepath.Join(w.root, e.Name()), func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil //removed while walking
+				}
+				return err
+			}
+			if !info.IsDir() {
+				size += info.Size()
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return size, nil
+}
+
+// CheckQuota returns a *QuotaError if the work directory uses its quota
+func (w *Workspace) CheckQuota() error {
+	if w.quota <= 0 {
+		return nil
+	}
+	used, err := w.Usage()
+	if err != nil {
+		return fmt.Errorf("error measuring work directory %v: %v", w.root, err)
+	}
+	if used >= w.quota {
+		return &QuotaError{Root: w.root, Used: used, Quota: w.quota}
+	}
+	return nil
+}
+
+// Sweep removes the report directories that are not in use, such as those left behind by a crashed process,
+// and those in use for longer than maxAge, whose reports were never cleaned. LaTeX diagnostics older than maxAge
+// are removed too. Nothing in use expires if maxAge is 0. Sweep returns the number of directories removed.
+func (w *Workspace) Sweep(maxAge time.Duration) (int, error) {
+	now := time.Now()
+	expired := func(t time.Time) bool {
+		return maxAge > 0 && now.Sub(t) > maxAge
+	}
+
+	entries, err := ioutil.ReadDir(w.root)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error reading work directory %v: %v", w.root, err)
+	}
+	var orphans []string
+	for _, e := range entries {
+		if !e.IsDir() || uuid.Parse(e.Name()) == nil {
+			continue //the directories of jobs, the cache and diagnostics
+		}
+		dir := filepath.Join(w.root, e.Name())
+		w.mu.Lock()
+		created, inUse := w.active[dir]
+		if !inUse || expired(created) {
+			delete(w.active, dir)
+			orphans = append(orphans, dir)
+		}
+		w.mu.Unlock()
+	}
+
+	diagnostics, err := ioutil.ReadDir(filepath.Join(w.root, diagnosticsDir))
+	if err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("error reading diagnostics directory: %v", err)
+	}
+	for _, e := range diagnostics {
+		if expired(e.ModTime()) {
+			orphans = append(orphans, filepath.Join(w.root, diagnosticsDir, e.Name()))
+		}
+	}
+
+	for i, dir := range orphans {
+		if err := os.RemoveAll(dir); err != nil {
+			return i, fmt.Errorf("error removing orphaned directory %v: %v", dir, err)
+		}
+	}
+	return len(orphans), nil
+}
+
+// DiagnosticsFile returns the path of a file kept from a failed LaTeX run: name is report.tex or report.log and
+// id is the ID of the LaTeXError. It returns an error if the file does not exist, e.g. because it expired.
+func (w *Workspace) DiagnosticsFile(id, name string) (string, error) {
+	if uuid.Parse(id) == nil || (name != reportTexFile && name != reportLogFile) {
+		return "", fmt.Errorf("unknown diagnostics file %v/%v", id, name)
+	}
+	path := filepath.Join(w.root, diagnosticsDir, id, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("error finding diagnostics file %v/%v: %v", id, name, err)
+	}
+	return path, nil
+}
+
+// newDir returns a new report directory, in use until it is released
+func (w *Workspace) newDir() string {
+	dir := filepath.Join(w.root, uuid.New())
+	w.mu.Lock()
+	w.active[dir] = time.Now()
+	w.mu.Unlock()
+	return dir
+}
+
+func (w *Workspace) release(dir string) {
+	w.mu.Lock()
+	delete(w.active, dir)
+	w.mu.Unlock()
+}
+
+func (rep *report) workspace() *Workspace {
+	if rep.opts.Workspace != nil {
+		return rep.opts.Workspace
+	}
+	return defaultWorkspace
+}
@@ -0,0 +1,129 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/IzakMarais/reporter/grafana"
+	"github.com/pborman/uuid"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWorkspace(t *testing.T) {
+	Convey("Given a workspace", t, func() {
+		root, err := ioutil.TempDir("", "workspace")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(root)
+		ws := NewWorkspace(root, 1000)
+		newReport := func() *report {
+			return new(&mockGrafanaClient{0, url.Values{}}, "testDash", grafana.TimeRange{From: "now-1h", To: "now"}, Options{Workspace: ws})
+		}
+
+		Convey("Reports should be generated in a directory of their own in the root", func() {
+			rep := newReport()
+			So(filepath.Dir(rep.tmpDir), ShouldEqual, root)
+			So(ws.active, ShouldContainKey, rep.tmpDir)
+			rep.Clean()
+			So(ws.active, ShouldBeEmpty)
+		})
+
+		Convey("Sweeping should remove orphaned report directories only", func() {
+			orphan := filepath.Join(root, uuid.New())
+			So(os.MkdirAll(orphan, 0777), ShouldBeNil)
+			So(os.MkdirAll(filepath.Join(root, "jobs"), 0777), ShouldBeNil)
+			rep := newReport()
+			defer rep.Clean()
+			So(os.MkdirAll(rep.tmpDir, 0777), ShouldBeNil)
+
+			n, err := ws.Sweep(time.Hour)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+			_, err = os.Stat(orphan)
+			So(os.IsNotExist(err), ShouldBeTrue)
+			_, err = os.Stat(rep.tmpDir)
+			So(err, ShouldBeNil)
+			_, err = os.Stat(filepath.Join(root, "jobs"))
+			So(err, ShouldBeNil)
+
+			Convey("Including those of reports in use for longer than the maximum age", func() {
+				ws.active[rep.tmpDir] = time.Now().Add(-2 * time.Hour)
+				n, err := ws.Sweep(time.Hour)
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, 1)
+				_, err = os.Stat(rep.tmpDir)
+				So(os.IsNotExist(err), ShouldBeTrue)
+				So(ws.active, ShouldBeEmpty)
+			})
+		})
+
+		Convey("Sweeping should remove expired diagnostics", func() {
+			dir := filepath.Join(root, diagnosticsDir, uuid.New())
+			So(os.MkdirAll(dir, 0777), ShouldBeNil)
+			n, err := ws.Sweep(time.Hour)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+
+			old := time.Now().Add(-2 * time.Hour)
+			So(os.Chtimes(dir, old, old), ShouldBeNil)
+			n, err = ws.Sweep(time.Hour)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+		})
+
+		Convey("Sweeping a missing root should do nothing", func() {
+			n, err := NewWorkspace(filepath.Join(root, "missing"), 0).Sweep(time.Hour)
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 0)
+		})
+
+		Convey("Reports should be refused once the quota is used", func() {
+			So(ws.CheckQuota(), ShouldBeNil)
+			orphan := filepath.Join(root, uuid.New())
+			So(os.MkdirAll(orphan, 0777), ShouldBeNil)
+			So(ioutil.WriteFile(filepath.Join(orphan, "image1.png"), make([]byte, 1000), 0666), ShouldBeNil)
+			used, err := ws.Usage()
+			So(err, ShouldBeNil)
+			So(used, ShouldEqual, 1000)
+
+			rep := newReport()
+			defer rep.Clean()
+			_, err = rep.Generate()
+			So(err, ShouldHaveSameTypeAs, &QuotaError{})
+			So(err.Error(), ShouldContainSubstring, "is full")
+
+			Convey("The disk cache and job results should not count", func() {
+				for _, dir := range []string{"cache", "jobs"} {
+					So(os.MkdirAll(filepath.Join(root, dir), 0777), ShouldBeNil)
+					So(ioutil.WriteFile(filepath.Join(root, dir, "result.pdf"), make([]byte, 5000), 0666), ShouldBeNil)
+				}
+				used, err := ws.Usage()
+				So(err, ShouldBeNil)
+				So(used, ShouldEqual, 1000)
+			})
+
+			Convey("Unless there is no quota", func() {
+				So(NewWorkspace(root, 0).CheckQuota(), ShouldBeNil)
+			})
+		})
+	})
+}
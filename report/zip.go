@@ -0,0 +1,177 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"time"
+
+	"github.com/IzakMarais/reporter/grafana"
+)
+
+// manifest describes the contents of a ZIP report, so that downstream tooling can build its own layouts
+type manifest struct {
+	Dashboard manifestDashboard `json:"dashboard"`
+	TimeRange manifestTimeRange `json:"timeRange"`
+	Variables url.Values        `json:"variables"`
+	Panels    []manifestPanel   `json:"panels"`
+	TexFile   string            `json:"texFile"`
+	// Failures lists the panels that failed to render, whose images are placeholders with the error
+	Failures []manifestFailure `json:"failures,omitempty"`
+}
+
+type manifestFailure struct {
+	ID    int    `json:"id"`
+	Error string `json:"error"`
+}
+
+type manifestDashboard struct {
+	Name        string `json:"name"`
+	UID         string `json:"uid,omitempty"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+}
+
+type manifestTimeRange struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	RawFrom string    `json:"rawFrom"`
+	RawTo   string    `json:"rawTo"`
+}
+
+type manifestPanel struct {
+	ID        int             `json:"id"`
+	Title     string          `json:"title"`
+	Type      string          `json:"type"`
+	GridPos   grafana.GridPos `json:"gridPos"`
+	Image     string          `json:"image"`
+	RenderURL string          `json:"renderUrl"`
+}
+
+// zipRenderer packages the panel images, the generated TeX source and a manifest into a ZIP archive
+type zipRenderer struct{}
+
+func (zipRenderer) render(rep *report, dash grafana.Dashboard) (io.ReadCloser, error) {
+	err := rep.generateTeXFile(dash)
+	if err != nil {
+		return nil, fmt.Errorf("error generating TeX file for dash %+v: %v", dash, err)
+	}
+
+	file, err := os.Create(rep.zipPath())
+	if err != nil {
+		return nil, fmt.Errorf("error creating zip file at %v: %v", rep.zipPath(), err)
+	}
+	zw := zip.NewWriter(file)
+	err = rep.writeZip(zw, dash)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	file.Close()
+	if err != nil {
+		return nil, fmt.Errorf("error writing zip file at %v: %v", rep.zipPath(), err)
+	}
+
+	zipFile, err := os.Open(rep.zipPath())
+	if err != nil {
+		return nil, err
+	}
+	return zipFile, nil
+}
+
+func (rep *report) writeZip(zw *zip.Writer, dash grafana.Dashboard) error {
+	m := rep.manifest(dash)
+	w, err := zw.Create(manifestFile)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(m); err != nil {
+		return fmt.Errorf("error encoding manifest: %v", err)
+	}
+
+	if err = addFileToZip(zw, reportTexFile, rep.texPath()); err != nil {
+		return err
+	}
+	for i, p := range dash.Panels {
+		if err = addFileToZip(zw, m.Panels[i].Image, rep.imgFilePath(p)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rep *report) manifest(dash grafana.Dashboard) manifest {
+	plain := plainDashboard(dash)
+	m := manifest{
+		Dashboard: manifestDashboard{
+			Name:        rep.dashName,
+			UID:         dash.UID,
+			Title:       plain.Title,
+			Description: plain.Description,
+		},
+		TimeRange: manifestTimeRange{
+			From:    rep.time.FromTime(),
+			To:      rep.time.ToTime(),
+			RawFrom: rep.time.From,
+			RawTo:   rep.time.To,
+		},
+		Variables: dash.Variables,
+		Panels:    []manifestPanel{},
+		TexFile:   reportTexFile,
+	}
+	if m.Variables == nil {
+		m.Variables = url.Values{}
+	}
+	for _, p := range plain.Panels {
+		m.Panels = append(m.Panels, manifestPanel{
+			ID:        p.Id,
+			Title:     p.Title,
+			Type:      p.Type,
+			GridPos:   p.GridPos,
+			Image:     path.Join(imgDir, imgFileName(p)),
+			RenderURL: rep.gClient.GetPanelPngURL(p, rep.dashName, rep.time),
+		})
+	}
+	for _, f := range rep.failures {
+		m.Failures = append(m.Failures, manifestFailure{f.Panel.Id, f.Error})
+	}
+	return m
+}
+
+func addFileToZip(zw *zip.Writer, name, filePath string) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("error opening %v: %v", filePath, err)
+	}
+	defer f.Close()
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	if err != nil {
+		return fmt.Errorf("error adding %v to zip: %v", filePath, err)
+	}
+	return nil
+}
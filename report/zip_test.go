@@ -0,0 +1,77 @@
+/*
+   Copyright 2018 Vastech SA (PTY) LTD
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+
+   Unless required by applicable law or agreed to in writing, software
+   distributed under the License is distributed on an "AS IS" BASIS,
+   WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+   See the License for the specific language governing permissions and
+   limitations under the License.
+*/
+
+package report
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"testing"
+
+	"github.com/IzakMarais/reporter/grafana"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestZipReport(t *testing.T) {
+	Convey("When generating a ZIP report", t, func() {
+		variables := url.Values{}
+		variables.Add("var-test", "testvarvalue")
+		gClient := &mockGrafanaClient{0, variables}
+		rep := new(gClient, "testDash", grafana.TimeRange{From: "1453206447000", To: "1453213647000"}, Options{Format: FormatZIP})
+		defer rep.Clean()
+
+		file, err := rep.Generate()
+		So(err, ShouldBeNil)
+		file.Close()
+
+		zr, err := zip.OpenReader(rep.zipPath())
+		So(err, ShouldBeNil)
+		defer zr.Close()
+		files := map[string]*zip.File{}
+		for _, f := range zr.File {
+			files[f.Name] = f
+		}
+
+		Convey("It should contain every panel image and the TeX source", func() {
+			So(files, ShouldHaveLength, 11)
+			So(files, ShouldContainKey, "images/image1.png")
+			So(files, ShouldContainKey, "images/image99.png")
+			So(files, ShouldContainKey, "report.tex")
+		})
+
+		Convey("It should contain a manifest describing the report", func() {
+			So(files, ShouldContainKey, "manifest.json")
+			r, _ := files["manifest.json"].Open()
+			defer r.Close()
+			b, _ := ioutil.ReadAll(r)
+			var m manifest
+			So(json.Unmarshal(b, &m), ShouldBeNil)
+
+			So(m.Dashboard.Name, ShouldEqual, "testDash")
+			So(m.Dashboard.Title, ShouldEqual, "My first dashboard")
+			So(m.TimeRange.From.Unix(), ShouldEqual, 1453206447)
+			So(m.TimeRange.To.Unix(), ShouldEqual, 1453213647)
+			So(m.Variables.Get("var-test"), ShouldEqual, "testvarvalue")
+			So(m.Panels, ShouldHaveLength, 9)
+			So(m.Panels[1].ID, ShouldEqual, 22)
+			So(m.Panels[1].Type, ShouldEqual, "graph")
+			So(m.Panels[1].Image, ShouldEqual, "images/image22.png")
+			So(m.Panels[1].RenderURL, ShouldEqual, "http://grafana/render/d-solo/testDash/_?panelId=22")
+		})
+	})
+}